@@ -2,25 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Shafeeqth/notification-service/internal/application/service"
 	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/auth"
 	"github.com/Shafeeqth/notification-service/internal/infrastructure/config"
 	"github.com/Shafeeqth/notification-service/internal/infrastructure/database"
-	"github.com/Shafeeqth/notification-service/internal/infrastructure/email"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/i18n"
 	"github.com/Shafeeqth/notification-service/internal/infrastructure/kafka"
 	"github.com/Shafeeqth/notification-service/internal/infrastructure/logging"
 	"github.com/Shafeeqth/notification-service/internal/infrastructure/metrics"
 	"github.com/Shafeeqth/notification-service/internal/infrastructure/notification"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/notification/email"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/otp"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/ratelimit"
 	"github.com/Shafeeqth/notification-service/internal/infrastructure/redis"
 	"github.com/Shafeeqth/notification-service/internal/presentation/grpc"
-	_ "github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres" // Import the PostgreSQL driver
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/Shafeeqth/notification-service/internal/shared/template"
 	"go.uber.org/zap"
+	ggrpc "google.golang.org/grpc"
 )
 
 type NotificationRepository struct {
@@ -44,8 +49,11 @@ func (r *NotificationRepository) SaveNotification(ctx context.Context, notificat
 func (r *NotificationRepository) GetANotification(ctx context.Context, notificationId, userId string) (*domain.Notification, error) {
 	return r.repo.GetANotification(ctx, notificationId, userId)
 }
-func (r *NotificationRepository) GetAllNotifications(ctx context.Context, userId string, page, pageSize int, isRead *bool, notifyType *string) ([]domain.Notification, int64, error) {
-	return r.repo.GetAllNotifications(ctx, userId, page, pageSize, isRead, notifyType)
+func (r *NotificationRepository) GetAllNotifications(ctx context.Context, userId string, filter domain.NotificationFilter, after *domain.PageCursor, pageSize int) ([]domain.Notification, *domain.PageCursor, int64, error) {
+	return r.repo.GetAllNotifications(ctx, userId, filter, after, pageSize)
+}
+func (r *NotificationRepository) SearchNotifications(ctx context.Context, userId string, query domain.SearchQuery, offset, limit int) ([]domain.SearchResult, bool, error) {
+	return r.repo.SearchNotifications(ctx, userId, query, offset, limit)
 }
 func (r *NotificationRepository) MarkAsRead(ctx context.Context, notificationId, userId string) error {
 	return r.repo.MarkAsRead(ctx, notificationId, userId)
@@ -53,11 +61,75 @@ func (r *NotificationRepository) MarkAsRead(ctx context.Context, notificationId,
 func (r *NotificationRepository) MarkAllAsRead(ctx context.Context, userId string) error {
 	return r.repo.MarkAllAsRead(ctx, userId)
 }
-func (r *NotificationRepository) MarkAsProcessed(ctx context.Context, notificationId string) error {
-	return r.repo.MarkAsProcessed(ctx, notificationId)
+func (r *NotificationRepository) DeleteNotifications(ctx context.Context, userId string, notificationIds []string) (int64, error) {
+	return r.repo.DeleteNotifications(ctx, userId, notificationIds)
+}
+func (r *NotificationRepository) ArchiveNotifications(ctx context.Context, userId string, notificationIds []string, archived bool) (int64, error) {
+	return r.repo.ArchiveNotifications(ctx, userId, notificationIds, archived)
+}
+func (r *NotificationRepository) SnoozeNotifications(ctx context.Context, userId string, notificationIds []string, until time.Time) (int64, error) {
+	return r.repo.SnoozeNotifications(ctx, userId, notificationIds, until)
+}
+func (r *NotificationRepository) PinNotifications(ctx context.Context, userId string, notificationIds []string, pinned bool) (int64, error) {
+	return r.repo.PinNotifications(ctx, userId, notificationIds, pinned)
+}
+func (r *NotificationRepository) GetUnreadCount(ctx context.Context, userId string) (domain.UnreadCount, error) {
+	return r.repo.GetUnreadCount(ctx, userId)
+}
+func (r *NotificationRepository) WatchUnreadCount(ctx context.Context, userId string) (<-chan struct{}, error) {
+	return r.repo.WatchUnreadCount(ctx, userId)
+}
+func (r *NotificationRepository) ReconcileUnreadCounts(ctx context.Context) error {
+	return r.repo.ReconcileUnreadCounts(ctx)
+}
+func (r *NotificationRepository) ClaimNotification(ctx context.Context, notificationId, owner string, lease time.Duration) (domain.ClaimOutcome, error) {
+	return r.repo.ClaimNotification(ctx, notificationId, owner, lease)
+}
+func (r *NotificationRepository) CompleteNotification(ctx context.Context, notificationId, owner string) error {
+	return r.repo.CompleteNotification(ctx, notificationId, owner)
+}
+func (r *NotificationRepository) ReleaseNotification(ctx context.Context, notificationId, owner string) error {
+	return r.repo.ReleaseNotification(ctx, notificationId, owner)
+}
+
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userId string) (domain.UserPreferences, error) {
+	return r.repo.GetPreferences(ctx, userId)
+}
+func (r *NotificationRepository) SavePreferences(ctx context.Context, preferences domain.UserPreferences) error {
+	return r.repo.SavePreferences(ctx, preferences)
+}
+
+func (r *NotificationRepository) ClaimScheduledNotifications(ctx context.Context, limit int) ([]domain.Notification, error) {
+	return r.repo.ClaimScheduledNotifications(ctx, limit)
+}
+func (r *NotificationRepository) ReleaseScheduledNotification(ctx context.Context, notification domain.Notification) error {
+	return r.repo.ReleaseScheduledNotification(ctx, notification)
+}
+
+func (r *NotificationRepository) ReserveIdempotencyKey(ctx context.Context, key domain.IdempotencyKey, staleBefore time.Time) (domain.IdempotencyKey, bool, error) {
+	return r.repo.ReserveIdempotencyKey(ctx, key, staleBefore)
+}
+func (r *NotificationRepository) CompleteIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error {
+	return r.repo.CompleteIdempotencyKey(ctx, key)
+}
+func (r *NotificationRepository) ReleaseIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error {
+	return r.repo.ReleaseIdempotencyKey(ctx, key)
+}
+
+func (r *NotificationRepository) CreateRule(ctx context.Context, rule domain.NotificationRule) (domain.NotificationRule, error) {
+	return r.repo.CreateRule(ctx, rule)
+}
+func (r *NotificationRepository) UpdateRule(ctx context.Context, rule domain.NotificationRule) (domain.NotificationRule, error) {
+	return r.repo.UpdateRule(ctx, rule)
+}
+func (r *NotificationRepository) DeleteRule(ctx context.Context, ruleId string) error {
+	return r.repo.DeleteRule(ctx, ruleId)
+}
+func (r *NotificationRepository) GetRule(ctx context.Context, ruleId string) (domain.NotificationRule, error) {
+	return r.repo.GetRule(ctx, ruleId)
 }
-func (r *NotificationRepository) CheckIfProcessed(ctx context.Context, notificationId string) (bool, error) {
-	return r.repo.CheckIfProcessed(ctx, notificationId)
+func (r *NotificationRepository) ListRules(ctx context.Context, eventType string, enabledOnly bool) ([]domain.NotificationRule, error) {
+	return r.repo.ListRules(ctx, eventType, enabledOnly)
 }
 
 func (r *NotificationRepository) SendEmail(ctx context.Context, recipient, subject, body string) error {
@@ -74,8 +146,32 @@ func (r *NotificationRepository) GetOTP(ctx context.Context, email string) (doma
 	return r.repo.GetOTP(ctx, email)
 }
 
-func (r *NotificationRepository) AutoMigrate() error {
-	return r.repo.AutoMigrate()
+func (r *NotificationRepository) ConsumeOTP(ctx context.Context, email string) (bool, error) {
+	return r.repo.ConsumeOTP(ctx, email)
+}
+
+func (r *NotificationRepository) RecordOTPFailure(ctx context.Context, email string, expiresAt time.Time) (int64, error) {
+	return r.repo.RecordOTPFailure(ctx, email, expiresAt)
+}
+
+func (r *NotificationRepository) SaveTOTPEnrollment(ctx context.Context, enrollment domain.TOTPEnrollment) error {
+	return r.repo.SaveTOTPEnrollment(ctx, enrollment)
+}
+
+func (r *NotificationRepository) GetTOTPEnrollment(ctx context.Context, userId string) (domain.TOTPEnrollment, error) {
+	return r.repo.GetTOTPEnrollment(ctx, userId)
+}
+
+func (r *NotificationRepository) UseTOTPStep(ctx context.Context, userId string, step int64) (bool, error) {
+	return r.repo.UseTOTPStep(ctx, userId, step)
+}
+
+func (r *NotificationRepository) SaveMagicLink(ctx context.Context, tokenId string, ttl time.Duration) error {
+	return r.repo.SaveMagicLink(ctx, tokenId, ttl)
+}
+
+func (r *NotificationRepository) ConsumeMagicLink(ctx context.Context, tokenId string) (bool, error) {
+	return r.repo.ConsumeMagicLink(ctx, tokenId)
 }
 
 // func (r *NotificationRepository) SendEmail(recipient, subject, body string) error {
@@ -86,6 +182,147 @@ func (r *NotificationRepository) AutoMigrate() error {
 // 	return r.inAppSender.SendInApp(userID, message)
 // }
 
+func toLimit(l config.RateLimit) ratelimit.Limit {
+	return ratelimit.Limit{Rate: l.Rate, Burst: l.Burst}
+}
+
+func toQuotaPolicies(c config.RPCQuotaConfig) map[string]grpc.QuotaPolicy {
+	policies := make(map[string]grpc.QuotaPolicy, len(c.Methods))
+	for method, q := range c.Methods {
+		policies[method] = grpc.QuotaPolicy{
+			PerIP:    toLimit(q.IP),
+			PerEmail: toLimit(q.Email),
+			PerUser:  toLimit(q.User),
+		}
+	}
+	return policies
+}
+
+func toRateLimits(c config.RateLimitConfig) ratelimit.Limits {
+	limit := toLimit
+	limits := ratelimit.Limits{
+		Global:    limit(c.Global),
+		Recipient: limit(c.Recipient),
+		Tenant:    limit(c.Tenant),
+		Channels:  make(map[string]ratelimit.Limit),
+		Tenants:   make(map[string]ratelimit.Limit),
+	}
+	for channel, l := range c.Channels {
+		limits.Channels[channel] = limit(l)
+	}
+	for tenant, l := range c.Tenants {
+		limits.Tenants[tenant] = limit(l)
+	}
+	return limits
+}
+
+func toRetention(c config.RetentionConfig) database.RetentionConfig {
+	retention := database.RetentionConfig{
+		PartitionMaxAge:   c.PartitionMaxAge,
+		ArchivePartitions: c.ArchivePartitions,
+		PartitionsAhead:   c.PartitionsAhead,
+		ProcessedMaxAge:   c.ProcessedMaxAge,
+	}
+	for _, p := range c.Policies {
+		retention.Policies = append(retention.Policies, database.RetentionPolicy{
+			Type:     domain.NotificationType(p.Type),
+			Category: p.Category,
+			MaxAge:   p.MaxAge,
+			ReadOnly: p.ReadOnly,
+		})
+	}
+	return retention
+}
+
+func toKafkaClient(c config.KafkaClientConfig) kafka.ClientConfig {
+	client := kafka.ClientConfig{
+		ClientID: c.ClientID,
+		SASL: kafka.SASLConfig{
+			Mechanism: c.SASL.Mechanism,
+			Username:  c.SASL.Username,
+			Password:  c.SASL.Password,
+		},
+	}
+	if c.TLS.Enabled {
+		client.TLS = &kafka.TLSConfig{
+			CAFile:             c.TLS.CAFile,
+			CertFile:           c.TLS.CertFile,
+			KeyFile:            c.TLS.KeyFile,
+			InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+		}
+	}
+	return client
+}
+
+func toKafkaGroup(c config.KafkaConsumerConfig) kafka.GroupConfig {
+	return kafka.GroupConfig{
+		InitialOffset:     c.InitialOffset,
+		SessionTimeout:    c.SessionTimeout,
+		HeartbeatInterval: c.HeartbeatInterval,
+		RebalanceStrategy: c.RebalanceStrategy,
+	}
+}
+
+func toKafkaTopics(c config.KafkaConsumerConfig) []kafka.TopicConfig {
+	topics := make([]kafka.TopicConfig, len(c.Topics))
+	for i, t := range c.Topics {
+		topics[i] = kafka.TopicConfig{
+			Name:        t.Name,
+			Workers:     t.Workers,
+			MaxAttempts: t.MaxAttempts,
+		}
+	}
+	return topics
+}
+
+// deliveredChannels returns the channels that have a sender and whose
+// topic is consumed.
+func deliveredChannels(strategies map[domain.NotificationType]notification.SenderStrategy, c config.KafkaConsumerConfig) []domain.NotificationType {
+	consumed := make(map[string]bool, len(c.Topics))
+	for _, t := range c.Topics {
+		consumed[t.Name] = true
+	}
+	var channels []domain.NotificationType
+	for channel := range strategies {
+		if consumed[service.NotificationTopic(channel)] {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+func toTenants(c map[string]config.TenantConfig) domain.Tenants {
+	tenants := make(domain.Tenants, len(c))
+	for id, t := range c {
+		tenants[id] = domain.Tenant{ID: id, Name: t.Name, Locale: t.Locale, Branding: t.Branding}
+	}
+	return tenants
+}
+
+// newEmailSenders creates the email sender of each tenant. Tenants without
+// their own SMTP account share the top-level one's connections.
+func newEmailSenders(cfg *config.Config, logger *zap.Logger) (map[string]*email.EmailSender, error) {
+	shared, err := email.NewEmailSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, logger)
+	if err != nil {
+		return nil, err
+	}
+	senders := make(map[string]*email.EmailSender, len(cfg.Tenants))
+	for id, tenant := range cfg.Tenants {
+		sender := shared
+		if tenant.SMTP.Host != "" {
+			sender, err = email.NewEmailSender(tenant.SMTP.Host, tenant.SMTP.Port, tenant.SMTP.Username, tenant.SMTP.Password, logger.With(zap.String("tenant_id", id)))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if tenant.Sender != "" {
+			sender = sender.WithFrom(tenant.Sender)
+		}
+		senders[id] = sender
+	}
+	return senders, nil
+}
+
 func main() {
 
 	// initialize logger
@@ -102,38 +339,22 @@ func main() {
 
 	}
 
+	// The migrate subcommand manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:], logger); err != nil {
+			logger.Fatal("Migration failed", zap.Error(err))
+		}
+		return
+	}
+
+	// Cancelled on shutdown to stop background workers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Initialize metrics and server
 	metrics.InitMetrics()
 	metrics.StartMetricsServer()
 
-	// // Run database migrations
-	// m, err := migrate.New("file://migrations", cfg.DatabaseDSN)
-	// if err != nil {
-	// 	logger.Fatal("Failed to initialize migration", zap.Error(err))
-	// }
-
-	// // Check if the database is in a dirty state
-	// if _, _, databaseErr := m.Version(); databaseErr != nil {
-	// 	var migrateErr *migrate.ErrDirty
-	// 	if errors.As(databaseErr, &migrateErr) {
-	// 		logger.Error("Database is in a dirty state", zap.Error(databaseErr))
-	// 		// Force the migration version to the last successful version
-	// 		if err := m.Force(2025032401); err != nil {
-	// 			logger.Fatal("Failed to force migration version", zap.Error(err))
-	// 		}
-	// 		logger.Info("Forced migration version to 2025032401")
-	// 	} else {
-	// 		logger.Fatal("Failed to get migration version", zap.Error(databaseErr))
-	// 	}
-	// }
-
-	// // Apply migrations
-	// if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-	// 	logger.Error("Failed to apply migrations", zap.Error(err))
-	// } else {
-	// 	logger.Info("Database migrations applied")
-	// }
-
 	// Initialize database
 	db, err := database.NewDB(cfg.DatabaseDSN, logger)
 	if err != nil {
@@ -142,22 +363,49 @@ func main() {
 	}
 	defer db.Close()
 
+	// Refuse to run against a schema this build doesn't expect
+	if err := checkSchema(ctx, db, logger); err != nil {
+		logger.Fatal("Database schema is not up to date", zap.Error(err))
+	}
+
 	redisClient, err := redis.NewRedisClient(cfg.RedisAddr, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize redis", zap.Error(err))
 	}
 	defer redisClient.Close()
 
-	// Initialize Kafka producer
-	KafkaProducer, err := kafka.NewProducer(cfg.KafkaBrokers, logger)
+	// Initialize Kafka producer, batching for high-volume fan-out if asked
+	kafkaClient := toKafkaClient(cfg.KafkaClient)
+	var KafkaProducer interface {
+		service.KafkaProducer
+		Close() error
+	}
+	if cfg.KafkaProducer.Async {
+		KafkaProducer, err = kafka.NewAsyncProducer(cfg.KafkaBrokers, kafkaClient, kafka.BatchConfig{
+			Messages:  cfg.KafkaProducer.FlushMessages,
+			Bytes:     cfg.KafkaProducer.FlushBytes,
+			Frequency: cfg.KafkaProducer.FlushFrequency,
+		}, logger)
+	} else {
+		KafkaProducer, err = kafka.NewProducer(cfg.KafkaBrokers, kafkaClient, logger)
+	}
 	if err != nil {
 		logger.Fatal("Failed to initialize Kafka producer", zap.Error(err))
 	}
 
 	defer KafkaProducer.Close()
 
+	// Events are produced in versioned envelopes; the consumer reads every
+	// known format and version
+	eventCodec, err := kafka.NewEventCodec(kafka.EventFormat(cfg.KafkaEventFormat), "notification-service")
+	if err != nil {
+		logger.Fatal("Invalid Kafka event format", zap.Error(err))
+	}
+
+	tenants := toTenants(cfg.Tenants)
+
 	// initialize notification  senders
-	emailSender, err := email.NewEmailSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, logger)
+	emailSenders, err := newEmailSenders(cfg, logger)
 	// inAppSender := inapp.NewInAppSender(logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize sender", zap.Error(err))
@@ -165,30 +413,42 @@ func main() {
 
 	// implement all strategies here
 	strategies := map[domain.NotificationType]notification.SenderStrategy{
-		domain.EmailNotification: emailSender,
+		domain.EmailNotification: email.NewTenantSender(emailSenders),
 	}
-	notificationSender := notification.NewNotificationSender(strategies)
+	// Limits are shared across replicas through Redis, with a bounded
+	// per-process fallback while Redis is unavailable
+	rateLimits := ratelimit.NewPolicy(toRateLimits(cfg.RateLimits), func(limit ratelimit.Limit) ratelimit.Limiter {
+		fallback := ratelimit.NewRateLimiter(limit.Rate, limit.Burst, cfg.RateLimits.LocalCacheSize)
+		return ratelimit.NewRedisLimiter(redisClient.Client(), limit, fallback, logger)
+	})
+	notificationSender := notification.NewNotificationSender(strategies, rateLimits)
 
 	// initialize repository
-	repo := database.NewRepository(db, notificationSender, redisClient, logger)
+	repo := database.NewRepository(db, notificationSender, redisClient, cfg.SearchLanguage, logger)
 	notificationRepo := &NotificationRepository{repo: repo}
 
-	// Auto migrate database schema
-	if err := notificationRepo.AutoMigrate(); err != nil {
-		logger.Error("Failed to run migration", zap.Error(err))
-
+	// Partition maintenance and retention, on one replica at a time
+	retention := database.NewRetention(db, toRetention(cfg.Retention), logger)
+	retentionLeader, err := database.NewLeaderElector(db, "notification-retention", cfg.Retention.LeaderRetry, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize retention leader election", zap.Error(err))
 	}
+	go retentionLeader.Run(ctx, func(ctx context.Context) {
+		retention.RunEvery(ctx, cfg.Retention.Interval)
+	})
 
 	// Initialize Kafka consumer
-	consumer, err := kafka.NewConsumer(cfg.KafkaBrokers, cfg.ConsumerGroup, notificationRepo, notificationSender, logger, 5, 3)
+	consumer, err := kafka.NewConsumer(cfg.KafkaBrokers, cfg.ConsumerGroup, kafkaClient, toKafkaGroup(cfg.KafkaConsumer), toKafkaTopics(cfg.KafkaConsumer), notificationRepo, notificationSender, eventCodec, KafkaProducer, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize Kafka consumer", zap.Error(err))
 	}
 	defer consumer.Close()
 
 	// Start kafka consumers
+	consumerDone := make(chan struct{})
 	go func() {
-		if err := consumer.ConsumeNotifications(); err != nil {
+		defer close(consumerDone)
+		if err := consumer.ConsumeNotifications(ctx); err != nil {
 			logger.Fatal("Failed to consume email notifications", zap.Error(err))
 		}
 	}()
@@ -200,12 +460,91 @@ func main() {
 	// }()
 
 	// initialize services
-	notificationService := service.NewNotificationService(notificationRepo, logger, KafkaProducer)
+	localizer, err := i18n.NewLocalizer(template.FS)
+	if err != nil {
+		logger.Fatal("Failed to load email templates", zap.Error(err))
+	}
+	notificationService := service.NewNotificationService(notificationRepo, localizer, tenants, cfg.IdempotencyTTL, logger, KafkaProducer, eventCodec).
+		WithChannels(deliveredChannels(strategies, cfg.KafkaConsumer)...)
+	notificationService.StartUnreadReconciler(ctx, cfg.UnreadReconcileInterval)
+	notificationService.StartScheduler(ctx, cfg.SchedulerInterval)
 	// otpRepo := otp.NewOTPRepository(logger)
-	otpService := service.NewOTPService(notificationService, notificationRepo, logger)
+	otpHasher, err := otp.NewHasher(otp.HashAlgorithm(cfg.OTPHashAlgorithm), cfg.OTPPepper, cfg.OTPPreviousPepper, cfg.OTPPreviousPepperUntil)
+	if err != nil {
+		logger.Fatal("Failed to initialize OTP hasher", zap.Error(err))
+	}
+	magicLinks, err := otp.NewMagicLinkSigner(cfg.MagicLinkSecret, cfg.MagicLinkTTL)
+	if err != nil {
+		logger.Fatal("Failed to initialize magic link signer", zap.Error(err))
+	}
+	totpSecrets, err := otp.NewCipher(cfg.TOTPEncryptionKey, cfg.TOTPPreviousEncryptionKey)
+	if err != nil {
+		logger.Fatal("Failed to initialize TOTP secret encryption", zap.Error(err))
+	}
+	totp := otp.NewTOTP(cfg.TOTPIssuer, cfg.TOTPSkew)
+	otpService := service.NewOTPService(notificationService, notificationRepo, otpHasher, totp, totpSecrets, magicLinks, cfg.MagicLinkBaseURL, logger)
+
+	// Notification rules, applied to upstream domain events
+	ruleService := service.NewRuleService(notificationService, notificationRepo, logger)
+	eventsDone := make(chan struct{})
+	if len(cfg.RuleTopics) > 0 {
+		eventConsumer, err := kafka.NewEventConsumer(cfg.KafkaBrokers, cfg.RuleConsumerGroup, kafkaClient, toKafkaGroup(cfg.KafkaConsumer), cfg.RuleTopics, ruleService, KafkaProducer, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize Kafka event consumer", zap.Error(err))
+		}
+		defer eventConsumer.Close()
+		go func() {
+			defer close(eventsDone)
+			if err := eventConsumer.ConsumeEvents(ctx); err != nil {
+				logger.Fatal("Failed to consume events", zap.Error(err))
+			}
+		}()
+	} else {
+		close(eventsDone)
+	}
+
+	// Per-RPC abuse controls for endpoints that send to arbitrary addresses
+	quotaFallback := ratelimit.NewLocalQuotas(cfg.RateLimits.LocalCacheSize)
+	quotas := grpc.NewQuotas(toQuotaPolicies(cfg.RPCQuotas),
+		ratelimit.NewRedisQuotas(redisClient.Client(), quotaFallback, logger),
+		cfg.RPCQuotas.TrustForwardedFor, logger)
+
+	// Caller authentication, by JWT and/or mTLS client certificate
+	var tlsConfig *tls.Config
+	if cfg.Auth.TLS.CertFile != "" {
+		tlsConfig, err = auth.ServerTLSConfig(cfg.Auth.TLS.CertFile, cfg.Auth.TLS.KeyFile, cfg.Auth.TLS.ClientCAFile)
+		if err != nil {
+			logger.Fatal("Failed to load TLS config", zap.Error(err))
+		}
+	}
+	// Traced first, so even rejected calls answer with a correlation ID
+	interceptors := []ggrpc.UnaryServerInterceptor{grpc.TraceUnaryInterceptor()}
+	streamInterceptors := []ggrpc.StreamServerInterceptor{grpc.TraceStreamInterceptor()}
+	if !cfg.Auth.Disabled {
+		var verifier *auth.JWTVerifier
+		if cfg.Auth.JWT.JWKS != "" {
+			keys, err := auth.NewKeySet(ctx, cfg.Auth.JWT.JWKS, logger)
+			if err != nil {
+				logger.Fatal("Failed to load JWKS", zap.Error(err))
+			}
+			keys.StartRefresh(ctx, cfg.Auth.JWT.RefreshInterval)
+			verifier = auth.NewJWTVerifier(keys, cfg.Auth.JWT.Issuer, cfg.Auth.JWT.Audience, cfg.Auth.JWT.RolesClaim, cfg.Auth.JWT.TenantClaim)
+		}
+		mtls := tlsConfig != nil && cfg.Auth.TLS.ClientCAFile != ""
+		authn := grpc.NewAuth(verifier, mtls, cfg.Auth.AllowedServices, cfg.Auth.PublicMethods, logger)
+		// Authenticate first so anonymous callers cannot burn other users' quotas
+		interceptors = append(interceptors, authn.UnaryInterceptor())
+		streamInterceptors = append(streamInterceptors, authn.StreamInterceptor())
+	} else {
+		logger.Warn("Caller authentication is disabled")
+	}
+	// Resolved after authentication, which may bind the caller to a tenant
+	tenantResolver := grpc.NewTenantResolver(tenants, logger)
+	interceptors = append(interceptors, tenantResolver.UnaryInterceptor(), quotas.UnaryInterceptor())
+	streamInterceptors = append(streamInterceptors, tenantResolver.StreamInterceptor())
 
 	// Start grpc Server
-	grpcServer := grpc.NewServer(notificationService, otpService, logger)
+	grpcServer := grpc.NewServer(notificationService, otpService, ruleService, tlsConfig, logger, interceptors, streamInterceptors)
 
 	go func() {
 		if err := grpcServer.Start(":" + string(cfg.GRpcPort)); err != nil {
@@ -219,5 +558,9 @@ func main() {
 	<-sigChan
 
 	logger.Info("Shutting down...")
+	cancel()
 	grpcServer.Stop()
+	// Let the consumer finish in-flight notifications and commit them
+	<-consumerDone
+	<-eventsDone
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/config"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/database"
+	"go.uber.org/zap"
+)
+
+const migrateUsage = "usage: migrate up | down [N] | status | force VERSION"
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(cfg *config.Config, args []string, logger *zap.Logger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := database.NewDB(cfg.DatabaseDSN, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(context.Background(), db, logger)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q: %s", args[1], migrateUsage)
+			}
+		}
+		err = migrator.Down(steps)
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid version %q: %s", args[1], migrateUsage)
+		}
+		err = migrator.Force(version)
+	case "status":
+	default:
+		return fmt.Errorf("unknown command %q: %s", args[0], migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("version: %d\nlatest: %d\ndirty: %t\n", status.Version, status.Latest, status.Dirty)
+	return nil
+}
+
+// checkSchema fails unless the database is at the latest migration.
+func checkSchema(ctx context.Context, db *database.DB, logger *zap.Logger) error {
+	migrator, err := database.NewMigrator(ctx, db, logger)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+	return migrator.Check()
+}
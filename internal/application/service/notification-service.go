@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/Shafeeqth/notification-service/internal/domain"
@@ -11,109 +15,383 @@ import (
 )
 
 type NotificationService struct {
-	repo   domain.NotificationRepository
-	logger *zap.Logger
-	kafka  KafkaProducer
+	repo           domain.NotificationRepository
+	localizer      domain.Localizer
+	tenants        domain.Tenants
+	idempotencyTTL time.Duration
+	logger         *zap.Logger
+	kafka          KafkaProducer
+	encoder        NotificationEncoder
+	// channels, if set, are the only queued channels accepted
+	channels map[domain.NotificationType]bool
 }
 
+// KafkaProducer sends messages keyed for partitioning, with headers added
+// to the tenant and trace the producer takes from ctx.
 type KafkaProducer interface {
-	Produce(ctx context.Context, topic string, message []byte) error
+	Produce(ctx context.Context, topic, key string, message []byte, headers map[string]string) error
+	// ProduceAsync reports the delivery of the message to done, possibly
+	// before returning.
+	ProduceAsync(ctx context.Context, topic, key string, message []byte, headers map[string]string, done func(error))
 }
 
-func NewNotificationService(repo domain.NotificationRepository, logger *zap.Logger, kafka KafkaProducer) *NotificationService {
+// NotificationEncoder encodes a notification as a message, with the
+// headers consumers need to decode it.
+type NotificationEncoder interface {
+	EncodeNotification(notification domain.Notification) ([]byte, map[string]string, error)
+}
+
+// NewNotificationService creates the service. localizer and tenants render
+// templated emails in each recipient's language and tenant branding.
+// idempotencyTTL is how long a send's idempotency key is honoured.
+func NewNotificationService(repo domain.NotificationRepository, localizer domain.Localizer, tenants domain.Tenants, idempotencyTTL time.Duration, logger *zap.Logger, kafka KafkaProducer, encoder NotificationEncoder) *NotificationService {
 
-	return &NotificationService{repo: repo, logger: logger, kafka: kafka}
+	return &NotificationService{repo: repo, localizer: localizer, tenants: tenants, idempotencyTTL: idempotencyTTL, logger: logger, kafka: kafka, encoder: encoder}
 }
 
-// func (s *NotificationService) SendEmailNotification(ctx context.Context, userId, recipient, subject, body string) error {
-// 	notification := domain.Notification{
-// 		ID:        uuid.New().String(),
-// 		UserId:    userId,
-// 		Subject:   subject,
-// 		Body:      body,
-// 		Type:      domain.EmailNotification,
-// 		IsRead:    false,
-// 		CreatedAt: time.Now(),
-// 	}
+// WithChannels accepts only notifications of channels, those with a sender
+// whose topic is consumed, so nothing is queued that no consumer delivers.
+// Other channels fail with domain.ErrUnsupportedChannel. In-app
+// notifications are only stored, and always accepted.
+func (s *NotificationService) WithChannels(channels ...domain.NotificationType) *NotificationService {
+	s.channels = make(map[domain.NotificationType]bool, len(channels))
+	for _, channel := range channels {
+		s.channels[channel] = true
+	}
+	return s
+}
 
-// 	// save to database
-// 	if err := s.repo.SaveNotification(ctx, notification); err != nil {
-// 		s.logger.Error("Failed to save notification to db", zap.Error(err))
+// Supports reports whether notifications of channel are accepted.
+func (s *NotificationService) Supports(channel domain.NotificationType) bool {
+	return s.channels == nil || channel == domain.InAppNotification || s.channels[channel]
+}
 
-// 	}
+// prepare assigns a new notification its ID, tenant and creation time and
+// validates its channel and its payload for that channel.
+func (s *NotificationService) prepare(ctx context.Context, notification domain.Notification) (domain.Notification, error) {
+	if !s.Supports(notification.Type) {
+		s.logger.Warn("Notification channel is not configured",
+			zap.String("userId", notification.UserId),
+			zap.String("type", string(notification.Type)))
+		return domain.Notification{}, fmt.Errorf("%w: %s", domain.ErrUnsupportedChannel, notification.Type)
+	}
+	notification.ID = uuid.New().String()
+	notification.TenantId = domain.TenantFromContext(ctx)
+	notification.IsRead = false
+	notification.CreatedAt = time.Now()
+	if notification.Priority == "" {
+		notification.Priority = domain.PriorityNormal
+	}
+	// Only future times are scheduled; the scheduler would otherwise claim
+	// a notification that was already sent
+	if notification.SendAt != nil && !notification.SendAt.After(notification.CreatedAt) {
+		notification.SendAt = nil
+	}
+	notification.QueuedAt = nil
+	if err := notification.ValidatePayload(); err != nil {
+		s.logger.Warn("Invalid notification payload",
+			zap.String("userId", notification.UserId),
+			zap.String("type", string(notification.Type)),
+			zap.Error(err))
+		return domain.Notification{}, err
+	}
+	return notification, nil
+}
 
-// 	// Send to Kafka for asynchronous processing
-// 	msg, err := json.Marshal(notification)
-// 	if err != nil {
-// 		s.logger.Error("Failed to marshall email notification", zap.Error(err))
-// 		return err
-// 	}
+// SendInAppNotification stores a notification for the user's inbox. The
+// caller fills in the content and payload.
+func (s *NotificationService) SendInAppNotification(ctx context.Context, notification domain.Notification) error {
+	notification.Type = domain.InAppNotification
+	return s.SendNotification(ctx, notification)
+}
 
-// 	if err := s.kafka.Produce(ctx, string(util.EmailNotifications), msg); err != nil {
-// 		s.logger.Error("Failed to produce email notification to Kafka", zap.Error(err))
-// 		return err
-// 	}
-// 	s.logger.Info("email notification queued", zap.String("recipient", recipient))
-// 	return nil
-// }
+// SendNotification stores a notification and delivers it on the channel of
+// its type, immediately or at its SendAt. The caller fills in the content,
+// type and payload.
+func (s *NotificationService) SendNotification(ctx context.Context, notification domain.Notification) error {
+	notification, err := s.prepare(ctx, notification)
+	if err != nil {
+		return err
+	}
+	return s.dispatch(ctx, notification)
+}
 
-func (s *NotificationService) SendInAppNotification(ctx context.Context, userId, recipient, subject, body string, notifyType domain.NotificationType) error {
-	notification := domain.Notification{
-		ID:        uuid.New().String(),
-		UserId:    userId,
-		Subject:   subject,
-		Type:      notifyType,
-		Body:      body,
-		Recipient: recipient,
-		IsRead:    false,
-		CreatedAt: time.Now(),
+// Send fans one logical notification out to each of its channels and
+// returns the created notifications in channel order. Every channel's
+// content is rendered and validated before any is sent.
+//
+// With an idempotency key, a repeat of the request by the same caller
+// returns the notifications of the first without sending again; only their
+// ID, type and user are set.
+func (s *NotificationService) Send(ctx context.Context, req domain.SendRequest) ([]domain.Notification, error) {
+	if req.IdempotencyKey != "" {
+		return s.sendOnce(ctx, req)
 	}
+	return s.send(ctx, req)
+}
 
-	// save to database
-	if err := s.repo.SaveNotification(ctx, notification); err != nil {
-		s.logger.Error("Failed to save notification to db", zap.Error(err))
+// idempotencyLease is how long a key stays in progress before a retry may
+// assume the first request died and take the key over.
+const idempotencyLease = time.Minute
 
+func (s *NotificationService) sendOnce(ctx context.Context, req domain.SendRequest) ([]domain.Notification, error) {
+	hash, err := requestHash(req)
+	if err != nil {
+		s.logger.Error("Failed to hash send request", zap.Error(err))
+		return nil, err
+	}
+	var caller string
+	if principal, ok := domain.PrincipalFromContext(ctx); ok {
+		caller = principal.Subject
+	}
+	now := time.Now()
+	key := domain.IdempotencyKey{
+		Caller:      caller,
+		Key:         req.IdempotencyKey,
+		RequestHash: hash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.idempotencyTTL),
 	}
 
-	s.logger.Info("In-app notification queued", zap.String("recipient", userId))
-	return nil
+	existing, reserved, err := s.repo.ReserveIdempotencyKey(ctx, key, now.Add(-idempotencyLease))
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		switch {
+		case existing.RequestHash != hash:
+			return nil, domain.ErrIdempotencyKeyReused
+		case existing.CompletedAt == nil:
+			return nil, domain.ErrIdempotencyKeyInProgress
+		}
+		s.logger.Info("Replaying idempotent send",
+			zap.String("idempotency_key", req.IdempotencyKey),
+			zap.String("userId", req.Content.UserId))
+		notifications := make([]domain.Notification, len(existing.Notifications))
+		for i, ref := range existing.Notifications {
+			notifications[i] = domain.Notification{ID: ref.ID, TenantId: existing.TenantId, UserId: req.Content.UserId, Type: ref.Type}
+		}
+		return notifications, nil
+	}
+
+	notifications, sendErr := s.send(ctx, req)
+	if len(notifications) == 0 {
+		// Nothing was sent, so a retry may try again
+		if err := s.repo.ReleaseIdempotencyKey(ctx, key); err != nil {
+			s.logger.Error("Failed to release idempotency key", zap.String("idempotency_key", req.IdempotencyKey))
+		}
+		return nil, sendErr
+	}
 
+	// A partial send is recorded too: retrying must not repeat the
+	// channels that went out
+	key.Notifications = make([]domain.NotificationRef, len(notifications))
+	for i, n := range notifications {
+		key.Notifications[i] = domain.NotificationRef{ID: n.ID, Type: n.Type}
+	}
+	if err := s.repo.CompleteIdempotencyKey(ctx, key); err != nil {
+		// The notifications went out, so the send itself still succeeded
+		s.logger.Error("Failed to complete idempotency key", zap.String("idempotency_key", req.IdempotencyKey))
+	}
+	return notifications, sendErr
 }
-func (s *NotificationService) SendNotification(ctx context.Context, userId, recipient, subject, body string, notifyType domain.NotificationType) error {
-	notification := domain.Notification{
-		ID:        uuid.New().String(),
-		UserId:    uuid.New().String(),// userId,
-		Subject:   subject,
-		Type:      notifyType,
-		Body:      body,
-		Recipient: recipient,
-		IsRead:    false,
-		CreatedAt: time.Now(),
+
+// requestHash fingerprints a send request, excluding its idempotency key.
+func requestHash(req domain.SendRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (s *NotificationService) send(ctx context.Context, req domain.SendRequest) ([]domain.Notification, error) {
+	notifications := make([]domain.Notification, 0, len(req.Channels))
+	for _, channel := range req.Channels {
+		notification := req.Content
+		notification.Type = channel
+		if req.Template != "" {
+			message, err := s.localize(ctx, notification.UserId, req.Template, req.Locale, req.TemplateData, channel != domain.EmailNotification)
+			if err != nil {
+				return nil, err
+			}
+			notification.Subject, notification.Body = message.Subject, message.Body
+		}
+		notification, err := s.prepare(ctx, notification)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	for i, notification := range notifications {
+		if err := s.dispatch(ctx, notification); err != nil {
+			return notifications[:i], err
+		}
+	}
+	return notifications, nil
+}
+
+// dispatch stores a prepared notification and hands it to its channel. An
+// in-app or scheduled notification is its stored row, so failing to store
+// it fails the send; other channels still deliver without one.
+func (s *NotificationService) dispatch(ctx context.Context, notification domain.Notification) error {
+	if notification.Sensitive {
+		return s.deliverSensitive(ctx, notification)
 	}
+	scheduled := notification.SendAt != nil && notification.SendAt.After(time.Now())
 
 	// save to database
 	if err := s.repo.SaveNotification(ctx, notification); err != nil {
 		s.logger.Error("Failed to save notification to db", zap.Error(err))
+		if scheduled || notification.Type == domain.InAppNotification {
+			return err
+		}
+	}
+
+	switch {
+	case notification.Type == domain.InAppNotification:
+		s.logger.Info("In-app notification stored", zap.String("recipient", notification.UserId))
+		return nil
+	case scheduled:
+		s.logger.Info("Notification scheduled",
+			zap.String("notification_id", notification.ID),
+			zap.Time("send_at", *notification.SendAt))
+		return nil
+	}
+	return s.queue(ctx, notification)
+}
+
+// redactedBody replaces the body of a sensitive notification in its stored
+// row.
+const redactedBody = "[redacted]"
 
+// deliverSensitive emails a sensitive notification directly, so its secret
+// never reaches Kafka, and stores it with its body redacted, so the secret
+// is neither kept nor searchable.
+func (s *NotificationService) deliverSensitive(ctx context.Context, notification domain.Notification) error {
+	record := notification
+	record.Body = redactedBody
+	if err := s.repo.SaveNotification(ctx, record); err != nil {
+		s.logger.Error("Failed to save notification to db", zap.Error(err))
+	}
+
+	if err := s.repo.SendEmail(ctx, notification.Recipient, notification.Subject, notification.Body); err != nil {
+		s.logger.Error("Failed to send sensitive email",
+			zap.String("notification_id", notification.ID),
+			zap.Error(err))
+		return err
 	}
+	s.logger.Info("Sensitive email sent",
+		zap.String("notification_id", notification.ID),
+		zap.String("userId", notification.UserId))
+	return nil
+}
 
-	msg, err := json.Marshal(notification)
+// queue produces a notification to the topic of its type, keyed by user so
+// each user's notifications stay in order.
+func (s *NotificationService) queue(ctx context.Context, notification domain.Notification) error {
+	msg, headers, err := s.encoder.EncodeNotification(notification)
 	if err != nil {
-		s.logger.Error("Failed to marshall in-app notification", zap.Error(err))
+		s.logger.Error("Failed to encode notification", zap.Error(err))
 		return err
 	}
 
-	topic := notifyType + "-notifications"
-	if err := s.kafka.Produce(ctx, string(topic), msg); err != nil {
-		s.logger.Error("Failed to produce in-app notification to Kafka", zap.Error(err))
+	ctx = domain.ContextWithTenant(ctx, notification.TenantId)
+	if err := s.kafka.Produce(ctx, NotificationTopic(notification.Type), notification.UserId, msg, headers); err != nil {
+		s.logger.Error("Failed to produce notification to Kafka", zap.Error(err))
 		return domain.ErrKafkaProduce
 	}
-	s.logger.Info("Notification queued",
-		zap.String("recipient", recipient),
-		zap.String("userId", userId),
-		zap.String("type", string(notifyType)))
+	s.logQueued(notification)
 	return nil
+}
+
+// queueAsync is queue for batches: it returns once the notification is
+// buffered and reports the outcome to done.
+func (s *NotificationService) queueAsync(ctx context.Context, notification domain.Notification, done func(error)) {
+	msg, headers, err := s.encoder.EncodeNotification(notification)
+	if err != nil {
+		s.logger.Error("Failed to encode notification", zap.Error(err))
+		done(err)
+		return
+	}
+
+	ctx = domain.ContextWithTenant(ctx, notification.TenantId)
+	s.kafka.ProduceAsync(ctx, NotificationTopic(notification.Type), notification.UserId, msg, headers, func(err error) {
+		if err != nil {
+			s.logger.Error("Failed to produce notification to Kafka", zap.Error(err))
+			done(domain.ErrKafkaProduce)
+			return
+		}
+		s.logQueued(notification)
+		done(nil)
+	})
+}
 
+// NotificationTopic is the topic notifications of channel are queued on.
+func NotificationTopic(channel domain.NotificationType) string {
+	return string(channel) + "-notifications"
+}
+
+func (s *NotificationService) logQueued(notification domain.Notification) {
+	s.logger.Info("Notification queued",
+		zap.String("recipient", notification.Recipient),
+		zap.String("userId", notification.UserId),
+		zap.String("type", string(notification.Type)))
+}
+
+// scheduleBatchSize bounds each claim of due scheduled notifications.
+const scheduleBatchSize = 100
+
+// StartScheduler queues scheduled notifications as they fall due, checking
+// every interval until ctx is done. It is safe to run on every replica.
+func (s *NotificationService) StartScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.queueDue(ctx)
+			}
+		}
+	}()
+}
+
+func (s *NotificationService) queueDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := s.repo.ClaimScheduledNotifications(ctx, scheduleBatchSize)
+		if err != nil {
+			return
+		}
+		// The batch is produced at once and awaited, so a batching
+		// producer sends it in few requests
+		var wg sync.WaitGroup
+		for _, notification := range due {
+			// In-app notifications only had to become visible
+			if notification.Type == domain.InAppNotification {
+				continue
+			}
+			wg.Add(1)
+			s.queueAsync(ctx, notification, func(err error) {
+				defer wg.Done()
+				if err != nil {
+					// Released notifications are retried on the next tick
+					s.repo.ReleaseScheduledNotification(ctx, notification)
+				}
+			})
+		}
+		wg.Wait()
+		if len(due) < scheduleBatchSize {
+			return
+		}
+	}
 }
 
 func (s *NotificationService) GetANotification(ctx context.Context, notificationId, userId string) (*domain.Notification, error) {
@@ -128,17 +406,120 @@ func (s *NotificationService) GetANotification(ctx context.Context, notification
 	return notification, nil
 }
 
-func (s *NotificationService) GetAllNotifications(ctx context.Context, userId string, page, pageSize int, isRead *bool, notifyType *string) ([]domain.Notification, int64, error) {
-	notifications, total, err := s.repo.GetAllNotifications(ctx, userId, page, pageSize, isRead, notifyType)
+func (s *NotificationService) GetAllNotifications(ctx context.Context, userId string, filter domain.NotificationFilter, after *domain.PageCursor, pageSize int) ([]domain.Notification, *domain.PageCursor, int64, error) {
+	notifications, next, total, err := s.repo.GetAllNotifications(ctx, userId, filter, after, pageSize)
 	if err != nil {
 		s.logger.Error("Failed to get notifications from db", zap.String("userId", userId), zap.Error(err))
-		return nil, 0, err
+		return nil, nil, 0, err
 	}
-	return notifications, total, nil
+	return notifications, next, total, nil
+}
+
+func (s *NotificationService) SearchNotifications(ctx context.Context, userId string, query domain.SearchQuery, offset, limit int) ([]domain.SearchResult, bool, error) {
+	results, more, err := s.repo.SearchNotifications(ctx, userId, query, offset, limit)
+	if err != nil {
+		s.logger.Error("Failed to search notifications", zap.String("userId", userId), zap.Error(err))
+		return nil, false, err
+	}
+	return results, more, nil
 }
 
 func (s *NotificationService) SendEmailNotification(ctx context.Context, userId, recipient, subject, body string) error {
-	return s.SendNotification(ctx, userId, recipient, subject, body, domain.EmailNotification)
+	return s.SendNotification(ctx, domain.Notification{
+		UserId:    userId,
+		Recipient: recipient,
+		Subject:   subject,
+		Body:      body,
+		Type:      domain.EmailNotification,
+	})
+}
+
+// SendLocalizedEmail renders template name for the recipient and queues it
+// as an email. The language is locale if given, else the user's saved
+// preference, else the tenant's locale. time.Time values in vars are shown
+// in the user's time zone.
+func (s *NotificationService) SendLocalizedEmail(ctx context.Context, userId, recipient, name, locale string, vars map[string]interface{}) error {
+	message, err := s.localize(ctx, userId, name, locale, vars, false)
+	if err != nil {
+		return err
+	}
+	return s.SendEmailNotification(ctx, userId, recipient, message.Subject, message.Body)
+}
+
+// SendSensitiveEmail is SendLocalizedEmail for templates whose rendering
+// carries a secret, such as a one-time code or sign-in link. The email is
+// sent directly and its body is never stored or produced. It is stored in
+// domain.CategorySecurity.
+func (s *NotificationService) SendSensitiveEmail(ctx context.Context, userId, recipient, name, locale string, vars map[string]interface{}) error {
+	message, err := s.localize(ctx, userId, name, locale, vars, false)
+	if err != nil {
+		return err
+	}
+	return s.SendNotification(ctx, domain.Notification{
+		UserId:    userId,
+		Recipient: recipient,
+		Subject:   message.Subject,
+		Body:      message.Body,
+		Type:      domain.EmailNotification,
+		Category:  domain.CategorySecurity,
+		Sensitive: true,
+	})
+}
+
+// localize renders template name for a user as SendLocalizedEmail
+// describes, as plain text if text is set.
+func (s *NotificationService) localize(ctx context.Context, userId, name, locale string, vars map[string]interface{}, text bool) (domain.LocalizedMessage, error) {
+	tenant, err := s.tenants.Lookup(domain.TenantFromContext(ctx))
+	if err != nil {
+		s.logger.Error("Failed to resolve tenant", zap.Error(err))
+		return domain.LocalizedMessage{}, err
+	}
+
+	// Preferences only refine the email, so failing to load them must not
+	// stop it from being sent
+	preferences, err := s.repo.GetPreferences(ctx, userId)
+	if err != nil {
+		preferences = domain.UserPreferences{UserId: userId}
+	}
+
+	loc := preferences.Location()
+	args := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		if t, ok := v.(time.Time); ok {
+			v = t.In(loc)
+		}
+		args[k] = v
+	}
+
+	render := s.localizer.Render
+	if text {
+		render = s.localizer.RenderText
+	}
+	message, err := render(name, []string{locale, preferences.Locale, tenant.Locale}, tenant, args)
+	if err != nil {
+		s.logger.Error("Failed to render template", zap.String("template", name), zap.Error(err))
+		return domain.LocalizedMessage{}, err
+	}
+	return message, nil
+}
+
+func (s *NotificationService) GetPreferences(ctx context.Context, userId string) (domain.UserPreferences, error) {
+	preferences, err := s.repo.GetPreferences(ctx, userId)
+	if err != nil {
+		s.logger.Error("Failed to get preferences", zap.String("userId", userId), zap.Error(err))
+		return domain.UserPreferences{}, err
+	}
+	return preferences, nil
+}
+
+// UpdatePreferences replaces a user's preferences; empty fields clear them.
+func (s *NotificationService) UpdatePreferences(ctx context.Context, preferences domain.UserPreferences) error {
+	if err := s.repo.SavePreferences(ctx, preferences); err != nil {
+		s.logger.Error("Failed to save preferences", zap.String("userId", preferences.UserId), zap.Error(err))
+		return err
+	}
+	s.logger.Info("Preferences updated", zap.String("userId", preferences.UserId))
+	return nil
 }
 
 func (s *NotificationService) MarkAsRead(ctx context.Context, notificationId, userId string) error {
@@ -163,3 +544,129 @@ func (s *NotificationService) MarkAllAsRead(ctx context.Context, userId string)
 		zap.String("userId", userId))
 	return nil
 }
+
+func (s *NotificationService) DeleteNotifications(ctx context.Context, userId string, notificationIds []string) (int64, error) {
+	deleted, err := s.repo.DeleteNotifications(ctx, userId, notificationIds)
+	if err != nil {
+		s.logger.Error("Failed to delete notifications", zap.String("userId", userId), zap.Error(err))
+		return 0, err
+	}
+	s.logger.Info("Notifications deleted", zap.String("userId", userId), zap.Int64("count", deleted))
+	return deleted, nil
+}
+
+func (s *NotificationService) ArchiveNotifications(ctx context.Context, userId string, notificationIds []string, archived bool) (int64, error) {
+	updated, err := s.repo.ArchiveNotifications(ctx, userId, notificationIds, archived)
+	if err != nil {
+		s.logger.Error("Failed to archive notifications",
+			zap.String("userId", userId),
+			zap.Bool("archived", archived),
+			zap.Error(err))
+		return 0, err
+	}
+	s.logger.Info("Notifications archive state changed",
+		zap.String("userId", userId),
+		zap.Bool("archived", archived),
+		zap.Int64("count", updated))
+	return updated, nil
+}
+
+func (s *NotificationService) SnoozeNotifications(ctx context.Context, userId string, notificationIds []string, until time.Time) (int64, error) {
+	updated, err := s.repo.SnoozeNotifications(ctx, userId, notificationIds, until)
+	if err != nil {
+		s.logger.Error("Failed to snooze notifications", zap.String("userId", userId), zap.Error(err))
+		return 0, err
+	}
+	s.logger.Info("Notifications snoozed",
+		zap.String("userId", userId),
+		zap.Time("until", until),
+		zap.Int64("count", updated))
+	return updated, nil
+}
+
+func (s *NotificationService) PinNotifications(ctx context.Context, userId string, notificationIds []string, pinned bool) (int64, error) {
+	updated, err := s.repo.PinNotifications(ctx, userId, notificationIds, pinned)
+	if err != nil {
+		s.logger.Error("Failed to pin notifications",
+			zap.String("userId", userId),
+			zap.Bool("pinned", pinned),
+			zap.Error(err))
+		return 0, err
+	}
+	s.logger.Info("Notifications pin state changed",
+		zap.String("userId", userId),
+		zap.Bool("pinned", pinned),
+		zap.Int64("count", updated))
+	return updated, nil
+}
+
+func (s *NotificationService) GetUnreadCount(ctx context.Context, userId string) (domain.UnreadCount, error) {
+	count, err := s.repo.GetUnreadCount(ctx, userId)
+	if err != nil {
+		s.logger.Error("Failed to get unread count", zap.String("userId", userId), zap.Error(err))
+		return domain.UnreadCount{}, err
+	}
+	return count, nil
+}
+
+func (s *NotificationService) SubscribeUnreadCount(ctx context.Context, userId string) (<-chan domain.UnreadCount, error) {
+	// Watch before the first read so no change between the two is missed
+	changes, err := s.repo.WatchUnreadCount(ctx, userId)
+	if err != nil {
+		s.logger.Error("Failed to watch unread count", zap.String("userId", userId), zap.Error(err))
+		return nil, err
+	}
+	current, err := s.GetUnreadCount(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(chan domain.UnreadCount)
+	go func() {
+		defer close(counts)
+		last := current
+		select {
+		case counts <- current:
+		case <-ctx.Done():
+			return
+		}
+		for range changes {
+			count, err := s.GetUnreadCount(ctx, userId)
+			if err != nil {
+				continue
+			}
+			if count.Equal(last) {
+				continue
+			}
+			last = count
+			select {
+			case counts <- count:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return counts, nil
+}
+
+// StartUnreadReconciler recomputes cached unread counts from the database
+// every interval until ctx is done.
+func (s *NotificationService) StartUnreadReconciler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.repo.ReconcileUnreadCounts(ctx); err != nil && ctx.Err() == nil {
+					s.logger.Error("Failed to reconcile unread counts", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+func TestPrepareChannels(t *testing.T) {
+	content := domain.Notification{
+		UserId:    "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f",
+		Recipient: "ann@example.com",
+		Subject:   "Welcome",
+		Body:      "Hello Ann",
+	}
+	all := NewNotificationService(nil, nil, nil, 0, zap.NewNop(), nil, nil)
+	emailOnly := NewNotificationService(nil, nil, nil, 0, zap.NewNop(), nil, nil).WithChannels(domain.EmailNotification)
+
+	tests := []struct {
+		name    string
+		service *NotificationService
+		channel domain.NotificationType
+		want    error
+	}{
+		{"unrestricted push", all, domain.PushNotification, nil},
+		{"configured email", emailOnly, domain.EmailNotification, nil},
+		{"in-app is stored", emailOnly, domain.InAppNotification, nil},
+		{"unconfigured push", emailOnly, domain.PushNotification, domain.ErrUnsupportedChannel},
+		{"unconfigured otp", emailOnly, domain.OTPNotification, domain.ErrUnsupportedChannel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notification := content
+			notification.Type = tt.channel
+			_, err := tt.service.prepare(context.Background(), notification)
+			if tt.want == nil && err != nil {
+				t.Fatalf("prepare() = %v, want no error", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("prepare() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationTopic(t *testing.T) {
+	if got := NotificationTopic(domain.EmailNotification); got != "email-notifications" {
+		t.Errorf("NotificationTopic(email) = %q", got)
+	}
+}
+
+// unreadRepo serves a settable unread count and signals its changes.
+type unreadRepo struct {
+	domain.NotificationRepository
+
+	mu      sync.Mutex
+	count   domain.UnreadCount
+	changes chan struct{}
+}
+
+func (r *unreadRepo) GetUnreadCount(context.Context, string) (domain.UnreadCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count, nil
+}
+
+func (r *unreadRepo) WatchUnreadCount(context.Context, string) (<-chan struct{}, error) {
+	return r.changes, nil
+}
+
+func (r *unreadRepo) set(total int64) {
+	r.mu.Lock()
+	r.count = domain.UnreadCount{Total: total}
+	r.mu.Unlock()
+	r.changes <- struct{}{}
+}
+
+func TestSubscribeUnreadCount(t *testing.T) {
+	repo := &unreadRepo{count: domain.UnreadCount{Total: 2}, changes: make(chan struct{}, 1)}
+	s := NewNotificationService(repo, nil, nil, 0, zap.NewNop(), nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	counts, err := s.SubscribeUnreadCount(ctx, "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f")
+	if err != nil {
+		t.Fatalf("SubscribeUnreadCount: %v", err)
+	}
+	next := func() domain.UnreadCount {
+		t.Helper()
+		select {
+		case count := <-counts:
+			return count
+		case <-time.After(time.Second):
+			t.Fatal("no count received")
+			return domain.UnreadCount{}
+		}
+	}
+
+	if count := next(); count.Total != 2 {
+		t.Errorf("first count = %d, want the current 2", count.Total)
+	}
+	repo.set(3)
+	if count := next(); count.Total != 3 {
+		t.Errorf("count after a change = %d, want 3", count.Total)
+	}
+	// A signal without a new count is not repeated to the subscriber
+	repo.set(3)
+	repo.set(0)
+	if count := next(); count.Total != 0 {
+		t.Errorf("count after all read = %d, want 0", count.Total)
+	}
+
+	close(repo.changes)
+	if _, ok := <-counts; ok {
+		t.Error("counts not closed when the watch ended")
+	}
+}
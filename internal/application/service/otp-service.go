@@ -2,9 +2,8 @@ package service
 
 import (
 	"context"
-	_ "fmt"
-	"os"
-	"strings"
+	"errors"
+	"net/url"
 	"time"
 
 	"github.com/Shafeeqth/notification-service/internal/domain"
@@ -14,20 +13,38 @@ import (
 type OTPService struct {
 	notificationService *NotificationService
 	otpRepo             domain.OTPRepository
+	hasher              domain.OTPHasher
+	totp                domain.TOTPGenerator
+	secrets             domain.SecretCipher
+	magicLinks          domain.MagicLinkSigner
+	magicLinkBaseURL    string
 	logger              *zap.Logger
 }
 
-func NewOTPService(notificationService *NotificationService, otpRepo domain.OTPRepository, logger *zap.Logger) *OTPService {
+func NewOTPService(notificationService *NotificationService, otpRepo domain.OTPRepository, hasher domain.OTPHasher, totp domain.TOTPGenerator, secrets domain.SecretCipher, magicLinks domain.MagicLinkSigner, magicLinkBaseURL string, logger *zap.Logger) *OTPService {
 	return &OTPService{
 		notificationService: notificationService,
 		otpRepo:             otpRepo,
+		hasher:              hasher,
+		totp:                totp,
+		secrets:             secrets,
+		magicLinks:          magicLinks,
+		magicLinkBaseURL:    magicLinkBaseURL,
 		logger:              logger,
 	}
 
 }
 
-func (s *OTPService) SendOTP(ctx context.Context, userId, email, username string) (string, error) {
+// otpTTL is how long an emailed OTP stays valid.
+const otpTTL = 10 * time.Minute
 
+// maxOTPFailures is how many wrong codes an OTP tolerates before it is
+// revoked, so it cannot be guessed within its TTL.
+const maxOTPFailures = 5
+
+// SendOTP emails a verification code in the given locale, or the user's
+// preferred one if empty.
+func (s *OTPService) SendOTP(ctx context.Context, userId, email, username, locale string) (string, error) {
 	// Generate OTP
 	code, err := domain.GenerateOTP()
 	if err != nil {
@@ -35,12 +52,21 @@ func (s *OTPService) SendOTP(ctx context.Context, userId, email, username string
 		return "", err
 	}
 
+	// Only the peppered hash of the code is persisted
+	codeHash, err := s.hasher.Hash(email, code)
+	if err != nil {
+		s.logger.Error("Failed to hash OTP", zap.Error(err))
+		return "", err
+	}
+
 	// Save OTP with expiration
 	otp := domain.OTP{
 		Code:      code,
+		CodeHash:  codeHash,
 		UserId:    userId,
+		TenantId:  domain.TenantFromContext(ctx),
 		Email:     email,
-		ExpiresAt: time.Now().Add(10 * time.Minute), // 10 minutes expiration time
+		ExpiresAt: time.Now().Add(otpTTL),
 	}
 
 	if err := s.otpRepo.SaveOTP(ctx, otp); err != nil {
@@ -48,23 +74,13 @@ func (s *OTPService) SendOTP(ctx context.Context, userId, email, username string
 		return "", err
 	}
 
-	subject := "Your OTP for Email Verification"
-	// Load HTML template from file
-	templatePath := ".\\internal\\shared\\template\\activation-mail.html"
-	htmlTemplate, err := os.ReadFile(templatePath)
-	if err != nil {
-		s.logger.Error("Failed to read OTP HTML template", zap.Error(err))
-		return "", err
+	vars := map[string]interface{}{
+		"user_name":  username,
+		"code":       code,
+		"minutes":    int(otpTTL / time.Minute),
+		"expires_at": otp.ExpiresAt,
 	}
-
-	// Replace placeholders in the template with actual values
-	body := string(htmlTemplate)
-	body = strings.ReplaceAll(body, "{{USER_NAME}}", username)
-	body = strings.ReplaceAll(body, "{{CODE}}", code)
-	body = strings.ReplaceAll(body, "{{EXPIRY_TIME}}", "10 minutes") // Replace with the expiration time
-
-	// Ensure the body contains valid HTML content
-	if err := s.notificationService.SendEmailNotification(ctx, userId, email, subject, body); err != nil {
+	if err := s.notificationService.SendSensitiveEmail(ctx, userId, email, "activation-mail", locale, vars); err != nil {
 		s.logger.Error("Failed to send OTP email", zap.Error(err))
 		return "", err
 	}
@@ -73,6 +89,9 @@ func (s *OTPService) SendOTP(ctx context.Context, userId, email, username string
 	return code, nil
 }
 
+// VerifyOTP checks an emailed code. A matching code is consumed, so it is
+// accepted once; after maxOTPFailures wrong codes the OTP is revoked and
+// ErrOTPAttemptsExceeded returned.
 func (s *OTPService) VerifyOTP(ctx context.Context, userId, email, otp string) (bool, error) {
 	// Retrieve OTP from repository
 	storedOTP, err := s.otpRepo.GetOTP(ctx, email)
@@ -82,8 +101,40 @@ func (s *OTPService) VerifyOTP(ctx context.Context, userId, email, otp string) (
 	}
 
 	// Check if OTP matches and is not expired
-	if storedOTP.Code != otp || time.Now().After(storedOTP.ExpiresAt) {
-		s.logger.Warn("Invalid or expired OTP", zap.String("user_id", userId), zap.String("email", email))
+	matched, err := s.hasher.Verify(email, otp, storedOTP.CodeHash)
+	if err != nil {
+		s.logger.Error("Failed to verify OTP hash", zap.Error(err))
+		return false, err
+	}
+	if time.Now().After(storedOTP.ExpiresAt) {
+		s.logger.Warn("Expired OTP", zap.String("user_id", userId), zap.String("email", email))
+		return false, nil
+	}
+	if !matched {
+		failures, err := s.otpRepo.RecordOTPFailure(ctx, email, storedOTP.ExpiresAt)
+		if err != nil {
+			s.logger.Error("Failed to record OTP failure", zap.Error(err))
+			return false, err
+		}
+		s.logger.Warn("Invalid OTP", zap.String("user_id", userId), zap.String("email", email), zap.Int64("failures", failures))
+		if failures >= maxOTPFailures {
+			if _, err := s.otpRepo.ConsumeOTP(ctx, email); err != nil {
+				s.logger.Error("Failed to revoke OTP", zap.Error(err))
+				return false, err
+			}
+			return false, domain.ErrOTPAttemptsExceeded
+		}
+		return false, nil
+	}
+
+	// Only one of concurrent verifications of the code succeeds
+	consumed, err := s.otpRepo.ConsumeOTP(ctx, email)
+	if err != nil {
+		s.logger.Error("Failed to consume OTP", zap.Error(err))
+		return false, err
+	}
+	if !consumed {
+		s.logger.Warn("OTP already used", zap.String("user_id", userId), zap.String("email", email))
 		return false, nil
 	}
 
@@ -91,3 +142,135 @@ func (s *OTPService) VerifyOTP(ctx context.Context, userId, email, otp string) (
 	s.logger.Info("OTP verified successfully", zap.String("user_id", userId), zap.String("email", email))
 	return true, nil
 }
+
+// EnrollTOTP starts (or restarts) authenticator-app enrollment for a user and
+// returns the base32 secret with its otpauth:// provisioning URI. A confirmed
+// enrollment cannot be overwritten.
+func (s *OTPService) EnrollTOTP(ctx context.Context, userId, accountName string) (string, string, error) {
+	existing, err := s.otpRepo.GetTOTPEnrollment(ctx, userId)
+	if err != nil && !errors.Is(err, domain.ErrTOTPNotEnrolled) {
+		s.logger.Error("Failed to load TOTP enrollment", zap.String("user_id", userId), zap.Error(err))
+		return "", "", err
+	}
+	if err == nil && existing.Confirmed {
+		s.logger.Warn("TOTP already enrolled", zap.String("user_id", userId))
+		return "", "", domain.ErrTOTPEnrolled
+	}
+
+	secret, err := s.totp.GenerateSecret()
+	if err != nil {
+		s.logger.Error("Failed to generate TOTP secret", zap.Error(err))
+		return "", "", err
+	}
+	sealed, err := s.secrets.Encrypt(userId, secret)
+	if err != nil {
+		s.logger.Error("Failed to encrypt TOTP secret", zap.Error(err))
+		return "", "", err
+	}
+	enrollment := domain.TOTPEnrollment{
+		UserId: userId,
+		Secret: sealed,
+	}
+	if err := s.otpRepo.SaveTOTPEnrollment(ctx, enrollment); err != nil {
+		s.logger.Error("Failed to save TOTP enrollment", zap.String("user_id", userId), zap.Error(err))
+		return "", "", err
+	}
+
+	s.logger.Info("TOTP enrollment started", zap.String("user_id", userId))
+	return secret, s.totp.ProvisioningURI(secret, accountName), nil
+}
+
+// VerifyTOTP checks an authenticator code. The first successful verification
+// confirms a pending enrollment; a code is never accepted twice.
+func (s *OTPService) VerifyTOTP(ctx context.Context, userId, code string) (bool, error) {
+	enrollment, err := s.otpRepo.GetTOTPEnrollment(ctx, userId)
+	if err != nil {
+		s.logger.Error("Failed to load TOTP enrollment", zap.String("user_id", userId), zap.Error(err))
+		return false, err
+	}
+
+	secret, err := s.secrets.Decrypt(userId, enrollment.Secret)
+	if err != nil {
+		s.logger.Error("Failed to decrypt TOTP secret", zap.String("user_id", userId), zap.Error(err))
+		return false, err
+	}
+	step, ok, err := s.totp.Validate(secret, code, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to validate TOTP code", zap.String("user_id", userId), zap.Error(err))
+		return false, err
+	}
+	if !ok {
+		s.logger.Warn("Invalid TOTP code", zap.String("user_id", userId))
+		return false, nil
+	}
+
+	fresh, err := s.otpRepo.UseTOTPStep(ctx, userId, step)
+	if err != nil {
+		return false, err
+	}
+	if !fresh {
+		s.logger.Warn("TOTP code replayed", zap.String("user_id", userId))
+		return false, nil
+	}
+
+	s.logger.Info("TOTP verified successfully", zap.String("user_id", userId))
+	return true, nil
+}
+
+// SendMagicLink emails a signed, single-use passwordless login link in the
+// given locale, or the user's preferred one if empty.
+func (s *OTPService) SendMagicLink(ctx context.Context, userId, email, locale string) error {
+	token, claims, err := s.magicLinks.Issue(domain.TenantFromContext(ctx), userId, email)
+	if err != nil {
+		s.logger.Error("Failed to issue magic link", zap.Error(err))
+		return err
+	}
+	if err := s.otpRepo.SaveMagicLink(ctx, claims.ID, time.Until(claims.ExpiresAt)); err != nil {
+		s.logger.Error("Failed to save magic link", zap.Error(err))
+		return err
+	}
+
+	vars := map[string]interface{}{
+		"link":       s.magicLinkBaseURL + "?token=" + url.QueryEscape(token),
+		"expires_at": claims.ExpiresAt,
+	}
+	if err := s.notificationService.SendSensitiveEmail(ctx, userId, email, "magic-link", locale, vars); err != nil {
+		s.logger.Error("Failed to send magic link email", zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Magic link sent", zap.String("email", email), zap.String("userId", userId))
+	return nil
+}
+
+// ConsumeMagicLink verifies and redeems a magic-link token, returning the
+// claims it was issued for.
+func (s *OTPService) ConsumeMagicLink(ctx context.Context, token string) (domain.MagicLinkClaims, error) {
+	claims, err := s.magicLinks.Parse(token)
+	if err != nil {
+		s.logger.Warn("Invalid magic link", zap.Error(err))
+		return domain.MagicLinkClaims{}, err
+	}
+	// Tokens issued before multi-tenancy carry no tenant
+	issuedTo := claims.TenantId
+	if issuedTo == "" {
+		issuedTo = domain.DefaultTenant
+	}
+	if issuedTo != domain.TenantFromContext(ctx) {
+		s.logger.Warn("Magic link used in another tenant", zap.String("user_id", claims.UserId))
+		return domain.MagicLinkClaims{}, domain.ErrMagicLinkInvalid
+	}
+
+	redeemed, err := s.otpRepo.ConsumeMagicLink(ctx, claims.ID)
+	if err != nil {
+		s.logger.Error("Failed to consume magic link", zap.Error(err))
+		return domain.MagicLinkClaims{}, err
+	}
+	if !redeemed {
+		s.logger.Warn("Magic link already used", zap.String("user_id", claims.UserId))
+		return domain.MagicLinkClaims{}, domain.ErrMagicLinkUsed
+	}
+
+	s.logger.Info("Magic link consumed", zap.String("user_id", claims.UserId))
+	return claims, nil
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+const testUserId = "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f"
+
+// memOTPRepo is an in-memory OTPRepository with the single-use semantics
+// of the Redis and Postgres stores.
+type memOTPRepo struct {
+	domain.OTPRepository
+
+	mu          sync.Mutex
+	otps        map[string]domain.OTP
+	failures    map[string]int64
+	enrollments map[string]domain.TOTPEnrollment
+	magicLinks  map[string]bool
+}
+
+func newMemOTPRepo() *memOTPRepo {
+	return &memOTPRepo{
+		otps:        make(map[string]domain.OTP),
+		failures:    make(map[string]int64),
+		enrollments: make(map[string]domain.TOTPEnrollment),
+		magicLinks:  make(map[string]bool),
+	}
+}
+
+func (r *memOTPRepo) SaveOTP(_ context.Context, otp domain.OTP) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.otps[otp.Email] = otp
+	delete(r.failures, otp.Email)
+	return nil
+}
+
+func (r *memOTPRepo) GetOTP(_ context.Context, email string) (domain.OTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	otp, ok := r.otps[email]
+	if !ok {
+		return domain.OTP{}, domain.ErrOTPNotFound
+	}
+	return otp, nil
+}
+
+func (r *memOTPRepo) ConsumeOTP(_ context.Context, email string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.otps[email]
+	delete(r.otps, email)
+	delete(r.failures, email)
+	return ok, nil
+}
+
+func (r *memOTPRepo) RecordOTPFailure(_ context.Context, email string, _ time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[email]++
+	return r.failures[email], nil
+}
+
+func (r *memOTPRepo) SaveTOTPEnrollment(_ context.Context, enrollment domain.TOTPEnrollment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enrollments[enrollment.UserId] = enrollment
+	return nil
+}
+
+func (r *memOTPRepo) GetTOTPEnrollment(_ context.Context, userId string) (domain.TOTPEnrollment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enrollment, ok := r.enrollments[userId]
+	if !ok {
+		return domain.TOTPEnrollment{}, domain.ErrTOTPNotEnrolled
+	}
+	return enrollment, nil
+}
+
+func (r *memOTPRepo) UseTOTPStep(_ context.Context, userId string, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enrollment, ok := r.enrollments[userId]
+	if !ok || enrollment.LastUsedStep >= step {
+		return false, nil
+	}
+	enrollment.LastUsedStep = step
+	enrollment.Confirmed = true
+	r.enrollments[userId] = enrollment
+	return true, nil
+}
+
+func (r *memOTPRepo) SaveMagicLink(_ context.Context, tokenId string, _ time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.magicLinks[tokenId] = true
+	return nil
+}
+
+func (r *memOTPRepo) ConsumeMagicLink(_ context.Context, tokenId string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	redeemed := r.magicLinks[tokenId]
+	delete(r.magicLinks, tokenId)
+	return redeemed, nil
+}
+
+// plainHasher "hashes" a code by prefixing its subject.
+type plainHasher struct{}
+
+func (plainHasher) Hash(subject, code string) (string, error) {
+	return subject + "$" + code, nil
+}
+
+func (plainHasher) Verify(subject, code, encoded string) (bool, error) {
+	return encoded == subject+"$"+code, nil
+}
+
+// stepTOTP accepts the codes it knows, each for its time step.
+type stepTOTP struct {
+	secret string
+	steps  map[string]int64
+}
+
+func (t stepTOTP) GenerateSecret() (string, error) { return t.secret, nil }
+
+func (t stepTOTP) ProvisioningURI(secret, accountName string) string {
+	return "otpauth://totp/" + accountName + "?secret=" + secret
+}
+
+func (t stepTOTP) Validate(secret, code string, _ time.Time) (int64, bool, error) {
+	if secret != t.secret {
+		return 0, false, errors.New("wrong secret")
+	}
+	step, ok := t.steps[code]
+	return step, ok, nil
+}
+
+// subjectCipher "encrypts" by prefixing the subject, so a secret read
+// back for another subject fails.
+type subjectCipher struct{}
+
+func (subjectCipher) Encrypt(subject, plaintext string) (string, error) {
+	return "sealed:" + subject + ":" + plaintext, nil
+}
+
+func (subjectCipher) Decrypt(subject, encoded string) (string, error) {
+	plaintext, ok := strings.CutPrefix(encoded, "sealed:"+subject+":")
+	if !ok {
+		return "", domain.ErrSecretFormat
+	}
+	return plaintext, nil
+}
+
+// tokenSigner parses the tokens it was given claims for.
+type tokenSigner map[string]domain.MagicLinkClaims
+
+func (s tokenSigner) Issue(string, string, string) (string, domain.MagicLinkClaims, error) {
+	return "", domain.MagicLinkClaims{}, errors.New("not used")
+}
+
+func (s tokenSigner) Parse(token string) (domain.MagicLinkClaims, error) {
+	claims, ok := s[token]
+	if !ok {
+		return domain.MagicLinkClaims{}, domain.ErrMagicLinkInvalid
+	}
+	return claims, nil
+}
+
+func TestVerifyOTPRevokesAfterFailures(t *testing.T) {
+	const email = "ann@example.com"
+	otp := domain.OTP{UserId: testUserId, Email: email, CodeHash: email + "$123456", ExpiresAt: time.Now().Add(otpTTL)}
+
+	tests := []struct {
+		name     string
+		failures int // Wrong codes before the right one
+		want     bool
+		wantErr  error
+	}{
+		{"right code", 0, true, nil},
+		{"right code after failures", maxOTPFailures - 1, true, nil},
+		{"revoked", maxOTPFailures, false, domain.ErrOTPNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemOTPRepo()
+			repo.otps[email] = otp
+			s := NewOTPService(nil, repo, plainHasher{}, nil, nil, nil, "", zap.NewNop())
+			ctx := context.Background()
+
+			for i := 1; i <= tt.failures; i++ {
+				ok, err := s.VerifyOTP(ctx, testUserId, email, "000000")
+				if ok {
+					t.Fatalf("wrong code %d verified", i)
+				}
+				if i < maxOTPFailures && err != nil {
+					t.Fatalf("wrong code %d: VerifyOTP() = %v, want no error", i, err)
+				}
+				if i == maxOTPFailures && !errors.Is(err, domain.ErrOTPAttemptsExceeded) {
+					t.Fatalf("wrong code %d: VerifyOTP() = %v, want %v", i, err, domain.ErrOTPAttemptsExceeded)
+				}
+			}
+
+			ok, err := s.VerifyOTP(ctx, testUserId, email, "123456")
+			if ok != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyOTP() = %v, %v, want %v, %v", ok, err, tt.want, tt.wantErr)
+			}
+			if _, stored := repo.otps[email]; stored {
+				t.Error("OTP still stored after it was used or revoked")
+			}
+			if ok, _ := s.VerifyOTP(ctx, testUserId, email, "123456"); ok {
+				t.Error("OTP verified twice")
+			}
+		})
+	}
+}
+
+func TestVerifyOTPExpired(t *testing.T) {
+	const email = "ann@example.com"
+	repo := newMemOTPRepo()
+	repo.otps[email] = domain.OTP{UserId: testUserId, Email: email, CodeHash: email + "$123456", ExpiresAt: time.Now().Add(-time.Second)}
+	s := NewOTPService(nil, repo, plainHasher{}, nil, nil, nil, "", zap.NewNop())
+
+	if ok, err := s.VerifyOTP(context.Background(), testUserId, email, "123456"); ok || err != nil {
+		t.Errorf("VerifyOTP() = %v, %v, want false", ok, err)
+	}
+}
+
+func TestVerifyTOTPRejectsReplay(t *testing.T) {
+	repo := newMemOTPRepo()
+	totp := stepTOTP{secret: "JBSWY3DPEHPK3PXP", steps: map[string]int64{"111111": 100, "222222": 99, "333333": 101}}
+	s := NewOTPService(nil, repo, nil, totp, subjectCipher{}, nil, "", zap.NewNop())
+	ctx := context.Background()
+
+	secret, _, err := s.EnrollTOTP(ctx, testUserId, "ann@example.com")
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	if stored := repo.enrollments[testUserId].Secret; stored == secret {
+		t.Errorf("stored secret = %q, want it encrypted", stored)
+	}
+
+	steps := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"first code", "111111", true},
+		{"same code again", "111111", false},
+		{"earlier code", "222222", false},
+		{"unknown code", "999999", false},
+		{"later code", "333333", true},
+	}
+	for _, step := range steps {
+		ok, err := s.VerifyTOTP(ctx, testUserId, step.code)
+		if err != nil {
+			t.Fatalf("%s: VerifyTOTP: %v", step.name, err)
+		}
+		if ok != step.want {
+			t.Errorf("%s: VerifyTOTP() = %v, want %v", step.name, ok, step.want)
+		}
+	}
+	if !repo.enrollments[testUserId].Confirmed {
+		t.Error("enrollment not confirmed by a verified code")
+	}
+	if _, _, err := s.EnrollTOTP(ctx, testUserId, "ann@example.com"); !errors.Is(err, domain.ErrTOTPEnrolled) {
+		t.Errorf("EnrollTOTP() after confirmation = %v, want %v", err, domain.ErrTOTPEnrolled)
+	}
+}
+
+func TestVerifyTOTPSecretOfAnotherUser(t *testing.T) {
+	repo := newMemOTPRepo()
+	totp := stepTOTP{secret: "JBSWY3DPEHPK3PXP", steps: map[string]int64{"111111": 100}}
+	s := NewOTPService(nil, repo, nil, totp, subjectCipher{}, nil, "", zap.NewNop())
+	ctx := context.Background()
+	if _, _, err := s.EnrollTOTP(ctx, testUserId, "ann@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A secret copied onto another user's row does not decrypt for them
+	const other = "9b2c7a10-5d4e-4f3a-8b1c-2d3e4f5a6b7c"
+	copied := repo.enrollments[testUserId]
+	copied.UserId = other
+	repo.enrollments[other] = copied
+	if ok, err := s.VerifyTOTP(ctx, other, "111111"); ok || err == nil {
+		t.Errorf("VerifyTOTP() = %v, %v, want an error", ok, err)
+	}
+}
+
+func TestConsumeMagicLinkOnce(t *testing.T) {
+	claims := domain.MagicLinkClaims{ID: "link-1", TenantId: "acme", UserId: testUserId, Email: "ann@example.com"}
+	signer := tokenSigner{
+		"token":       claims,
+		"unsaved":     {ID: "link-2", TenantId: "acme", UserId: testUserId},
+		"other":       {ID: "link-3", TenantId: "globex", UserId: testUserId},
+		"pre-tenancy": {ID: "link-4", UserId: testUserId},
+	}
+	repo := newMemOTPRepo()
+	for _, c := range signer {
+		if c.ID != "link-2" {
+			repo.magicLinks[c.ID] = true
+		}
+	}
+	s := NewOTPService(nil, repo, nil, nil, nil, signer, "", zap.NewNop())
+	acme := domain.ContextWithTenant(context.Background(), "acme")
+
+	steps := []struct {
+		name  string
+		ctx   context.Context
+		token string
+		want  error
+	}{
+		{"first use", acme, "token", nil},
+		{"second use", acme, "token", domain.ErrMagicLinkUsed},
+		{"not saved", acme, "unsaved", domain.ErrMagicLinkUsed},
+		{"forged", acme, "forged", domain.ErrMagicLinkInvalid},
+		{"another tenant", acme, "other", domain.ErrMagicLinkInvalid},
+		{"issued before tenants", context.Background(), "pre-tenancy", nil},
+	}
+	for _, step := range steps {
+		got, err := s.ConsumeMagicLink(step.ctx, step.token)
+		if !errors.Is(err, step.want) || (step.want == nil && err != nil) {
+			t.Errorf("%s: ConsumeMagicLink() = %v, want %v", step.name, err, step.want)
+		}
+		if step.token == "token" && err == nil && got != claims {
+			t.Errorf("%s: claims = %+v, want %+v", step.name, got, claims)
+		}
+	}
+	// A link used in the wrong tenant stays redeemable in its own
+	if !repo.magicLinks["link-3"] {
+		t.Error("a link presented in another tenant was consumed")
+	}
+}
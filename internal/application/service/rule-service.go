@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// RuleService manages notification rules and applies them to upstream
+// domain events.
+type RuleService struct {
+	notificationService *NotificationService
+	rules               domain.RuleStore
+	logger              *zap.Logger
+}
+
+func NewRuleService(notificationService *NotificationService, rules domain.RuleStore, logger *zap.Logger) *RuleService {
+	return &RuleService{
+		notificationService: notificationService,
+		rules:               rules,
+		logger:              logger,
+	}
+}
+
+// defaultUserField is where rules find the user to notify unless told
+// otherwise.
+const defaultUserField = "user_id"
+
+// checkChannels rejects a rule sending on a channel the notification
+// service does not accept, since every event it matched would fail.
+func (s *RuleService) checkChannels(rule domain.NotificationRule) error {
+	for _, channel := range rule.Channels {
+		if !s.notificationService.Supports(channel) {
+			return fmt.Errorf("%w: %s", domain.ErrUnsupportedChannel, channel)
+		}
+	}
+	return nil
+}
+
+func (s *RuleService) CreateRule(ctx context.Context, rule domain.NotificationRule) (domain.NotificationRule, error) {
+	if err := s.checkChannels(rule); err != nil {
+		return domain.NotificationRule{}, err
+	}
+	if rule.UserField == "" {
+		rule.UserField = defaultUserField
+	}
+	rule, err := s.rules.CreateRule(ctx, rule)
+	if err != nil {
+		return domain.NotificationRule{}, err
+	}
+	s.logger.Info("Notification rule created",
+		zap.String("rule_id", rule.ID),
+		zap.String("event_type", rule.EventType))
+	return rule, nil
+}
+
+// UpdateRule replaces a rule; fields left empty take their defaults.
+func (s *RuleService) UpdateRule(ctx context.Context, rule domain.NotificationRule) (domain.NotificationRule, error) {
+	if err := s.checkChannels(rule); err != nil {
+		return domain.NotificationRule{}, err
+	}
+	if rule.UserField == "" {
+		rule.UserField = defaultUserField
+	}
+	rule, err := s.rules.UpdateRule(ctx, rule)
+	if err != nil {
+		return domain.NotificationRule{}, err
+	}
+	s.logger.Info("Notification rule updated", zap.String("rule_id", rule.ID))
+	return rule, nil
+}
+
+func (s *RuleService) DeleteRule(ctx context.Context, ruleId string) error {
+	if err := s.rules.DeleteRule(ctx, ruleId); err != nil {
+		return err
+	}
+	s.logger.Info("Notification rule deleted", zap.String("rule_id", ruleId))
+	return nil
+}
+
+func (s *RuleService) GetRule(ctx context.Context, ruleId string) (domain.NotificationRule, error) {
+	return s.rules.GetRule(ctx, ruleId)
+}
+
+// ListRules returns the tenant's rules for eventType, or all of them.
+func (s *RuleService) ListRules(ctx context.Context, eventType string) ([]domain.NotificationRule, error) {
+	return s.rules.ListRules(ctx, eventType, false)
+}
+
+// HandleEvent sends the notifications of every enabled rule matching the
+// event. Only failures a retry may fix, such as an unavailable database or
+// broker, are returned; a rule that cannot apply to the event is logged and
+// skipped. Each send is idempotent per event and rule, so retrying the
+// event does not repeat the rules that already sent.
+func (s *RuleService) HandleEvent(ctx context.Context, event domain.Event) error {
+	if event.TenantId != "" {
+		ctx = domain.ContextWithTenant(ctx, event.TenantId)
+	}
+	rules, err := s.rules.ListRules(ctx, event.Type, true)
+	if err != nil {
+		return err
+	}
+
+	var retry error
+	for _, rule := range rules {
+		if !rule.Matches(event) {
+			continue
+		}
+		req, err := rule.Request(event)
+		if err == nil {
+			_, err = s.notificationService.Send(ctx, req)
+		}
+		switch {
+		case err == nil:
+			s.logger.Info("Notification rule applied",
+				zap.String("rule_id", rule.ID),
+				zap.String("event_id", event.ID),
+				zap.String("event_type", event.Type))
+		case errors.Is(err, domain.ErrIdempotencyKeyReused):
+			// Sent before the rule was edited
+			s.logger.Info("Notification rule already applied",
+				zap.String("rule_id", rule.ID),
+				zap.String("event_id", event.ID))
+		case errors.Is(err, domain.ErrDatabase), errors.Is(err, domain.ErrKafkaProduce), errors.Is(err, domain.ErrIdempotencyKeyInProgress):
+			s.logger.Warn("Failed to apply notification rule, will retry",
+				zap.String("rule_id", rule.ID),
+				zap.String("event_id", event.ID),
+				zap.Error(err))
+			retry = err
+		default:
+			s.logger.Error("Notification rule cannot apply to event",
+				zap.String("rule_id", rule.ID),
+				zap.String("event_id", event.ID),
+				zap.String("event_type", event.Type),
+				zap.Error(err))
+		}
+	}
+	return retry
+}
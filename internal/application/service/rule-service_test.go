@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+// memRuleStore stores created and updated rules by ID.
+type memRuleStore struct {
+	domain.RuleStore
+	rules map[string]domain.NotificationRule
+}
+
+func (r *memRuleStore) CreateRule(_ context.Context, rule domain.NotificationRule) (domain.NotificationRule, error) {
+	rule.ID = rule.Name
+	r.rules[rule.ID] = rule
+	return rule, nil
+}
+
+func (r *memRuleStore) UpdateRule(_ context.Context, rule domain.NotificationRule) (domain.NotificationRule, error) {
+	r.rules[rule.ID] = rule
+	return rule, nil
+}
+
+func TestRuleChannels(t *testing.T) {
+	notificationService := NewNotificationService(nil, nil, nil, 0, zap.NewNop(), nil, nil).WithChannels(domain.EmailNotification)
+
+	tests := []struct {
+		name     string
+		channels []domain.NotificationType
+		want     error
+	}{
+		{"delivered email", []domain.NotificationType{domain.EmailNotification}, nil},
+		{"in-app is stored", []domain.NotificationType{domain.InAppNotification}, nil},
+		{"email and in-app", []domain.NotificationType{domain.EmailNotification, domain.InAppNotification}, nil},
+		{"undelivered push", []domain.NotificationType{domain.PushNotification}, domain.ErrUnsupportedChannel},
+		{"push among delivered", []domain.NotificationType{domain.EmailNotification, domain.PushNotification}, domain.ErrUnsupportedChannel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memRuleStore{rules: make(map[string]domain.NotificationRule)}
+			s := NewRuleService(notificationService, store, zap.NewNop())
+			rule := domain.NotificationRule{ID: "r1", Name: "r1", EventType: "course.enrolled", Channels: tt.channels}
+
+			_, createErr := s.CreateRule(context.Background(), rule)
+			_, updateErr := s.UpdateRule(context.Background(), rule)
+			for op, err := range map[string]error{"CreateRule": createErr, "UpdateRule": updateErr} {
+				if tt.want == nil && err != nil {
+					t.Fatalf("%s() = %v, want no error", op, err)
+				}
+				if !errors.Is(err, tt.want) {
+					t.Errorf("%s() = %v, want %v", op, err, tt.want)
+				}
+			}
+			if _, stored := store.rules["r1"]; stored != (tt.want == nil) {
+				t.Errorf("rule stored = %v, want %v", stored, tt.want == nil)
+			}
+		})
+	}
+}
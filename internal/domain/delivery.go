@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DeliveryClass tells what a failed delivery means for retrying it.
+type DeliveryClass int
+
+const (
+	// DeliveryTransient may succeed if retried, such as an SMTP 4xx reply
+	// or a lost connection.
+	DeliveryTransient DeliveryClass = iota
+	// DeliveryPermanent fails on every retry, such as an SMTP 5xx reply
+	// to the message itself.
+	DeliveryPermanent
+	// DeliveryRateLimited may be retried once RetryAfter has passed.
+	DeliveryRateLimited
+	// DeliverySuppressed must not be retried or replayed, since the
+	// recipient cannot receive it, such as an SMTP 550 to the mailbox.
+	DeliverySuppressed
+)
+
+func (c DeliveryClass) String() string {
+	switch c {
+	case DeliveryTransient:
+		return "transient"
+	case DeliveryPermanent:
+		return "permanent"
+	case DeliveryRateLimited:
+		return "rate_limited"
+	case DeliverySuppressed:
+		return "suppressed"
+	default:
+		return fmt.Sprintf("DeliveryClass(%d)", int(c))
+	}
+}
+
+// DeliveryError is a failed delivery with its classification. Senders
+// return it so the consumer can retry, dead-letter or drop the
+// notification accordingly.
+type DeliveryError struct {
+	Class DeliveryClass
+	// RetryAfter is how long a rate-limited delivery should wait, zero if
+	// unknown.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Class.String() + " delivery failure: " + e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// TransientError classifies err as a transient delivery failure.
+func TransientError(err error) error {
+	return &DeliveryError{Class: DeliveryTransient, Err: err}
+}
+
+// PermanentError classifies err as a permanent delivery failure.
+func PermanentError(err error) error {
+	return &DeliveryError{Class: DeliveryPermanent, Err: err}
+}
+
+// RateLimitedError classifies err as a rate-limited delivery failure that
+// may be retried after retryAfter, or zero if unknown.
+func RateLimitedError(err error, retryAfter time.Duration) error {
+	return &DeliveryError{Class: DeliveryRateLimited, RetryAfter: retryAfter, Err: err}
+}
+
+// SuppressedError classifies err as a suppressed delivery failure.
+func SuppressedError(err error) error {
+	return &DeliveryError{Class: DeliverySuppressed, Err: err}
+}
+
+// ClassifyDelivery returns the classification of a non-nil delivery
+// error. Errors that carry none are classified by their cause: an invalid
+// payload, unknown template or unsupported channel is permanent, a rate
+// limit is rate-limited and anything else is transient.
+func ClassifyDelivery(err error) *DeliveryError {
+	var classified *DeliveryError
+	if errors.As(err, &classified) {
+		return classified
+	}
+	switch {
+	case errors.Is(err, ErrInvalidPayload), errors.Is(err, ErrUnknownTemplate), errors.Is(err, ErrUnsupportedChannel):
+		return &DeliveryError{Class: DeliveryPermanent, Err: err}
+	case errors.Is(err, ErrRateLimit):
+		return &DeliveryError{Class: DeliveryRateLimited, Err: err}
+	default:
+		return &DeliveryError{Class: DeliveryTransient, Err: err}
+	}
+}
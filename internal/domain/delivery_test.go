@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestClassifyDelivery(t *testing.T) {
+	cause := errors.New("connection reset")
+
+	tests := []struct {
+		name       string
+		err        error
+		class      DeliveryClass
+		retryAfter time.Duration
+	}{
+		{"unclassified", cause, DeliveryTransient, 0},
+		{"transient", TransientError(cause), DeliveryTransient, 0},
+		{"permanent", PermanentError(cause), DeliveryPermanent, 0},
+		{"suppressed", SuppressedError(cause), DeliverySuppressed, 0},
+		{"rate limited", RateLimitedError(ErrRateLimit, 3*time.Second), DeliveryRateLimited, 3 * time.Second},
+		{"wrapped classification", fmt.Errorf("send: %w", SuppressedError(cause)), DeliverySuppressed, 0},
+		{"invalid payload", &InvalidPayloadError{Violations: []PayloadViolation{{"body", "is required"}}}, DeliveryPermanent, 0},
+		{"unknown template", fmt.Errorf("%w: welcome", ErrUnknownTemplate), DeliveryPermanent, 0},
+		{"unsupported channel", fmt.Errorf("%w: fax", ErrUnsupportedChannel), DeliveryPermanent, 0},
+		{"bare rate limit", ErrRateLimit, DeliveryRateLimited, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyDelivery(tt.err)
+			if got.Class != tt.class || got.RetryAfter != tt.retryAfter {
+				t.Errorf("ClassifyDelivery() = %v after %v, want %v after %v", got.Class, got.RetryAfter, tt.class, tt.retryAfter)
+			}
+			if !errors.Is(got, tt.err) && !errors.Is(tt.err, got) {
+				t.Errorf("ClassifyDelivery() = %v, lost the cause %v", got, tt.err)
+			}
+		})
+	}
+}
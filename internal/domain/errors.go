@@ -3,13 +3,34 @@ package domain
 import "errors"
 
 var (
-	ErrOTPNotFound      = errors.New("OTP not found")
-	ErrOTPExpired       = errors.New("OTP has expired")
-	ErrRateLimit        = errors.New("rate limit exceeded")
-	ErrDatabase         = errors.New("database error")
-	ErrKafkaProduce     = errors.New("failed to produce Kafka message")
-	ErrEmailSend        = errors.New("failed to send email")
-	ErrAlreadyProcessed = errors.New("notification already processed")
-	ErrNotFound         = errors.New("notification not found")
-	ErrUnauthorized     = errors.New("unauthorized access to resource")
+	ErrOTPNotFound              = errors.New("OTP not found")
+	ErrOTPExpired               = errors.New("OTP has expired")
+	ErrOTPAttemptsExceeded      = errors.New("too many failed OTP attempts")
+	ErrOTPHashFormat            = errors.New("unrecognized OTP hash format")
+	ErrSecretFormat             = errors.New("unrecognized encrypted secret format")
+	ErrTOTPNotEnrolled          = errors.New("TOTP not enrolled")
+	ErrTOTPEnrolled             = errors.New("TOTP already enrolled")
+	ErrMagicLinkInvalid         = errors.New("magic link is invalid or expired")
+	ErrMagicLinkUsed            = errors.New("magic link already used")
+	ErrRateLimit                = errors.New("rate limit exceeded")
+	ErrDatabase                 = errors.New("database error")
+	ErrKafkaProduce             = errors.New("failed to produce Kafka message")
+	ErrEmailSend                = errors.New("failed to send email")
+	ErrAlreadyProcessed         = errors.New("notification already processed")
+	ErrClaimLost                = errors.New("notification claim was taken over")
+	ErrNotFound                 = errors.New("notification not found")
+	ErrUnauthorized             = errors.New("unauthorized access to resource")
+	ErrUnauthenticated          = errors.New("caller is not authenticated")
+	ErrInvalidPageToken         = errors.New("invalid page token")
+	ErrUnknownTenant            = errors.New("unknown tenant")
+	ErrInvalidPayload           = errors.New("invalid notification payload")
+	ErrUnknownTemplate          = errors.New("unknown template")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key reused for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with this idempotency key is in progress")
+	ErrUnknownEventVersion      = errors.New("unknown event type or version")
+	ErrMalformedEvent           = errors.New("malformed event")
+	ErrRuleNotFound             = errors.New("notification rule not found")
+	ErrEventFieldMissing        = errors.New("event lacks a field the rule needs")
+	ErrEventFieldInvalid        = errors.New("event field is not valid for the rule")
+	ErrUnsupportedChannel       = errors.New("no sender for notification type")
 )
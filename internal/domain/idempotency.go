@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyKey records a send made with a client-supplied key, so a
+// retried request returns the notifications of the first one instead of
+// sending again. Keys are unique per tenant and caller, and expire after
+// ExpiresAt.
+type IdempotencyKey struct {
+	TenantId string `gorm:"type:varchar(64);primaryKey"`
+	Caller   string `gorm:"type:varchar(255);primaryKey"` // Principal subject, empty without authentication
+	Key      string `gorm:"type:varchar(255);primaryKey"`
+	// RequestHash fingerprints the request, so a key reused for a
+	// different request is rejected rather than answered with the wrong
+	// notifications.
+	RequestHash   string            `gorm:"type:char(64)"`
+	Notifications []NotificationRef `gorm:"type:jsonb;serializer:json"`
+	CreatedAt     time.Time
+	// CompletedAt is nil while the first request is still sending.
+	CompletedAt *time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+}
+
+// NotificationRef identifies a notification created by a send.
+type NotificationRef struct {
+	ID   string           `json:"id"`
+	Type NotificationType `json:"type"`
+}
+
+// IdempotencyStore persists idempotency keys.
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey stores key as in progress, replacing an expired
+	// key or one left in progress since before staleBefore. If another
+	// live key exists it is returned instead, with reserved false.
+	ReserveIdempotencyKey(ctx context.Context, key IdempotencyKey, staleBefore time.Time) (existing IdempotencyKey, reserved bool, err error)
+	// CompleteIdempotencyKey records the notifications a reserved key
+	// created.
+	CompleteIdempotencyKey(ctx context.Context, key IdempotencyKey) error
+	// ReleaseIdempotencyKey deletes a reserved key whose send created
+	// nothing, so the request can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, key IdempotencyKey) error
+}
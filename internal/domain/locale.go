@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// DefaultLocale is the last locale tried when rendering a template, so every
+// template and message must exist in it.
+const DefaultLocale = "en"
+
+// LocalizedMessage is a template rendered for one locale.
+type LocalizedMessage struct {
+	Locale  string // the locale actually used
+	Subject string
+	Body    string
+}
+
+// Localizer renders named email templates in a recipient's language.
+type Localizer interface {
+	// Render renders template name in the first of locales (BCP 47 tags,
+	// most preferred first) it supports, falling back to DefaultLocale.
+	// vars fill the template's message arguments; time.Time values should
+	// already be in the recipient's time zone.
+	Render(name string, locales []string, tenant Tenant, vars map[string]interface{}) (LocalizedMessage, error)
+	// RenderText is Render for channels that can't show HTML: the body is
+	// plain text from the template's body message.
+	RenderText(name string, locales []string, tenant Tenant, vars map[string]interface{}) (LocalizedMessage, error)
+}
+
+// UserPreferences are per-user delivery settings. The zero value means no
+// preference.
+type UserPreferences struct {
+	TenantId  string `gorm:"type:varchar(64);primaryKey"`
+	UserId    string `gorm:"type:uuid;primaryKey"`
+	Locale    string `gorm:"type:varchar(35)"` // BCP 47 language tag
+	TimeZone  string `gorm:"type:varchar(64)"` // IANA time zone name
+	UpdatedAt time.Time
+}
+
+// Location returns the user's time zone, UTC if unset or unknown.
+func (p UserPreferences) Location() *time.Location {
+	if p.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -2,33 +2,216 @@ package domain
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"time"
 )
 
 type Notification struct {
-	ID        string           `gorm:"type:uuid;primaryKey"`
-	UserId    string           `gorm:"type:uuid;index"`
+	ID        string           `gorm:"type:uuid;primaryKey;index:idx_notifications_user_listing,priority:5"`
+	TenantId  string           `gorm:"type:varchar(64);not null;default:'default';index:idx_notifications_user_listing,priority:1"`
+	UserId    string           `gorm:"type:uuid;index;index:idx_notifications_user_listing,priority:2"`
 	Type      NotificationType `gorm:"type:varchar(50)"`
+	Category  string           `gorm:"type:varchar(50);index"`
 	Subject   string           `gorm:"type:text;"`
 	Body      string           `gorm:"type:text"`
 	Recipient string           `gorm:"type:text"`
 	IsRead    bool             `gorm:"default:false;index"`
-	CreatedAt time.Time        `gorm:"autoCreateTime"`
+	CreatedAt time.Time        `gorm:"primaryKey;autoCreateTime;index:idx_notifications_user_listing,priority:4"` // Partition key
+
+	// Structured payload for clients that render more than text; see
+	// ValidatePayload for what each channel accepts. Metadata carries
+	// arbitrary client data such as course_id.
+	DeepLink string                 `gorm:"type:text"`
+	IconURL  string                 `gorm:"type:text"`
+	ImageURL string                 `gorm:"type:text"`
+	Actions  []NotificationAction   `gorm:"type:jsonb;serializer:json"`
+	Metadata map[string]interface{} `gorm:"type:jsonb;serializer:json"`
+
+	Priority NotificationPriority `gorm:"type:varchar(10);default:'normal'"`
+	// SendAt delays a notification: until then it is hidden from listings
+	// and counts, and it is queued for delivery by the scheduler, which
+	// records QueuedAt. Both are nil for immediate notifications.
+	SendAt   *time.Time
+	QueuedAt *time.Time
+
+	// Lifecycle state. Deleted notifications are never returned; archived
+	// and snoozed ones are hidden from listings unless asked for. A snoozed
+	// notification reappears, unread, once SnoozedUntil has passed.
+	Pinned       bool       `gorm:"default:false;index:idx_notifications_user_listing,priority:3"`
+	ArchivedAt   *time.Time `gorm:"index"`
+	SnoozedUntil *time.Time
+	DeletedAt    *time.Time `gorm:"index"`
+
+	// SearchConfig is the Postgres text search configuration used to index
+	// Subject and Body, one of SearchLanguages.
+	SearchConfig string `gorm:"type:regconfig"`
+
+	// Sensitive marks an email whose body carries a secret, such as a
+	// one-time code. It is sent directly instead of through Kafka, and its
+	// body is never stored.
+	Sensitive bool `gorm:"-" json:"-"`
+}
+
+// SearchLanguages are the Postgres text search configurations notifications
+// can be indexed and searched with.
+var SearchLanguages = []string{
+	"simple", "arabic", "armenian", "basque", "catalan", "danish", "dutch",
+	"english", "finnish", "french", "german", "greek", "hindi", "hungarian",
+	"indonesian", "irish", "italian", "lithuanian", "nepali", "norwegian",
+	"portuguese", "romanian", "russian", "serbian", "spanish", "swedish",
+	"tamil", "turkish", "yiddish",
+}
+
+// IsSearchLanguage reports whether language is one of SearchLanguages.
+func IsSearchLanguage(language string) bool {
+	for _, l := range SearchLanguages {
+		if l == language {
+			return true
+		}
+	}
+	return false
 }
 
+// SendRequest is one logical notification fanned out to several channels.
+// Content supplies the user, recipient, payload, priority and schedule
+// shared by every channel. The subject and body come either from Content
+// or, if Template is set, from rendering it in Locale with TemplateData.
+type SendRequest struct {
+	Channels     []NotificationType
+	Content      Notification
+	Template     string
+	TemplateData map[string]interface{}
+	Locale       string
+	// IdempotencyKey, if set, makes a repeat of the request return the
+	// notifications of the first instead of sending again.
+	IdempotencyKey string `json:"-"`
+}
+
+// SearchQuery is a full-text search over a user's notifications. Text uses
+// web search syntax: quoted phrases, "or", and "-" to exclude a word.
+type SearchQuery struct {
+	Text     string
+	Language string // Text search configuration, empty for the default
+	Filter   NotificationFilter
+}
+
+// SearchResult is a matching notification with its relevance and
+// highlighted excerpts. Matches in snippets are wrapped in <mark></mark>.
+type SearchResult struct {
+	Notification   Notification
+	Rank           float64
+	SubjectSnippet string
+	BodySnippet    string
+}
+
+// NotificationFilter narrows a user's notification listing. Nil fields are
+// not filtered on, so IsRead distinguishes "unread only" from "any".
+// Notifications snoozed until a future time are excluded unless
+// IncludeSnoozed is set.
+type NotificationFilter struct {
+	IsRead         *bool
+	Type           *NotificationType
+	Category       *string
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	Archived       *bool
+	Pinned         *bool
+	IncludeSnoozed bool
+}
+
+// PageCursor is the keyset position after the last notification of a page.
+// Listings are ordered by (pinned, created_at, id) descending, so pinned
+// notifications come first.
+type PageCursor struct {
+	Pinned    bool      `json:"p,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// Encode returns the cursor as an opaque page token.
+func (c PageCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodePageCursor parses a page token produced by PageCursor.Encode.
+func DecodePageCursor(token string) (PageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return PageCursor{}, ErrInvalidPageToken
+	}
+	var c PageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" || c.CreatedAt.IsZero() {
+		return PageCursor{}, ErrInvalidPageToken
+	}
+	return c, nil
+}
+
+// UnreadCount is a user's unread badge: the total plus a breakdown by
+// category. Uncategorised notifications only count towards Total.
+type UnreadCount struct {
+	Total      int64
+	ByCategory map[string]int64
+}
+
+// Equal reports whether both counts hold the same numbers.
+func (c UnreadCount) Equal(other UnreadCount) bool {
+	if c.Total != other.Total || len(c.ByCategory) != len(other.ByCategory) {
+		return false
+	}
+	for category, n := range c.ByCategory {
+		if m, ok := other.ByCategory[category]; !ok || m != n {
+			return false
+		}
+	}
+	return true
+}
+
+// ProcessedNotification is a consumer's claim on a notification message.
+// Until ProcessedAt is set it is a lease held by Owner until
+// LeaseExpiresAt, after which another consumer may take it over.
 type ProcessedNotification struct {
 	NotificationId string `gorm:"type:uuid;primaryKey"`
-	CreatedAt      time.Time
+	Owner          string `gorm:"type:varchar(128)"`
+	LeaseExpiresAt *time.Time
+	ProcessedAt    *time.Time
+	CreatedAt      time.Time `gorm:"index"`
 }
 
+// ClaimOutcome is the result of claiming a notification message.
+type ClaimOutcome int
+
+const (
+	// ClaimAcquired means the caller holds the lease and should deliver.
+	ClaimAcquired ClaimOutcome = iota
+	// ClaimHeld means another consumer holds a live lease.
+	ClaimHeld
+	// ClaimProcessed means the notification was already delivered.
+	ClaimProcessed
+)
+
+type NotificationPriority string
+
+const (
+	PriorityLow    NotificationPriority = "low"
+	PriorityNormal NotificationPriority = "normal"
+	PriorityHigh   NotificationPriority = "high"
+)
+
 type NotificationType string
 
 const (
 	EmailNotification NotificationType = "email"
 	InAppNotification NotificationType = "inapp"
 	OTPNotification   NotificationType = "otp"
+	PushNotification  NotificationType = "push"
 )
 
+// CategorySecurity is the category of account security emails, such as
+// OTPs, sign-in links and password resets.
+const CategorySecurity = "security"
+
 // NotificationService defines the interface for managing notifications in the system.
 // It provides methods for sending notifications, retrieving notifications, and marking them as read.
 // This interface acts as a middleman between the data layer and the domain layer, and is designed
@@ -65,14 +248,19 @@ type NotificationService interface {
 
 	GetANotification(ctx context.Context, notificationId, userId string) (*Notification, error)
 
-	// GetAllNotifications retrieves all notifications for a specific user.
+	// GetAllNotifications retrieves a page of notifications for a specific user.
 	// Parameters:
 	// - ctx: The context for managing request-scoped values, deadlines, and cancellations.
 	// - userId: The ID of the user whose notifications are to be retrieved.
+	// - filter: Optional read state, type, category and date range filters.
+	// - after: The cursor returned with the previous page, or nil for the first page.
+	// - pageSize: The maximum number of notifications to return.
 	// Returns:
 	// - A slice of Notification objects.
+	// - The cursor for the next page, or nil if this is the last page.
+	// - The total number of notifications matching the filter.
 	// - An error if the operation fails.
-	GetAllNotifications(ctx context.Context, userId string, page, pageSize int, isRead *bool, notifyType *string) ([]Notification, int64, error)
+	GetAllNotifications(ctx context.Context, userId string, filter NotificationFilter, after *PageCursor, pageSize int) ([]Notification, *PageCursor, int64, error)
 
 	// MarkAsRead marks a specific notification as read for a user.
 	// Parameters:
@@ -90,6 +278,60 @@ type NotificationService interface {
 	// Returns:
 	// - An error if the operation fails.
 	MarkAllAsRead(ctx context.Context, userId string) error
+
+	// SearchNotifications finds a user's notifications matching a full-text query.
+	// Parameters:
+	// - ctx: The context for managing request-scoped values, deadlines, and cancellations.
+	// - userId: The ID of the user whose notifications are searched.
+	// - query: The search text, language and filters.
+	// - offset: The number of results to skip.
+	// - limit: The maximum number of results to return.
+	// Returns:
+	// - The results, best match first.
+	// - Whether more results follow.
+	// - An error if the operation fails.
+	SearchNotifications(ctx context.Context, userId string, query SearchQuery, offset, limit int) ([]SearchResult, bool, error)
+
+	// DeleteNotifications soft-deletes a user's notifications.
+	// Parameters:
+	// - ctx: The context for managing request-scoped values, deadlines, and cancellations.
+	// - userId: The ID of the user who owns the notifications.
+	// - notificationIds: The IDs of the notifications to delete.
+	// Returns:
+	// - The number of notifications deleted; IDs not owned by the user are skipped.
+	// - An error if the operation fails.
+	DeleteNotifications(ctx context.Context, userId string, notificationIds []string) (int64, error)
+
+	// ArchiveNotifications archives, or with archived false unarchives, a
+	// user's notifications. It returns the number of notifications updated.
+	ArchiveNotifications(ctx context.Context, userId string, notificationIds []string, archived bool) (int64, error)
+
+	// SnoozeNotifications hides a user's notifications until the given time,
+	// after which they reappear as unread. It returns the number updated.
+	SnoozeNotifications(ctx context.Context, userId string, notificationIds []string, until time.Time) (int64, error)
+
+	// PinNotifications pins, or with pinned false unpins, a user's
+	// notifications. It returns the number of notifications updated.
+	PinNotifications(ctx context.Context, userId string, notificationIds []string, pinned bool) (int64, error)
+
+	// GetUnreadCount returns the number of unread notifications for a user.
+	// Parameters:
+	// - ctx: The context for managing request-scoped values, deadlines, and cancellations.
+	// - userId: The ID of the user whose unread notifications are counted.
+	// Returns:
+	// - The total and per-category unread counts.
+	// - An error if the operation fails.
+	GetUnreadCount(ctx context.Context, userId string) (UnreadCount, error)
+
+	// SubscribeUnreadCount streams a user's unread count, starting with the
+	// current value and then on every change, until ctx is done.
+	// Parameters:
+	// - ctx: The context whose cancellation ends the subscription.
+	// - userId: The ID of the user whose unread count is watched.
+	// Returns:
+	// - A channel of counts, closed when the subscription ends.
+	// - An error if the subscription cannot be started.
+	SubscribeUnreadCount(ctx context.Context, userId string) (<-chan UnreadCount, error)
 }
 
 type NotificationSender interface {
@@ -101,6 +343,8 @@ type NotificationSender interface {
 // as well as sending email notifications.
 // NotificationRepository defines the interface for managing notifications and sending email notifications.
 //
+// All methods act within the tenant of ctx (see TenantFromContext).
+//
 // Example usage:
 //
 //	// Create a new notification repository instance (implementation not shown).
@@ -149,15 +393,21 @@ type NotificationRepository interface {
 
 	GetANotification(ctx context.Context, notificationId, userId string) (*Notification, error)
 
-	// GetAllNotifications retrieves all notifications for a specific user.
+	// GetAllNotifications retrieves a page of notifications for a specific user,
+	// using keyset pagination on (created_at, id).
 	// Parameters:
 	// - ctx: The context for managing request-scoped values, deadlines, and cancellations.
 	// - userId: The ID of the user whose notifications are to be retrieved.
+	// - filter: Optional read state, type, category and date range filters.
+	// - after: The cursor returned with the previous page, or nil for the first page.
+	// - pageSize: The maximum number of notifications to return.
 	// Returns:
 	// - A slice of Notification objects.
+	// - The cursor for the next page, or nil if this is the last page.
+	// - The total number of notifications matching the filter.
 	// - An error if the operation fails.
-	GetAllNotifications(ctx context.Context, userId string, page, pageSize int, isRead *bool, notifyType *string) ([]Notification, int64, error)
-	// MarkAsRead marks a specific notification as read for a user.
+	GetAllNotifications(ctx context.Context, userId string, filter NotificationFilter, after *PageCursor, pageSize int) ([]Notification, *PageCursor, int64, error)
+	// MarkAs marks a specific notification as read for a user.
 	// Parameters:
 	// - ctx: The context for managing request-scoped values, deadlines, and cancellations.
 	// - notificationId: The ID of the notification to be marked as read.
@@ -174,6 +424,38 @@ type NotificationRepository interface {
 	// - An error if the operation fails.
 	MarkAllAsRead(ctx context.Context, userId string) error
 
+	// SearchNotifications returns a page of a user's notifications matching a
+	// full-text query, ordered by relevance, and whether more results follow.
+	SearchNotifications(ctx context.Context, userId string, query SearchQuery, offset, limit int) ([]SearchResult, bool, error)
+
+	// DeleteNotifications soft-deletes the given notifications of a user and
+	// returns how many were deleted.
+	DeleteNotifications(ctx context.Context, userId string, notificationIds []string) (int64, error)
+
+	// ArchiveNotifications sets or clears the archived state of the given
+	// notifications of a user and returns how many were updated.
+	ArchiveNotifications(ctx context.Context, userId string, notificationIds []string, archived bool) (int64, error)
+
+	// SnoozeNotifications marks the given notifications of a user unread and
+	// hidden until the given time, and returns how many were updated.
+	SnoozeNotifications(ctx context.Context, userId string, notificationIds []string, until time.Time) (int64, error)
+
+	// PinNotifications sets or clears the pinned state of the given
+	// notifications of a user and returns how many were updated.
+	PinNotifications(ctx context.Context, userId string, notificationIds []string, pinned bool) (int64, error)
+
+	// GetUnreadCount returns a user's unread counts from the cache, loading
+	// them from the database on a miss.
+	GetUnreadCount(ctx context.Context, userId string) (UnreadCount, error)
+
+	// WatchUnreadCount signals on the returned channel whenever a user's
+	// unread count may have changed, until ctx is done.
+	WatchUnreadCount(ctx context.Context, userId string) (<-chan struct{}, error)
+
+	// ReconcileUnreadCounts recomputes every cached unread count from the
+	// database, correcting any drift.
+	ReconcileUnreadCounts(ctx context.Context) error
+
 	// SendEmail sends an email notification to a recipient.
 	// Parameters:
 	// - ctx: The context for managing request-scoped values, deadlines, and cancellations.
@@ -184,8 +466,30 @@ type NotificationRepository interface {
 	// - An error if the operation fails.
 	SendEmail(ctx context.Context, recipient, subject, body string) error
 
-	CheckIfProcessed(ctx context.Context, notificationId string) (bool, error)
-	MarkAsProcessed(ctx context.Context, notificationId string) error
+	// ClaimNotification atomically leases a notification message to owner
+	// for lease, unless it was processed or another owner's lease is live.
+	ClaimNotification(ctx context.Context, notificationId, owner string, lease time.Duration) (ClaimOutcome, error)
+	// CompleteNotification records a claimed notification as processed. It
+	// returns ErrClaimLost if owner's lease was taken over.
+	CompleteNotification(ctx context.Context, notificationId, owner string) error
+	// ReleaseNotification drops owner's lease on an unprocessed
+	// notification, so a redelivery can claim it at once.
+	ReleaseNotification(ctx context.Context, notificationId, owner string) error
+
+	// GetPreferences returns a user's preferences, the zero value if none
+	// were saved.
+	GetPreferences(ctx context.Context, userId string) (UserPreferences, error)
+	// SavePreferences creates or replaces a user's preferences.
+	SavePreferences(ctx context.Context, preferences UserPreferences) error
+
+	// ClaimScheduledNotifications marks up to limit scheduled notifications
+	// of any tenant that are due as queued and returns them. Concurrent
+	// callers never claim the same notification.
+	ClaimScheduledNotifications(ctx context.Context, limit int) ([]Notification, error)
+	// ReleaseScheduledNotification returns a claimed notification to the
+	// schedule, for when it could not be queued.
+	ReleaseScheduledNotification(ctx context.Context, notification Notification) error
 
-	AutoMigrate() error
+	IdempotencyStore
+	RuleStore
 }
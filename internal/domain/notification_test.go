@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	created := time.Date(2026, 10, 18, 9, 30, 15, 123456789, time.UTC)
+	tests := []struct {
+		name   string
+		cursor PageCursor
+	}{
+		{"unpinned", PageCursor{CreatedAt: created, ID: "7f0c1a52-3c1e-4d8e-9a55-0b7a8f0e9d11"}},
+		{"pinned", PageCursor{Pinned: true, CreatedAt: created, ID: "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f"}},
+		{"other zone", PageCursor{CreatedAt: created.In(time.FixedZone("IST", 19800)), ID: "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePageCursor(tt.cursor.Encode())
+			if err != nil {
+				t.Fatalf("DecodePageCursor: %v", err)
+			}
+			// Keyset comparisons need the exact instant, to the nanosecond
+			if got.Pinned != tt.cursor.Pinned || got.ID != tt.cursor.ID || !got.CreatedAt.Equal(tt.cursor.CreatedAt) {
+				t.Errorf("decoded %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodePageCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"t":"2026-10-18T09:30:00Z","id":"a"}`))},
+		{"not json", encode("page 2")},
+		{"missing id", encode(`{"t":"2026-10-18T09:30:00Z"}`)},
+		{"missing time", encode(`{"id":"a"}`)},
+		{"bad time", encode(`{"t":"yesterday","id":"a"}`)},
+		{"search token", encode("20")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := DecodePageCursor(tt.token); !errors.Is(err, ErrInvalidPageToken) {
+				t.Errorf("DecodePageCursor(%q) = %+v, %v, want ErrInvalidPageToken", tt.token, c, err)
+			}
+		})
+	}
+}
@@ -8,15 +8,102 @@ import (
 )
 
 type OTP struct {
-	Code      string
+	// Code is the plaintext code. It only lives in memory long enough to be
+	// delivered and is never serialized to the store.
+	Code      string `json:"-"`
+	CodeHash  string
 	Email     string
 	UserId    string
+	TenantId  string
 	ExpiresAt time.Time
 }
 
+// TOTPEnrollment is a user's authenticator-app (RFC 6238) registration.
+// It stays unconfirmed until the user proves possession by verifying a
+// first code.
+type TOTPEnrollment struct {
+	TenantId     string `gorm:"type:varchar(64);primaryKey"`
+	UserId       string `gorm:"type:uuid;primaryKey"`
+	Secret       string `gorm:"type:text;not null"` // encrypted by a SecretCipher, never stored in plaintext
+	Confirmed    bool   `gorm:"default:false"`
+	LastUsedStep int64  `gorm:"default:0"` // last accepted time step, prevents replay
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// MagicLinkClaims are the signed contents of a passwordless login token.
+type MagicLinkClaims struct {
+	ID        string    `json:"jti"`
+	TenantId  string    `json:"tid,omitempty"`
+	UserId    string    `json:"sub"`
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// OTPRepository stores OTPs and enrollments. Lookups act within the tenant
+// of ctx; SaveOTP uses otp.TenantId.
 type OTPRepository interface {
 	SaveOTP(ctx context.Context, otp OTP) error
 	GetOTP(ctx context.Context, email string) (OTP, error)
+	// ConsumeOTP deletes the OTP for email, returning false if it was
+	// already gone, so a code is accepted at most once.
+	ConsumeOTP(ctx context.Context, email string) (bool, error)
+	// RecordOTPFailure counts a failed verification of the OTP for email
+	// and returns the failures so far. The count is reset by SaveOTP and
+	// expires at expiresAt, with the OTP.
+	RecordOTPFailure(ctx context.Context, email string, expiresAt time.Time) (int64, error)
+
+	// SaveTOTPEnrollment creates or replaces the enrollment for a user.
+	SaveTOTPEnrollment(ctx context.Context, enrollment TOTPEnrollment) error
+	GetTOTPEnrollment(ctx context.Context, userId string) (TOTPEnrollment, error)
+	// UseTOTPStep records step as used and confirms the enrollment. It
+	// returns false if an equal or later step was already used.
+	UseTOTPStep(ctx context.Context, userId string, step int64) (bool, error)
+
+	// SaveMagicLink registers a token id as redeemable for ttl.
+	SaveMagicLink(ctx context.Context, tokenId string, ttl time.Duration) error
+	// ConsumeMagicLink redeems a token id, returning false if it was
+	// unknown, expired or already used.
+	ConsumeMagicLink(ctx context.Context, tokenId string) (bool, error)
+}
+
+// OTPHasher derives a keyed, one-way hash of an OTP code so that only the
+// hash needs to be persisted.
+type OTPHasher interface {
+	// Hash returns an encoded hash of code bound to the given subject
+	// (usually the email address the code was sent to).
+	Hash(subject, code string) (string, error)
+
+	// Verify reports whether code matches the encoded hash for subject.
+	Verify(subject, code, encoded string) (bool, error)
+}
+
+// SecretCipher encrypts secrets the service must read back, such as TOTP
+// keys, so that read access to the store is not enough to use them.
+type SecretCipher interface {
+	// Encrypt returns an encoded ciphertext of plaintext bound to the given
+	// subject (usually the user the secret belongs to).
+	Encrypt(subject, plaintext string) (string, error)
+
+	// Decrypt returns the plaintext of an encoded ciphertext for subject.
+	Decrypt(subject, encoded string) (string, error)
+}
+
+// TOTPGenerator implements RFC 6238 time-based one-time passwords.
+type TOTPGenerator interface {
+	GenerateSecret() (string, error)
+	// ProvisioningURI returns the otpauth:// URI encoded in enrollment QR codes.
+	ProvisioningURI(secret, accountName string) string
+	// Validate checks code against secret within the allowed clock drift and
+	// returns the matching time step.
+	Validate(secret, code string, at time.Time) (int64, bool, error)
+}
+
+// MagicLinkSigner issues and verifies signed magic-link tokens.
+type MagicLinkSigner interface {
+	Issue(tenantId, userId, email string) (string, MagicLinkClaims, error)
+	// Parse verifies the signature and expiry of token.
+	Parse(token string) (MagicLinkClaims, error)
 }
 
 func GenerateOTP() (string, error) {
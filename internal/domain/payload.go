@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Payload limits, chosen so a notification still fits a push message.
+const (
+	MaxNotificationActions = 3
+	MaxActionLabelLength   = 40
+	MaxCategoryLength      = 50
+	MaxMetadataBytes       = 4096
+)
+
+// NotificationAction is a button shown with a notification.
+type NotificationAction struct {
+	ID    string `json:"id"` // reported back by the client when the action is taken
+	Label string `json:"label"`
+	URL   string `json:"url,omitempty"` // deep link or web page the action opens
+}
+
+// PayloadViolation is one invalid field of a notification.
+type PayloadViolation struct {
+	Field       string
+	Description string
+}
+
+// InvalidPayloadError lists everything wrong with a notification's content.
+// It matches ErrInvalidPayload.
+type InvalidPayloadError struct {
+	Violations []PayloadViolation
+}
+
+func (e *InvalidPayloadError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.Field + " " + v.Description
+	}
+	return ErrInvalidPayload.Error() + ": " + strings.Join(parts, "; ")
+}
+
+func (e *InvalidPayloadError) Is(target error) bool {
+	return target == ErrInvalidPayload
+}
+
+// channelRule is what a channel needs and can render.
+type channelRule struct {
+	recipient bool // requires a recipient address
+	subject   bool
+	body      bool
+	rich      bool // renders deep links, actions, icons and images
+}
+
+var channelRules = map[NotificationType]channelRule{
+	EmailNotification: {recipient: true, subject: true, body: true},
+	OTPNotification:   {recipient: true, body: true},
+	InAppNotification: {subject: true, rich: true},
+	PushNotification:  {subject: true, rich: true},
+}
+
+// blockedLinkSchemes could run script in the client that opens the link.
+var blockedLinkSchemes = map[string]bool{
+	"javascript": true,
+	"vbscript":   true,
+	"data":       true,
+}
+
+// ValidatePayload checks the content of n against what its channel
+// requires and can render, returning an *InvalidPayloadError.
+func (n Notification) ValidatePayload() error {
+	rule, ok := channelRules[n.Type]
+	if !ok {
+		return &InvalidPayloadError{Violations: []PayloadViolation{{"type", "is not a supported channel"}}}
+	}
+
+	var violations []PayloadViolation
+	add := func(field, description string) {
+		violations = append(violations, PayloadViolation{field, description})
+	}
+
+	if rule.recipient && strings.TrimSpace(n.Recipient) == "" {
+		add("recipient", "is required")
+	}
+	if rule.subject && strings.TrimSpace(n.Subject) == "" {
+		add("subject", "is required")
+	}
+	if rule.body && strings.TrimSpace(n.Body) == "" {
+		add("body", "is required")
+	}
+	switch n.Priority {
+	case "", PriorityLow, PriorityNormal, PriorityHigh:
+	default:
+		add("priority", "must be one of: low normal high")
+	}
+	if utf8.RuneCountInString(n.Category) > MaxCategoryLength {
+		add("category", fmt.Sprintf("must be at most %d characters", MaxCategoryLength))
+	}
+
+	if !rule.rich {
+		unsupported := []struct {
+			field string
+			set   bool
+		}{
+			{"deep_link", n.DeepLink != ""},
+			{"icon_url", n.IconURL != ""},
+			{"image_url", n.ImageURL != ""},
+			{"actions", len(n.Actions) > 0},
+		}
+		for _, u := range unsupported {
+			if u.set {
+				add(u.field, "is not supported by the "+string(n.Type)+" channel")
+			}
+		}
+	}
+
+	if n.DeepLink != "" && !isLink(n.DeepLink) {
+		add("deep_link", "must be an absolute URL")
+	}
+	if n.IconURL != "" && !isWebURL(n.IconURL) {
+		add("icon_url", "must be an http or https URL")
+	}
+	if n.ImageURL != "" && !isWebURL(n.ImageURL) {
+		add("image_url", "must be an http or https URL")
+	}
+
+	if len(n.Actions) > MaxNotificationActions {
+		add("actions", fmt.Sprintf("must have at most %d entries", MaxNotificationActions))
+	}
+	seen := make(map[string]bool, len(n.Actions))
+	for i, action := range n.Actions {
+		field := fmt.Sprintf("actions[%d]", i)
+		switch {
+		case action.ID == "":
+			add(field+".id", "is required")
+		case seen[action.ID]:
+			add(field+".id", "must be unique")
+		}
+		seen[action.ID] = true
+		if strings.TrimSpace(action.Label) == "" {
+			add(field+".label", "is required")
+		} else if utf8.RuneCountInString(action.Label) > MaxActionLabelLength {
+			add(field+".label", fmt.Sprintf("must be at most %d characters", MaxActionLabelLength))
+		}
+		if action.URL != "" && !isLink(action.URL) {
+			add(field+".url", "must be an absolute URL")
+		}
+	}
+
+	if len(n.Metadata) > 0 {
+		encoded, err := json.Marshal(n.Metadata)
+		if err != nil {
+			add("metadata", "must be representable as JSON")
+		} else if len(encoded) > MaxMetadataBytes {
+			add("metadata", fmt.Sprintf("must be at most %d bytes as JSON", MaxMetadataBytes))
+		}
+	}
+
+	if len(violations) > 0 {
+		return &InvalidPayloadError{Violations: violations}
+	}
+	return nil
+}
+
+// isLink accepts absolute URLs, including app schemes such as
+// "edulearn://course/42".
+func isLink(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || blockedLinkSchemes[strings.ToLower(u.Scheme)] {
+		return false
+	}
+	return u.Host != "" || u.Opaque != "" || u.Path != ""
+}
+
+func isWebURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
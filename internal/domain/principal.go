@@ -0,0 +1,52 @@
+package domain
+
+import "context"
+
+type PrincipalKind string
+
+const (
+	UserPrincipal    PrincipalKind = "user"
+	ServicePrincipal PrincipalKind = "service"
+)
+
+const (
+	RoleAdmin   = "admin"
+	RoleService = "service"
+)
+
+// Principal is the authenticated caller of an RPC, taken from a verified
+// JWT or a client certificate.
+type Principal struct {
+	Subject string
+	Kind    PrincipalKind
+	Roles   []string
+	// Tenant is set when the credential is bound to one tenant; the caller
+	// cannot act in any other.
+	Tenant string
+}
+
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CanActFor reports whether the principal may access resources owned by
+// userId: its own, or anyone's for admins and services.
+func (p Principal) CanActFor(userId string) bool {
+	return p.Subject == userId || p.HasRole(RoleAdmin) || p.HasRole(RoleService)
+}
+
+type principalKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,253 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is a domain event published by an upstream service, such as
+// course.enrolled or payment.succeeded. Data is the event's JSON body.
+type Event struct {
+	ID         string
+	Type       string
+	TenantId   string // Empty to use the tenant of the message
+	OccurredAt time.Time
+	Data       map[string]interface{}
+}
+
+// NotificationRule turns matching events into notifications, so upstream
+// services publish what happened and never format messages themselves. An
+// event matches when its type is EventType and every condition holds; the
+// rule then renders Template with the event data and sends it to the user
+// found at UserField on each of Channels.
+type NotificationRule struct {
+	ID         string          `gorm:"type:uuid;primaryKey"`
+	TenantId   string          `gorm:"type:varchar(64);not null;default:'default';index:idx_notification_rules_event,priority:1"`
+	Name       string          `gorm:"type:varchar(255)"`
+	EventType  string          `gorm:"type:varchar(255);index:idx_notification_rules_event,priority:2"`
+	Conditions []RuleCondition `gorm:"type:jsonb;serializer:json"`
+
+	Channels []NotificationType `gorm:"type:jsonb;serializer:json"`
+	Template string             `gorm:"type:varchar(255)"`
+	// Dotted paths into the event data. UserField is required; the
+	// recipient address and locale are optional.
+	UserField      string               `gorm:"type:varchar(255)"`
+	RecipientField string               `gorm:"type:varchar(255)"`
+	LocaleField    string               `gorm:"type:varchar(255)"`
+	Category       string               `gorm:"type:varchar(50)"`
+	Priority       NotificationPriority `gorm:"type:varchar(10)"`
+
+	Enabled   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type RuleOperator string
+
+const (
+	RuleEquals         RuleOperator = "eq"
+	RuleNotEquals      RuleOperator = "ne"
+	RuleIn             RuleOperator = "in"
+	RuleExists         RuleOperator = "exists"
+	RuleGreater        RuleOperator = "gt"
+	RuleGreaterOrEqual RuleOperator = "gte"
+	RuleLess           RuleOperator = "lt"
+	RuleLessOrEqual    RuleOperator = "lte"
+)
+
+// RuleCondition compares the event field at the dotted path Field with
+// Value. Ordering operators compare numbers numerically and strings, such
+// as RFC3339 times, lexically; in expects Value to be a list.
+type RuleCondition struct {
+	Field    string       `json:"field"`
+	Operator RuleOperator `json:"operator"`
+	Value    interface{}  `json:"value,omitempty"`
+}
+
+// Matches reports whether the event data satisfies the condition.
+func (c RuleCondition) Matches(data map[string]interface{}) bool {
+	actual, ok := EventField(data, c.Field)
+	switch c.Operator {
+	case RuleExists:
+		return ok
+	case RuleEquals:
+		return ok && equalValues(actual, c.Value)
+	case RuleNotEquals:
+		return !ok || !equalValues(actual, c.Value)
+	case RuleIn:
+		values, _ := c.Value.([]interface{})
+		for _, v := range values {
+			if ok && equalValues(actual, v) {
+				return true
+			}
+		}
+		return false
+	case RuleGreater, RuleGreaterOrEqual, RuleLess, RuleLessOrEqual:
+		cmp, comparable := compareValues(actual, c.Value)
+		if !ok || !comparable {
+			return false
+		}
+		switch c.Operator {
+		case RuleGreater:
+			return cmp > 0
+		case RuleGreaterOrEqual:
+			return cmp >= 0
+		case RuleLess:
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	}
+	return false
+}
+
+// Matches reports whether the rule applies to event.
+func (r NotificationRule) Matches(event Event) bool {
+	if !r.Enabled || r.EventType != event.Type {
+		return false
+	}
+	for _, c := range r.Conditions {
+		if !c.Matches(event.Data) {
+			return false
+		}
+	}
+	return true
+}
+
+// Request builds the send for a matching event. Template arguments are the
+// event data, nested fields by dotted path (so {course.title}), with
+// RFC3339 strings as times. The idempotency key ties the send to the event
+// and rule, so a redelivered event is not notified twice. An event without
+// a user ID returns ErrEventFieldMissing, and one whose user ID is not a
+// UUID ErrEventFieldInvalid; retrying either cannot help.
+func (r NotificationRule) Request(event Event) (SendRequest, error) {
+	field, _ := EventField(event.Data, r.UserField)
+	userId, ok := field.(string)
+	if !ok || userId == "" {
+		return SendRequest{}, fmt.Errorf("%w: %s", ErrEventFieldMissing, r.UserField)
+	}
+	if err := uuid.Validate(userId); err != nil {
+		return SendRequest{}, fmt.Errorf("%w: %s is not a UUID", ErrEventFieldInvalid, r.UserField)
+	}
+	req := SendRequest{
+		Channels: r.Channels,
+		Content: Notification{
+			UserId:   userId,
+			Category: r.Category,
+			Priority: r.Priority,
+			Metadata: map[string]interface{}{"event_id": event.ID, "event_type": event.Type, "rule_id": r.ID},
+		},
+		Template:     r.Template,
+		TemplateData: flattenEventData("", event.Data, make(map[string]interface{})),
+	}
+	if r.RecipientField != "" {
+		recipient, _ := EventField(event.Data, r.RecipientField)
+		req.Content.Recipient, _ = recipient.(string)
+	}
+	if r.LocaleField != "" {
+		locale, _ := EventField(event.Data, r.LocaleField)
+		req.Locale, _ = locale.(string)
+	}
+	if event.ID != "" {
+		req.IdempotencyKey = "rule:" + r.ID + ":" + event.ID
+	}
+	return req, nil
+}
+
+// EventField returns the value at a dotted path such as "course.title".
+func EventField(data map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func flattenEventData(prefix string, data map[string]interface{}, into map[string]interface{}) map[string]interface{} {
+	for k, v := range data {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flattenEventData(prefix+k+".", v, into)
+		case string:
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				into[prefix+k] = t
+			} else {
+				into[prefix+k] = v
+			}
+		default:
+			into[prefix+k] = v
+		}
+	}
+	return into
+}
+
+func equalValues(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues orders two numbers or two strings.
+func compareValues(a, b interface{}) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok := a.(string)
+	y, ok2 := b.(string)
+	if !ok || !ok2 {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// RuleStore persists notification rules, per tenant.
+type RuleStore interface {
+	CreateRule(ctx context.Context, rule NotificationRule) (NotificationRule, error)
+	// UpdateRule replaces a rule, returning ErrRuleNotFound if it doesn't
+	// exist.
+	UpdateRule(ctx context.Context, rule NotificationRule) (NotificationRule, error)
+	DeleteRule(ctx context.Context, ruleId string) error
+	GetRule(ctx context.Context, ruleId string) (NotificationRule, error)
+	// ListRules returns the rules for eventType, or every rule if it is
+	// empty, optionally only the enabled ones.
+	ListRules(ctx context.Context, eventType string, enabledOnly bool) ([]NotificationRule, error)
+}
@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRuleConditionMatches(t *testing.T) {
+	data := map[string]interface{}{
+		"status": "paid",
+		"amount": float64(120),
+		"course": map[string]interface{}{"level": "advanced", "starts_at": "2026-11-01T09:00:00Z"},
+		"tags":   []interface{}{"a"},
+	}
+
+	tests := []struct {
+		name      string
+		condition RuleCondition
+		want      bool
+	}{
+		{"eq", RuleCondition{"status", RuleEquals, "paid"}, true},
+		{"eq mismatch", RuleCondition{"status", RuleEquals, "refunded"}, false},
+		{"eq number across types", RuleCondition{"amount", RuleEquals, 120}, true},
+		{"eq missing field", RuleCondition{"missing", RuleEquals, "paid"}, false},
+		{"eq nested", RuleCondition{"course.level", RuleEquals, "advanced"}, true},
+		{"eq through a non-object", RuleCondition{"status.level", RuleEquals, "paid"}, false},
+		{"ne", RuleCondition{"status", RuleNotEquals, "refunded"}, true},
+		{"ne equal", RuleCondition{"status", RuleNotEquals, "paid"}, false},
+		{"ne missing field", RuleCondition{"missing", RuleNotEquals, "paid"}, true},
+		{"in", RuleCondition{"status", RuleIn, []interface{}{"paid", "settled"}}, true},
+		{"in mismatch", RuleCondition{"status", RuleIn, []interface{}{"refunded"}}, false},
+		{"in not a list", RuleCondition{"status", RuleIn, "paid"}, false},
+		{"in missing field", RuleCondition{"missing", RuleIn, []interface{}{nil}}, false},
+		{"exists", RuleCondition{"course.level", RuleExists, nil}, true},
+		{"exists missing", RuleCondition{"course.title", RuleExists, nil}, false},
+		{"gt", RuleCondition{"amount", RuleGreater, 100}, true},
+		{"gt equal", RuleCondition{"amount", RuleGreater, 120}, false},
+		{"gte equal", RuleCondition{"amount", RuleGreaterOrEqual, float64(120)}, true},
+		{"lt", RuleCondition{"amount", RuleLess, 100}, false},
+		{"lte equal", RuleCondition{"amount", RuleLessOrEqual, 120}, true},
+		{"gt time string", RuleCondition{"course.starts_at", RuleGreater, "2026-10-18T00:00:00Z"}, true},
+		{"gt number against string", RuleCondition{"amount", RuleGreater, "100"}, false},
+		{"lt missing field", RuleCondition{"missing", RuleLess, 100}, false},
+		{"unknown operator", RuleCondition{"status", "like", "paid"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.condition.Matches(data); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationRuleMatches(t *testing.T) {
+	rule := NotificationRule{
+		EventType: "payment.succeeded",
+		Enabled:   true,
+		Conditions: []RuleCondition{
+			{"amount", RuleGreaterOrEqual, 100},
+			{"currency", RuleEquals, "USD"},
+		},
+	}
+	event := func(eventType string, amount float64) Event {
+		return Event{Type: eventType, Data: map[string]interface{}{"amount": amount, "currency": "USD"}}
+	}
+	disabled := rule
+	disabled.Enabled = false
+	unconditional := rule
+	unconditional.Conditions = nil
+
+	tests := []struct {
+		name  string
+		rule  NotificationRule
+		event Event
+		want  bool
+	}{
+		{"all conditions hold", rule, event("payment.succeeded", 150), true},
+		{"one condition fails", rule, event("payment.succeeded", 50), false},
+		{"other event type", rule, event("payment.failed", 150), false},
+		{"disabled", disabled, event("payment.succeeded", 150), false},
+		{"no conditions", unconditional, event("payment.succeeded", 1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Matches(tt.event); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationRuleRequest(t *testing.T) {
+	const userId = "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f"
+	rule := NotificationRule{
+		ID:             "r1",
+		Channels:       []NotificationType{EmailNotification, InAppNotification},
+		Template:       "course-enrolled",
+		UserField:      "user.id",
+		RecipientField: "user.email",
+		LocaleField:    "user.locale",
+		Category:       "course",
+		Priority:       PriorityHigh,
+	}
+	event := Event{
+		ID:   "e1",
+		Type: "course.enrolled",
+		Data: map[string]interface{}{
+			"user":   map[string]interface{}{"id": userId, "email": "ann@example.com", "locale": "fr"},
+			"course": map[string]interface{}{"title": "Go", "starts_at": "2026-11-01T09:00:00Z"},
+			"seats":  float64(3),
+		},
+	}
+
+	req, err := rule.Request(event)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	want := SendRequest{
+		Channels: rule.Channels,
+		Content: Notification{
+			UserId:    userId,
+			Recipient: "ann@example.com",
+			Category:  "course",
+			Priority:  PriorityHigh,
+			Metadata:  map[string]interface{}{"event_id": "e1", "event_type": "course.enrolled", "rule_id": "r1"},
+		},
+		Template: "course-enrolled",
+		TemplateData: map[string]interface{}{
+			"user.id":          userId,
+			"user.email":       "ann@example.com",
+			"user.locale":      "fr",
+			"course.title":     "Go",
+			"course.starts_at": time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC),
+			"seats":            float64(3),
+		},
+		Locale:         "fr",
+		IdempotencyKey: "rule:r1:e1",
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Errorf("Request() = %+v\nwant %+v", req, want)
+	}
+}
+
+func TestNotificationRuleRequestUser(t *testing.T) {
+	rule := NotificationRule{ID: "r1", UserField: "user_id", Channels: []NotificationType{InAppNotification}}
+
+	tests := []struct {
+		name   string
+		userId interface{}
+		want   error
+	}{
+		{"uuid", "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f", nil},
+		{"missing", nil, ErrEventFieldMissing},
+		{"empty", "", ErrEventFieldMissing},
+		{"not a string", float64(42), ErrEventFieldMissing},
+		{"not a uuid", "user-42", ErrEventFieldInvalid},
+		{"truncated uuid", "0f8e2d4c-7a5b-4c3d-8e9f", ErrEventFieldInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{}
+			if tt.userId != nil {
+				data["user_id"] = tt.userId
+			}
+			_, err := rule.Request(Event{ID: "e1", Data: data})
+			if tt.want == nil && err != nil {
+				t.Fatalf("Request() = %v, want no error", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Request() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
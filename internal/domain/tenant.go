@@ -0,0 +1,45 @@
+package domain
+
+import "context"
+
+// DefaultTenant owns requests and rows that don't name a tenant, so a
+// single-tenant deployment needs no tenant configuration.
+const DefaultTenant = "default"
+
+// Tenant is one white-label academy sharing the service.
+type Tenant struct {
+	ID   string
+	Name string
+	// Locale is the language of the tenant's emails when the recipient has
+	// no preference.
+	Locale string
+	// Branding holds the URLs templates link to, e.g. "logo_url".
+	Branding map[string]string
+}
+
+// Tenants holds the configured tenants by id.
+type Tenants map[string]Tenant
+
+// Lookup returns ErrUnknownTenant for ids that are not configured.
+func (t Tenants) Lookup(id string) (Tenant, error) {
+	tenant, ok := t[id]
+	if !ok {
+		return Tenant{}, ErrUnknownTenant
+	}
+	return tenant, nil
+}
+
+type tenantKey struct{}
+
+func ContextWithTenant(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantId)
+}
+
+// TenantFromContext returns the tenant a request acts in, DefaultTenant if
+// none was set.
+func TenantFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(tenantKey{}).(string); ok && id != "" {
+		return id
+	}
+	return DefaultTenant
+}
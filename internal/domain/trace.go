@@ -0,0 +1,23 @@
+package domain
+
+import "context"
+
+// Trace ties work done for one request together across services: its
+// correlation ID and, if the caller traces, its W3C trace context.
+type Trace struct {
+	CorrelationID string
+	TraceParent   string // e.g. "00-<trace-id>-<parent-id>-01"
+	TraceState    string
+}
+
+type traceKey struct{}
+
+func ContextWithTrace(ctx context.Context, trace Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFromContext returns the trace of ctx, the zero Trace if none.
+func TraceFromContext(ctx context.Context) Trace {
+	trace, _ := ctx.Value(traceKey{}).(Trace)
+	return trace
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// testKeys are a signing key of each supported type.
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{rsa: rsaKey, ec: ecKey, ed25519: edKey}
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+// toJWK encodes the public key of a test key as a JWK.
+func toJWK(kid string, key crypto.PublicKey) jwk {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return jwk{Kty: "RSA", Kid: kid, N: encodeBigInt(k.N), E: encodeBigInt(big.NewInt(int64(k.E)))}
+	case *ecdsa.PublicKey:
+		return jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: encodeBigInt(k.X), Y: encodeBigInt(k.Y)}
+	case ed25519.PublicKey:
+		return jwk{Kty: "OKP", Kid: kid, Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(k)}
+	}
+	panic("unsupported key")
+}
+
+// jwksDocument is the JWKS of the test keys, under the key ids rsa, ec and
+// ed25519, followed by extra.
+func (k testKeys) jwksDocument(t *testing.T, extra ...jwk) []byte {
+	t.Helper()
+	keys := append([]jwk{
+		toJWK("rsa", &k.rsa.PublicKey),
+		toJWK("ec", &k.ec.PublicKey),
+		toJWK("ed25519", k.ed25519.Public()),
+	}, extra...)
+	data, err := json.Marshal(map[string][]jwk{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeJWKS(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestKeySetLoads(t *testing.T) {
+	keys := newTestKeys(t)
+	document := keys.jwksDocument(t,
+		jwk{Kty: "RSA", Kid: "encryption", Use: "enc", N: encodeBigInt(keys.rsa.N), E: "AQAB"},
+		jwk{Kty: "EC", Kid: "unknown-curve", Crv: "P-192", X: "AQ", Y: "AQ"},
+		jwk{Kty: "oct", Kid: "symmetric"},
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(document)
+	}))
+	defer server.Close()
+
+	for name, source := range map[string]string{"file": writeJWKS(t, document), "url": server.URL} {
+		t.Run(name, func(t *testing.T) {
+			ks, err := NewKeySet(context.Background(), source, zap.NewNop())
+			if err != nil {
+				t.Fatalf("NewKeySet: %v", err)
+			}
+			want := map[string]crypto.PublicKey{
+				"rsa":     &keys.rsa.PublicKey,
+				"ec":      &keys.ec.PublicKey,
+				"ed25519": keys.ed25519.Public(),
+			}
+			for kid, key := range want {
+				got, ok := ks.Key(kid)
+				if !ok {
+					t.Errorf("key %s not loaded", kid)
+					continue
+				}
+				if !key.(interface{ Equal(crypto.PublicKey) bool }).Equal(got) {
+					t.Errorf("key %s = %v, want %v", kid, got, key)
+				}
+			}
+			for _, kid := range []string{"encryption", "unknown-curve", "symmetric", ""} {
+				if _, ok := ks.Key(kid); ok {
+					t.Errorf("unusable key %q loaded", kid)
+				}
+			}
+		})
+	}
+}
+
+func TestKeySetRejects(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"missing file", filepath.Join(t.TempDir(), "missing.json")},
+		{"not json", writeJWKS(t, []byte("not json"))},
+		{"no usable keys", writeJWKS(t, []byte(`{"keys":[{"kty":"oct","kid":"symmetric"}]}`))},
+		{"no keys", writeJWKS(t, []byte(`{"keys":[]}`))},
+		{"unavailable url", unavailable.URL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeySet(context.Background(), tt.source, zap.NewNop()); err == nil {
+				t.Error("NewKeySet() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestKeySetRefreshKeepsKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	path := writeJWKS(t, keys.jwksDocument(t))
+	ks, err := NewKeySet(context.Background(), path, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Refresh(context.Background()); err == nil {
+		t.Error("Refresh() of a broken document succeeded")
+	}
+	if _, ok := ks.Key("rsa"); !ok {
+		t.Error("failed refresh dropped the loaded keys")
+	}
+
+	rotated := newTestKeys(t)
+	if err := os.WriteFile(path, rotated.jwksDocument(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if key, _ := ks.Key("rsa"); !rotated.rsa.PublicKey.Equal(key) {
+		t.Error("refresh did not load the rotated keys")
+	}
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTVerifier validates bearer tokens against a KeySet and turns their
+// claims into a domain.Principal.
+type JWTVerifier struct {
+	keys        *KeySet
+	parser      *jwt.Parser
+	rolesClaim  string
+	tenantClaim string
+}
+
+// NewJWTVerifier creates a verifier. issuer and audience are enforced when
+// non-empty; rolesClaim names the claim holding the caller's roles and
+// tenantClaim the one binding it to a tenant, if any.
+func NewJWTVerifier(keys *KeySet, issuer, audience, rolesClaim, tenantClaim string) *JWTVerifier {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+	return &JWTVerifier{keys: keys, parser: jwt.NewParser(opts...), rolesClaim: rolesClaim, tenantClaim: tenantClaim}
+}
+
+func (v *JWTVerifier) Verify(token string) (domain.Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := v.keys.Key(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return domain.Principal{}, errors.Join(domain.ErrUnauthenticated, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return domain.Principal{}, errors.Join(domain.ErrUnauthenticated, errors.New("token has no subject"))
+	}
+
+	principal := domain.Principal{Subject: subject, Kind: domain.UserPrincipal}
+	switch roles := claims[v.rolesClaim].(type) {
+	case []interface{}:
+		for _, r := range roles {
+			if role, ok := r.(string); ok {
+				principal.Roles = append(principal.Roles, role)
+			}
+		}
+	case string:
+		principal.Roles = []string{roles}
+	}
+	if principal.HasRole(domain.RoleService) {
+		principal.Kind = domain.ServicePrincipal
+	}
+	if v.tenantClaim != "" {
+		principal.Tenant, _ = claims[v.tenantClaim].(string)
+	}
+	return principal, nil
+}
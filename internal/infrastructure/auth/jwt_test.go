@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	testIssuer   = "https://auth.example.com"
+	testAudience = "notification-service"
+)
+
+func testVerifier(t *testing.T, keys testKeys) *JWTVerifier {
+	t.Helper()
+	ks, err := NewKeySet(context.Background(), writeJWKS(t, keys.jwksDocument(t)), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return NewJWTVerifier(ks, testIssuer, testAudience, "", "tenant_id")
+}
+
+// validClaims are claims the test verifier accepts, changed by edit.
+func validClaims(edit func(jwt.MapClaims)) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"sub":       "u1",
+		"iss":       testIssuer,
+		"aud":       testAudience,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"roles":     []string{"admin"},
+		"tenant_id": "acme",
+	}
+	if edit != nil {
+		edit(claims)
+	}
+	return claims
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed
+}
+
+func TestJWTVerifierAccepts(t *testing.T) {
+	keys := newTestKeys(t)
+	v := testVerifier(t, keys)
+
+	tests := []struct {
+		name  string
+		token string
+		want  domain.Principal
+	}{
+		{"rsa", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, validClaims(nil)),
+			domain.Principal{Subject: "u1", Kind: domain.UserPrincipal, Roles: []string{"admin"}, Tenant: "acme"}},
+		{"ecdsa", sign(t, jwt.SigningMethodES256, "ec", keys.ec, validClaims(nil)),
+			domain.Principal{Subject: "u1", Kind: domain.UserPrincipal, Roles: []string{"admin"}, Tenant: "acme"}},
+		{"ed25519", sign(t, jwt.SigningMethodEdDSA, "ed25519", keys.ed25519, validClaims(nil)),
+			domain.Principal{Subject: "u1", Kind: domain.UserPrincipal, Roles: []string{"admin"}, Tenant: "acme"}},
+		{"service role", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, validClaims(func(c jwt.MapClaims) { c["roles"] = domain.RoleService })),
+			domain.Principal{Subject: "u1", Kind: domain.ServicePrincipal, Roles: []string{domain.RoleService}, Tenant: "acme"}},
+		{"audience list", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, validClaims(func(c jwt.MapClaims) { c["aud"] = []string{"other", testAudience} })),
+			domain.Principal{Subject: "u1", Kind: domain.UserPrincipal, Roles: []string{"admin"}, Tenant: "acme"}},
+		{"no roles or tenant", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, validClaims(func(c jwt.MapClaims) { delete(c, "roles"); delete(c, "tenant_id") })),
+			domain.Principal{Subject: "u1", Kind: domain.UserPrincipal}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := v.Verify(tt.token)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if !reflect.DeepEqual(principal, tt.want) {
+				t.Errorf("Verify() = %+v, want %+v", principal, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTVerifierRejects(t *testing.T) {
+	keys := newTestKeys(t)
+	v := testVerifier(t, keys)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit := func(edit func(jwt.MapClaims)) string {
+		return sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, validClaims(edit))
+	}
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "retired", keys.rsa, validClaims(nil))},
+		{"no kid", sign(t, jwt.SigningMethodRS256, "", keys.rsa, validClaims(nil))},
+		{"kid of another key", sign(t, jwt.SigningMethodRS256, "ec", keys.rsa, validClaims(nil))},
+		{"signed by another key", sign(t, jwt.SigningMethodRS256, "rsa", other, validClaims(nil))},
+		{"hmac", sign(t, jwt.SigningMethodHS256, "rsa", []byte("shared-secret"), validClaims(nil))},
+		{"alg none", unsigned},
+		{"expired", edit(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })},
+		{"no expiry", edit(func(c jwt.MapClaims) { delete(c, "exp") })},
+		{"not yet valid", edit(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() })},
+		{"wrong audience", edit(func(c jwt.MapClaims) { c["aud"] = "other-service" })},
+		{"no audience", edit(func(c jwt.MapClaims) { delete(c, "aud") })},
+		{"wrong issuer", edit(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" })},
+		{"no issuer", edit(func(c jwt.MapClaims) { delete(c, "iss") })},
+		{"no subject", edit(func(c jwt.MapClaims) { delete(c, "sub") })},
+		{"malformed", "not.a.token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := v.Verify(tt.token)
+			if !errors.Is(err, domain.ErrUnauthenticated) {
+				t.Errorf("Verify() = %+v, %v, want %v", principal, err, domain.ErrUnauthenticated)
+			}
+		})
+	}
+}
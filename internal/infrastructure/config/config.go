@@ -1,6 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -15,6 +21,203 @@ type Config struct {
 	RedisAddr     string
 	ConsumerGroup string
 	GRpcPort      string
+
+	KafkaClient   KafkaClientConfig
+	KafkaConsumer KafkaConsumerConfig
+	KafkaProducer KafkaProducerConfig
+	// Encoding of produced events: "protobuf" or "json"
+	KafkaEventFormat string
+
+	// Topics of upstream domain events to apply notification rules to;
+	// none disables the rules consumer
+	RuleTopics        []string
+	RuleConsumerGroup string
+
+	// OTP hashing. OTPPreviousPepper is only set while rotating peppers and
+	// is honoured until OTPPreviousPepperUntil (or indefinitely if zero).
+	OTPHashAlgorithm       string
+	OTPPepper              string
+	OTPPreviousPepper      string
+	OTPPreviousPepperUntil time.Time
+
+	TOTPIssuer string
+	TOTPSkew   int
+	// TOTP secrets are encrypted at rest. TOTPPreviousEncryptionKey keeps
+	// secrets encrypted before a key rotation readable.
+	TOTPEncryptionKey         string
+	TOTPPreviousEncryptionKey string
+
+	MagicLinkSecret  string
+	MagicLinkBaseURL string
+	MagicLinkTTL     time.Duration
+
+	// How often cached unread counts are recomputed from Postgres
+	UnreadReconcileInterval time.Duration
+
+	// How often due scheduled notifications are queued
+	SchedulerInterval time.Duration
+
+	// How long a send's idempotency key is honoured
+	IdempotencyTTL time.Duration
+
+	// Default Postgres text search configuration for notifications
+	SearchLanguage string
+
+	RateLimits RateLimitConfig
+	RPCQuotas  RPCQuotaConfig
+	Auth       AuthConfig
+	Retention  RetentionConfig
+
+	// Tenants by id; always contains domain.DefaultTenant
+	Tenants map[string]TenantConfig
+}
+
+// KafkaClientConfig is read from the "kafka" key and applies to every
+// producer and consumer. SASL is off unless a mechanism is set.
+type KafkaClientConfig struct {
+	ClientID string `mapstructure:"client_id"`
+	SASL     struct {
+		Mechanism string `mapstructure:"mechanism"` // PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+		Username  string `mapstructure:"username"`
+		Password  string `mapstructure:"password"`
+	} `mapstructure:"sasl"`
+	TLS struct {
+		Enabled            bool   `mapstructure:"enabled"`
+		CAFile             string `mapstructure:"ca_file"`   // System roots if empty
+		CertFile           string `mapstructure:"cert_file"` // Client certificate, optional
+		KeyFile            string `mapstructure:"key_file"`
+		InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	} `mapstructure:"tls"`
+}
+
+// KafkaConsumerConfig is read from the "kafka.consumer" key. The group
+// settings apply to the notification and rules consumers; Topics are those
+// of the notification consumer.
+type KafkaConsumerConfig struct {
+	InitialOffset     string             `mapstructure:"initial_offset"` // "oldest" or "newest", for a new group
+	SessionTimeout    time.Duration      `mapstructure:"session_timeout"`
+	HeartbeatInterval time.Duration      `mapstructure:"heartbeat_interval"`
+	RebalanceStrategy string             `mapstructure:"rebalance_strategy"` // "roundrobin", "range" or "sticky"
+	Topics            []KafkaTopicConfig `mapstructure:"topics"`
+}
+
+// MaxKafkaAttempts is the first delivery and one per retry topic tier.
+const MaxKafkaAttempts = 4
+
+type KafkaTopicConfig struct {
+	Name        string `mapstructure:"name"`
+	Workers     int    `mapstructure:"workers"`      // Per partition
+	MaxAttempts int    `mapstructure:"max_attempts"` // Delivery attempts per notification, 1 to MaxKafkaAttempts
+}
+
+// KafkaProducerConfig is read from the "kafka.producer" key. The async
+// producer batches messages, flushing on whichever limit is reached first.
+type KafkaProducerConfig struct {
+	Async          bool          `mapstructure:"async"`
+	FlushMessages  int           `mapstructure:"flush_messages"`
+	FlushBytes     int           `mapstructure:"flush_bytes"`
+	FlushFrequency time.Duration `mapstructure:"flush_frequency"`
+}
+
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// TenantConfig is one entry of the "tenants" key, keyed by tenant id. An
+// empty SMTP host uses the top-level smtp account, and the locale and
+// branding keys the tenant doesn't set are taken from the default tenant.
+// Per-tenant rate limits are configured under ratelimit.tenants.
+type TenantConfig struct {
+	Name     string            `mapstructure:"name"`
+	Locale   string            `mapstructure:"locale"` // email language for users without a preference
+	Sender   string            `mapstructure:"sender"` // From address, defaults to the SMTP username
+	SMTP     SMTPConfig        `mapstructure:"smtp"`
+	Branding map[string]string `mapstructure:"branding"`
+}
+
+// Tenant ids become part of Redis keys, so they are restricted to a
+// separator-free alphabet.
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// defaultBranding fills the template links the default tenant doesn't
+// configure.
+var defaultBranding = map[string]string{
+	"home_url":      "http://localhost:3000/api/v1",
+	"logo_url":      "https://img.atom.com/story_images/visual_images/1612609471-kafty.png?class=show",
+	"support_email": "shafeeqsha06@gmail.com",
+}
+
+type RateLimit struct {
+	Rate  float64 `mapstructure:"rate"`  // Requests per second, 0 disables the scope
+	Burst int     `mapstructure:"burst"` // Burst size
+}
+
+// RateLimitConfig is read from the "ratelimit" key. Channels are keyed by
+// notification type, Tenants by tenant id (overriding Tenant).
+type RateLimitConfig struct {
+	Global         RateLimit            `mapstructure:"global"`
+	Recipient      RateLimit            `mapstructure:"recipient"`
+	Tenant         RateLimit            `mapstructure:"tenant"`
+	Channels       map[string]RateLimit `mapstructure:"channels"`
+	Tenants        map[string]RateLimit `mapstructure:"tenants"`
+	LocalCacheSize int                  `mapstructure:"local_cache_size"`
+}
+
+type RPCQuota struct {
+	IP    RateLimit `mapstructure:"ip"`
+	Email RateLimit `mapstructure:"email"`
+	User  RateLimit `mapstructure:"user"`
+}
+
+// AuthConfig is read from the "auth" key. At least one of JWT (a JWKS
+// source) or mTLS must be configured unless Disabled is set.
+type AuthConfig struct {
+	Disabled      bool     `mapstructure:"disabled"`
+	PublicMethods []string `mapstructure:"public_methods"`
+	JWT           struct {
+		JWKS            string        `mapstructure:"jwks"` // File path or http(s) URL
+		Issuer          string        `mapstructure:"issuer"`
+		Audience        string        `mapstructure:"audience"`
+		RolesClaim      string        `mapstructure:"roles_claim"`
+		TenantClaim     string        `mapstructure:"tenant_claim"` // Binds the caller to one tenant
+		RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	} `mapstructure:"jwt"`
+	TLS struct {
+		CertFile     string `mapstructure:"cert_file"`
+		KeyFile      string `mapstructure:"key_file"`
+		ClientCAFile string `mapstructure:"client_ca_file"` // Enables mTLS
+	} `mapstructure:"tls"`
+	AllowedServices []string `mapstructure:"allowed_services"` // mTLS identities, required with client_ca_file
+}
+
+// RetentionPolicy matches notifications by type, category or both.
+type RetentionPolicy struct {
+	Type     string        `mapstructure:"type"`     // Notification type
+	Category string        `mapstructure:"category"` // e.g. "security"
+	MaxAge   time.Duration `mapstructure:"max_age"`  // e.g. "2160h"
+	ReadOnly bool          `mapstructure:"read_only"`
+}
+
+// RetentionConfig is read from the "retention" key. The retention job runs
+// on one replica at a time, elected through a Postgres advisory lock.
+type RetentionConfig struct {
+	Interval          time.Duration     `mapstructure:"interval"`
+	LeaderRetry       time.Duration     `mapstructure:"leader_retry"`
+	Policies          []RetentionPolicy `mapstructure:"policies"`
+	PartitionMaxAge   time.Duration     `mapstructure:"partition_max_age"` // 0 keeps all partitions
+	ArchivePartitions bool              `mapstructure:"archive_partitions"`
+	PartitionsAhead   int               `mapstructure:"partitions_ahead"`
+	ProcessedMaxAge   time.Duration     `mapstructure:"processed_max_age"` // Keep above Kafka retention
+}
+
+// RPCQuotaConfig is read from the "grpc.quotas" key. Methods are keyed by
+// RPC name, e.g. "SendOTP".
+type RPCQuotaConfig struct {
+	TrustForwardedFor bool                `mapstructure:"trust_forwarded_for"`
+	Methods           map[string]RPCQuota `mapstructure:"methods"`
 }
 
 func LoadConfig(logger *zap.Logger) (*Config, error) {
@@ -23,6 +226,57 @@ func LoadConfig(logger *zap.Logger) (*Config, error) {
 	viper.AddConfigPath(".")
 	viper.AutomaticEnv()
 
+	viper.SetDefault("totp.issuer", "EduLearn")
+	viper.SetDefault("totp.skew", 1)
+	viper.SetDefault("magic_link.ttl", "15m")
+	viper.SetDefault("unread.reconcile_interval", "5m")
+	viper.SetDefault("scheduler.interval", "10s")
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("kafka.producer.flush_messages", 100)
+	viper.SetDefault("kafka.producer.flush_frequency", "10ms")
+	viper.SetDefault("kafka.event_format", "protobuf")
+	viper.SetDefault("kafka.client_id", "notification-service")
+	// Oldest, so a new group processes the backlog instead of skipping it
+	viper.SetDefault("kafka.consumer.initial_offset", "oldest")
+	viper.SetDefault("kafka.consumer.session_timeout", "10s")
+	viper.SetDefault("kafka.consumer.heartbeat_interval", "3s")
+	viper.SetDefault("kafka.consumer.rebalance_strategy", "roundrobin")
+	// Only email has a sender; add a channel's topic once it has one
+	viper.SetDefault("kafka.consumer.topics", []map[string]interface{}{
+		{"name": "email-notifications", "workers": 5, "max_attempts": MaxKafkaAttempts},
+	})
+	viper.SetDefault("rules.consumer_group", "notification-rules")
+	viper.SetDefault("search.language", "english")
+	// Helpful to not tag emails as spam (10 emails/sec per recipient, burst of 20)
+	viper.SetDefault("ratelimit.recipient.rate", 10)
+	viper.SetDefault("ratelimit.recipient.burst", 20)
+	viper.SetDefault("ratelimit.local_cache_size", 10000)
+	viper.SetDefault("retention.interval", "1h")
+	viper.SetDefault("retention.leader_retry", "30s")
+	viper.SetDefault("retention.partitions_ahead", 2)
+	viper.SetDefault("retention.partition_max_age", "8760h")
+	viper.SetDefault("retention.processed_max_age", "336h")
+	// Read in-app notifications are kept for 90 days, the redacted
+	// records of OTP, sign-in link and password reset emails for 30
+	viper.SetDefault("retention.policies", []map[string]interface{}{
+		{"type": "inapp", "max_age": "2160h", "read_only": true},
+		{"category": "security", "max_age": "720h"},
+	})
+	viper.SetDefault("auth.jwt.roles_claim", "roles")
+	viper.SetDefault("auth.jwt.tenant_claim", "tenant_id")
+	viper.SetDefault("auth.jwt.refresh_interval", "5m")
+	// Sends that reach arbitrary addresses default to roughly 3/hour per
+	// email and user, and a few per minute per IP
+	for _, method := range []string{"SendOTP", "ForgotPassword", "SendMagicLink"} {
+		prefix := "grpc.quotas.methods." + method
+		viper.SetDefault(prefix+".ip.rate", 0.1)
+		viper.SetDefault(prefix+".ip.burst", 10)
+		viper.SetDefault(prefix+".email.rate", 1.0/1200)
+		viper.SetDefault(prefix+".email.burst", 3)
+		viper.SetDefault(prefix+".user.rate", 1.0/1200)
+		viper.SetDefault(prefix+".user.burst", 3)
+	}
+
 	if err := viper.ReadInConfig(); err != nil {
 		logger.Error("Failed to read config", zap.Error(err))
 		return nil, err
@@ -38,7 +292,224 @@ func LoadConfig(logger *zap.Logger) (*Config, error) {
 		RedisAddr:     viper.GetString("redis.addr"),
 		GRpcPort:      viper.GetString("grpc.port"),
 		ConsumerGroup: viper.GetString("kafka.consumer_group"),
+
+		KafkaEventFormat: viper.GetString("kafka.event_format"),
+
+		RuleTopics:        viper.GetStringSlice("rules.topics"),
+		RuleConsumerGroup: viper.GetString("rules.consumer_group"),
+
+		OTPHashAlgorithm:       viper.GetString("otp.hash_algorithm"),
+		OTPPepper:              viper.GetString("otp.pepper"),
+		OTPPreviousPepper:      viper.GetString("otp.previous_pepper"),
+		OTPPreviousPepperUntil: viper.GetTime("otp.previous_pepper_until"),
+
+		TOTPIssuer: viper.GetString("totp.issuer"),
+		TOTPSkew:   viper.GetInt("totp.skew"),
+
+		TOTPEncryptionKey:         viper.GetString("totp.encryption_key"),
+		TOTPPreviousEncryptionKey: viper.GetString("totp.previous_encryption_key"),
+
+		MagicLinkSecret:  viper.GetString("magic_link.secret"),
+		MagicLinkBaseURL: viper.GetString("magic_link.base_url"),
+		MagicLinkTTL:     viper.GetDuration("magic_link.ttl"),
+
+		UnreadReconcileInterval: viper.GetDuration("unread.reconcile_interval"),
+		SchedulerInterval:       viper.GetDuration("scheduler.interval"),
+		IdempotencyTTL:          viper.GetDuration("idempotency.ttl"),
+
+		SearchLanguage: viper.GetString("search.language"),
+	}
+
+	if err := viper.UnmarshalKey("ratelimit", &cfg.RateLimits); err != nil {
+		logger.Error("Failed to read rate limit config", zap.Error(err))
+		return nil, err
+	}
+	if err := viper.UnmarshalKey("grpc.quotas", &cfg.RPCQuotas); err != nil {
+		logger.Error("Failed to read gRPC quota config", zap.Error(err))
+		return nil, err
+	}
+	if err := viper.UnmarshalKey("kafka.producer", &cfg.KafkaProducer); err != nil {
+		logger.Error("Failed to read Kafka producer config", zap.Error(err))
+		return nil, err
+	}
+	if err := viper.UnmarshalKey("kafka", &cfg.KafkaClient); err != nil {
+		logger.Error("Failed to read Kafka client config", zap.Error(err))
+		return nil, err
+	}
+	if err := viper.UnmarshalKey("kafka.consumer", &cfg.KafkaConsumer); err != nil {
+		logger.Error("Failed to read Kafka consumer config", zap.Error(err))
+		return nil, err
+	}
+	if err := validateKafka(cfg); err != nil {
+		logger.Error("Invalid Kafka config", zap.Error(err))
+		return nil, err
+	}
+	if err := viper.UnmarshalKey("retention", &cfg.Retention); err != nil {
+		logger.Error("Failed to read retention config", zap.Error(err))
+		return nil, err
+	}
+	if cfg.Retention.Interval <= 0 || cfg.Retention.LeaderRetry <= 0 {
+		logger.Error("Invalid retention schedule")
+		return nil, errors.New("retention.interval and retention.leader_retry must be positive")
+	}
+	for i, policy := range cfg.Retention.Policies {
+		if (policy.Type == "" && policy.Category == "") || policy.MaxAge <= 0 {
+			logger.Error("Invalid retention policy", zap.Int("index", i))
+			return nil, fmt.Errorf("retention.policies[%d] needs a type or category and a positive max_age", i)
+		}
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		logger.Error("Invalid idempotency TTL")
+		return nil, errors.New("idempotency.ttl must be positive")
+	}
+	if err := viper.UnmarshalKey("auth", &cfg.Auth); err != nil {
+		logger.Error("Failed to read auth config", zap.Error(err))
+		return nil, err
+	}
+	if !cfg.Auth.Disabled && cfg.Auth.JWT.JWKS == "" && cfg.Auth.TLS.ClientCAFile == "" {
+		logger.Error("No caller authentication configured")
+		return nil, errors.New("auth.jwt.jwks or auth.tls.client_ca_file is required (or set auth.disabled)")
+	}
+	if !cfg.Auth.Disabled && cfg.Auth.TLS.ClientCAFile != "" && len(cfg.Auth.AllowedServices) == 0 {
+		logger.Error("No mTLS identities allowed")
+		return nil, errors.New("auth.allowed_services is required with auth.tls.client_ca_file")
+	}
+
+	if err := loadTenants(cfg, logger); err != nil {
+		return nil, err
+	}
+
+	if !domain.IsSearchLanguage(cfg.SearchLanguage) {
+		logger.Error("Unsupported search language", zap.String("language", cfg.SearchLanguage))
+		return nil, fmt.Errorf("search.language %q is not a supported text search configuration", cfg.SearchLanguage)
+	}
+
+	if cfg.OTPPepper == "" {
+		logger.Error("Missing OTP pepper", zap.String("key", "otp.pepper"))
+		return nil, errors.New("otp.pepper is required")
+	}
+	if cfg.TOTPEncryptionKey == "" {
+		logger.Error("Missing TOTP encryption key", zap.String("key", "totp.encryption_key"))
+		return nil, errors.New("totp.encryption_key is required")
 	}
 
 	return cfg, nil
 }
+
+// validateKafka rejects Kafka settings the clients would fail on, or that
+// would silently consume nothing.
+func validateKafka(cfg *Config) error {
+	if len(cfg.KafkaBrokers) == 0 {
+		return errors.New("kafka.brokers is required")
+	}
+	if cfg.ConsumerGroup == "" {
+		return errors.New("kafka.consumer_group is required")
+	}
+	if cfg.KafkaClient.ClientID == "" {
+		return errors.New("kafka.client_id is required")
+	}
+
+	sasl := cfg.KafkaClient.SASL
+	switch sasl.Mechanism {
+	case "":
+	case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		if sasl.Username == "" || sasl.Password == "" {
+			return errors.New("kafka.sasl.username and kafka.sasl.password are required with a SASL mechanism")
+		}
+	default:
+		return fmt.Errorf("kafka.sasl.mechanism %q must be PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512", sasl.Mechanism)
+	}
+	tls := cfg.KafkaClient.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		return errors.New("kafka.tls.cert_file and kafka.tls.key_file must be set together")
+	}
+	if !tls.Enabled && (tls.CAFile != "" || tls.CertFile != "") {
+		return errors.New("kafka.tls files are set but kafka.tls.enabled is false")
+	}
+
+	consumer := cfg.KafkaConsumer
+	if consumer.InitialOffset != "oldest" && consumer.InitialOffset != "newest" {
+		return fmt.Errorf("kafka.consumer.initial_offset %q must be oldest or newest", consumer.InitialOffset)
+	}
+	switch consumer.RebalanceStrategy {
+	case "roundrobin", "range", "sticky":
+	default:
+		return fmt.Errorf("kafka.consumer.rebalance_strategy %q must be roundrobin, range or sticky", consumer.RebalanceStrategy)
+	}
+	// Kafka expects at least three heartbeats per session
+	if consumer.SessionTimeout <= 0 || consumer.HeartbeatInterval <= 0 || consumer.HeartbeatInterval*3 > consumer.SessionTimeout {
+		return errors.New("kafka.consumer.session_timeout and heartbeat_interval must be positive, with the session at least three heartbeats long")
+	}
+
+	if len(consumer.Topics) == 0 {
+		return errors.New("kafka.consumer.topics must list at least one topic")
+	}
+	seen := make(map[string]bool, len(consumer.Topics))
+	for i, topic := range consumer.Topics {
+		switch {
+		case topic.Name == "":
+			return fmt.Errorf("kafka.consumer.topics[%d].name is required", i)
+		case seen[topic.Name]:
+			return fmt.Errorf("kafka.consumer.topics lists %q twice", topic.Name)
+		case topic.Workers < 1:
+			return fmt.Errorf("kafka.consumer.topics[%d].workers must be at least 1", i)
+		case topic.MaxAttempts < 1 || topic.MaxAttempts > MaxKafkaAttempts:
+			return fmt.Errorf("kafka.consumer.topics[%d].max_attempts must be between 1 and %d", i, MaxKafkaAttempts)
+		}
+		seen[topic.Name] = true
+	}
+	return nil
+}
+
+func loadTenants(cfg *Config, logger *zap.Logger) error {
+	if err := viper.UnmarshalKey("tenants", &cfg.Tenants); err != nil {
+		logger.Error("Failed to read tenant config", zap.Error(err))
+		return err
+	}
+	if cfg.Tenants == nil {
+		cfg.Tenants = make(map[string]TenantConfig)
+	}
+
+	fallback := cfg.Tenants[domain.DefaultTenant]
+	if fallback.Name == "" {
+		fallback.Name = "EduLearn"
+	}
+	if fallback.Locale == "" {
+		fallback.Locale = domain.DefaultLocale
+	}
+	fallback.Branding = withDefaults(fallback.Branding, defaultBranding)
+	cfg.Tenants[domain.DefaultTenant] = fallback
+
+	for id, tenant := range cfg.Tenants {
+		if !tenantIDPattern.MatchString(id) {
+			logger.Error("Invalid tenant id", zap.String("tenant_id", id))
+			return fmt.Errorf("tenant id %q must be up to 64 lowercase letters, digits, '-' or '_'", id)
+		}
+		if tenant.SMTP.Host == "" && tenant.SMTP.Username != "" {
+			logger.Error("Tenant SMTP account has no host", zap.String("tenant_id", id))
+			return fmt.Errorf("tenants.%s.smtp.host is required when an SMTP username is set", id)
+		}
+		if tenant.Name == "" {
+			tenant.Name = id
+		}
+		if tenant.Locale == "" {
+			tenant.Locale = fallback.Locale
+		}
+		tenant.Branding = withDefaults(tenant.Branding, fallback.Branding)
+		cfg.Tenants[id] = tenant
+	}
+	return nil
+}
+
+// withDefaults returns values with any keys missing from it copied from
+// defaults.
+func withDefaults(values, defaults map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(values))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
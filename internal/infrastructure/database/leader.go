@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LeaderElector runs background work on one replica at a time. Leadership
+// is a session-level Postgres advisory lock held on a dedicated connection,
+// so it is released automatically if the leader dies.
+type LeaderElector struct {
+	db     *sql.DB
+	name   string
+	key    int64
+	retry  time.Duration
+	logger *zap.Logger
+}
+
+// NewLeaderElector creates an elector for the named job. Replicas using the
+// same name compete for the same lock; retry is how often followers try to
+// take over and how often the leader checks its connection.
+func NewLeaderElector(db *DB, name string, retry time.Duration, logger *zap.Logger) (*LeaderElector, error) {
+	sqlDB, err := db.DB().DB()
+	if err != nil {
+		logger.Error("Failed to get SQL DB", zap.Error(err))
+		return nil, err
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return &LeaderElector{
+		db:     sqlDB,
+		name:   name,
+		key:    int64(h.Sum64()),
+		retry:  retry,
+		logger: logger,
+	}, nil
+}
+
+// Run blocks until ctx is done, calling work whenever this replica becomes
+// leader. The context passed to work is cancelled when leadership is lost,
+// and work must return promptly when it is.
+func (l *LeaderElector) Run(ctx context.Context, work func(ctx context.Context)) {
+	for {
+		if err := l.lead(ctx, work); err != nil && ctx.Err() == nil {
+			l.logger.Error("Leader election failed", zap.String("job", l.name), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(l.retry):
+		}
+	}
+}
+
+func (l *LeaderElector) lead(ctx context.Context, work func(ctx context.Context)) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil {
+		return err
+	}
+	if !acquired {
+		return nil
+	}
+	l.logger.Info("Acquired leadership", zap.String("job", l.name))
+	defer l.release(conn)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(l.retry)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-leaderCtx.Done():
+				return
+			case <-ticker.C:
+				if err := conn.PingContext(leaderCtx); err != nil && leaderCtx.Err() == nil {
+					l.logger.Warn("Lost leader connection", zap.String("job", l.name), zap.Error(err))
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	work(leaderCtx)
+	return nil
+}
+
+func (l *LeaderElector) release(conn *sql.Conn) {
+	// ctx may already be cancelled, so unlock with a fresh one
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+		l.logger.Warn("Failed to release leadership, discarding connection", zap.String("job", l.name), zap.Error(err))
+		// A pooled connection would keep holding the lock; closing the
+		// session releases it
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		return
+	}
+	l.logger.Info("Released leadership", zap.String("job", l.name))
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Shafeeqth/notification-service/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrSchemaDirty  = errors.New("database schema is dirty, a migration failed part way; fix it and run \"migrate force\"")
+	ErrSchemaBehind = errors.New("database schema is behind, run \"migrate up\"")
+)
+
+// SchemaStatus describes the applied migrations relative to the embedded ones.
+type SchemaStatus struct {
+	Version uint // Applied version, 0 if none
+	Dirty   bool
+	Latest  uint // Newest embedded migration
+}
+
+// Migrator applies the SQL migrations embedded in the migrations package.
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+	logger *zap.Logger
+}
+
+// NewMigrator creates a migrator on a dedicated connection from db, which
+// also holds the migration lock. Close releases it.
+func NewMigrator(ctx context.Context, db *DB, logger *zap.Logger) (*Migrator, error) {
+	sqlDB, err := db.DB().DB()
+	if err != nil {
+		logger.Error("Failed to get SQL DB", zap.Error(err))
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		logger.Error("Failed to get migration connection", zap.Error(err))
+		return nil, err
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		logger.Error("Failed to initialize migration driver", zap.Error(err))
+		return nil, err
+	}
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		driver.Close()
+		logger.Error("Failed to load migrations", zap.Error(err))
+		return nil, err
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		logger.Error("Failed to initialize migrations", zap.Error(err))
+		return nil, err
+	}
+	m.Log = migrateLogger{logger}
+	return &Migrator{m: m, source: src, logger: logger}, nil
+}
+
+// Up applies all pending migrations.
+func (mg *Migrator) Up() error {
+	if err := mg.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		mg.logger.Error("Failed to apply migrations", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// Down rolls back the given number of migrations.
+func (mg *Migrator) Down(steps int) error {
+	if err := mg.m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		mg.logger.Error("Failed to roll back migrations", zap.Int("steps", steps), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// Force records version as applied and clears the dirty flag without
+// running anything, after a failed migration has been fixed by hand.
+func (mg *Migrator) Force(version int) error {
+	if err := mg.m.Force(version); err != nil {
+		mg.logger.Error("Failed to force migration version", zap.Int("version", version), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (mg *Migrator) Status() (SchemaStatus, error) {
+	var status SchemaStatus
+	version, dirty, err := mg.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		mg.logger.Error("Failed to get migration version", zap.Error(err))
+		return status, err
+	}
+	status.Version, status.Dirty = version, dirty
+
+	latest, err := mg.source.First()
+	for err == nil {
+		status.Latest = latest
+		latest, err = mg.source.Next(latest)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		mg.logger.Error("Failed to read migrations", zap.Error(err))
+		return status, err
+	}
+	return status, nil
+}
+
+// Check returns ErrSchemaDirty or ErrSchemaBehind unless the database is
+// at the latest embedded migration.
+func (mg *Migrator) Check() error {
+	status, err := mg.Status()
+	if err != nil {
+		return err
+	}
+	if status.Dirty {
+		return fmt.Errorf("%w (version %d)", ErrSchemaDirty, status.Version)
+	}
+	if status.Version < status.Latest {
+		return fmt.Errorf("%w (at %d, latest %d)", ErrSchemaBehind, status.Version, status.Latest)
+	}
+	return nil
+}
+
+func (mg *Migrator) Close() error {
+	sourceErr, dbErr := mg.m.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+type migrateLogger struct {
+	logger *zap.Logger
+}
+
+func (l migrateLogger) Printf(format string, v ...interface{}) {
+	l.logger.Info(fmt.Sprintf(format, v...))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The notifications table is range partitioned by created_at into monthly
+// partitions named after the month they hold, e.g. notifications_y2025m04.
+const partitionNameLayout = "notifications_y2006m01"
+
+type partition struct {
+	name string
+	from time.Time
+	to   time.Time
+}
+
+func monthPartition(t time.Time) partition {
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return partition{
+		name: from.Format(partitionNameLayout),
+		from: from,
+		to:   from.AddDate(0, 1, 0),
+	}
+}
+
+// isPartitioned reports whether notifications is a partitioned table. It is
+// a plain table until the partitioning migration has run.
+func isPartitioned(ctx context.Context, db *gorm.DB) (bool, error) {
+	var kind string
+	err := db.WithContext(ctx).
+		Raw("SELECT relkind FROM pg_class WHERE oid = to_regclass('notifications')").
+		Scan(&kind).Error
+	return kind == "p", err
+}
+
+// ensurePartitions creates the partition for now's month and the given
+// number of following months.
+func ensurePartitions(ctx context.Context, db *gorm.DB, now time.Time, ahead int) error {
+	for i := 0; i <= ahead; i++ {
+		p := monthPartition(now.AddDate(0, i, 0))
+		sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF notifications FOR VALUES FROM ('%s') TO ('%s')",
+			p.name, p.from.Format(time.RFC3339), p.to.Format(time.RFC3339))
+		if err := db.WithContext(ctx).Exec(sql).Error; err != nil {
+			return fmt.Errorf("create partition %s: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+// listPartitions returns the attached monthly partitions of notifications.
+// Partitions not following the naming scheme are ignored.
+func listPartitions(ctx context.Context, db *gorm.DB) ([]partition, error) {
+	var names []string
+	if err := db.WithContext(ctx).Raw(`SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass('notifications')
+		ORDER BY c.relname`).Scan(&names).Error; err != nil {
+		return nil, err
+	}
+
+	partitions := make([]partition, 0, len(names))
+	for _, name := range names {
+		month, err := time.Parse(partitionNameLayout, name)
+		if err != nil {
+			continue
+		}
+		partitions = append(partitions, monthPartition(month))
+	}
+	return partitions, nil
+}
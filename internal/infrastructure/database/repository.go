@@ -10,49 +10,60 @@ import (
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// searchHeadlineOptions configure ts_headline snippets.
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
 type Repository struct {
-	db     *gorm.DB
-	sender *notification.NotificationSender
-	redis  *redis.RedisClient
-	logger *zap.Logger
+	db             *gorm.DB
+	sender         *notification.NotificationSender
+	redis          *redis.RedisClient
+	searchLanguage string
+	logger         *zap.Logger
 }
 
-func NewRepository(db *DB, sender *notification.NotificationSender, redisClient *redis.RedisClient, logger *zap.Logger) *Repository {
+// NewRepository creates the repository. searchLanguage is the text search
+// configuration used for notifications and queries that don't specify one.
+func NewRepository(db *DB, sender *notification.NotificationSender, redisClient *redis.RedisClient, searchLanguage string, logger *zap.Logger) *Repository {
 	return &Repository{
-		db:     db.DB(),
-		sender: sender,
-		redis:  redisClient,
-		logger: logger,
-	}
-}
-
-func (r *Repository) AutoMigrate() error {
-	if err := r.db.AutoMigrate(&domain.Notification{}, &domain.ProcessedNotification{}); err != nil {
-		r.logger.Error("Failed to auto-migrate database", zap.Error(err))
-		return err
+		db:             db.DB(),
+		sender:         sender,
+		redis:          redisClient,
+		searchLanguage: searchLanguage,
+		logger:         logger,
 	}
-	r.logger.Info("Database migration completed")
-	return nil
 }
 
 func (r *Repository) SaveNotification(ctx context.Context, notification domain.Notification) error {
 	if notification.ID == "" {
 		notification.ID = uuid.New().String()
 	}
+	if notification.TenantId == "" {
+		notification.TenantId = domain.TenantFromContext(ctx)
+	}
+	if notification.SearchConfig == "" {
+		notification.SearchConfig = r.searchLanguage
+	}
 	if err := r.db.WithContext(ctx).Create(&notification).Error; err != nil {
 		r.logger.Error("Failed to save notification", zap.Error(err))
 		return domain.ErrDatabase
 	}
 	r.logger.Info("Notification saved", zap.String("id", notification.ID))
+
+	// Cache errors are logged by the client; the reconciler corrects drift.
+	// Scheduled notifications are counted once claimed.
+	if !notification.IsRead && (notification.SendAt == nil || !notification.SendAt.After(time.Now())) {
+		r.redis.IncrUnread(ctx, notification.TenantId, notification.UserId, notification.Category, 1)
+	}
 	return nil
 }
 
 func (r *Repository) GetANotification(ctx context.Context, notificationID, userID string) (*domain.Notification, error) {
 	var notification domain.Notification
 	if err := r.db.WithContext(ctx).
-		Where("id = ? AND user_id = ?", notificationID, userID).
+		Where("tenant_id = ? AND id = ? AND user_id = ? AND deleted_at IS NULL", domain.TenantFromContext(ctx), notificationID, userID).
 		First(&notification).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			r.logger.Warn("Notification not found",
@@ -68,43 +79,144 @@ func (r *Repository) GetANotification(ctx context.Context, notificationID, userI
 	return &notification, nil
 }
 
-func (r *Repository) GetAllNotifications(ctx context.Context, userId string, page, pageSize int, isRead *bool, notifyType *string) ([]domain.Notification, int64, error) {
+func (r *Repository) GetAllNotifications(ctx context.Context, userId string, filter domain.NotificationFilter, after *domain.PageCursor, pageSize int) ([]domain.Notification, *domain.PageCursor, int64, error) {
 	var notifications []domain.Notification
 	var total int64
 
-	query := r.db.WithContext(ctx).Model(&domain.Notification{}).Where("user_id = ?", userId)
-	if isRead != nil {
-		query = query.Where("is_read = ?", *isRead)
-	}
+	query := filterNotifications(r.db.WithContext(ctx).Model(&domain.Notification{}), domain.TenantFromContext(ctx), userId, filter)
+
+	// New session so the count and page queries don't share statement state
+	query = query.Session(&gorm.Session{})
 
 	// Get total count
 	if err := query.Count(&total).Error; err != nil {
 		r.logger.Error("Failed to count notifications",
 			zap.String("user_id", userId),
 			zap.Error(err))
-		return nil, 0, domain.ErrDatabase
+		return nil, nil, 0, domain.ErrDatabase
 	}
 
-	// Apply pagination
-	offset := (page - 1) * pageSize
+	// Apply keyset pagination; one extra row tells us whether a next page exists
+	if after != nil {
+		query = query.Where("(pinned, created_at, id) < (?, ?, ?)", after.Pinned, after.CreatedAt, after.ID)
+	}
 	if err := query.
-		Order("created_at DESC").
-		Offset(offset).
-		Limit(pageSize).
+		Order("pinned DESC, created_at DESC, id DESC").
+		Limit(pageSize + 1).
 		Find(&notifications).Error; err != nil {
 		r.logger.Error("Failed to get notifications",
 			zap.String("user_id", userId),
 			zap.Error(err))
-		return nil, 0, domain.ErrDatabase
+		return nil, nil, 0, domain.ErrDatabase
+	}
+
+	var next *domain.PageCursor
+	if len(notifications) > pageSize {
+		notifications = notifications[:pageSize]
+		last := notifications[pageSize-1]
+		next = &domain.PageCursor{Pinned: last.Pinned, CreatedAt: last.CreatedAt, ID: last.ID}
 	}
-	return notifications, total, nil
+	return notifications, next, total, nil
+
+}
 
+// filterNotifications restricts query to the user's live notifications
+// matching filter.
+func filterNotifications(query *gorm.DB, tenantId, userId string, filter domain.NotificationFilter) *gorm.DB {
+	query = query.Where("tenant_id = ? AND user_id = ? AND deleted_at IS NULL", tenantId, userId)
+	if filter.IsRead != nil {
+		query = query.Where("is_read = ?", *filter.IsRead)
+	}
+	if filter.Type != nil {
+		query = query.Where("type = ?", *filter.Type)
+	}
+	if filter.Category != nil {
+		query = query.Where("category = ?", *filter.Category)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.Archived != nil {
+		if *filter.Archived {
+			query = query.Where("archived_at IS NOT NULL")
+		} else {
+			query = query.Where("archived_at IS NULL")
+		}
+	}
+	if filter.Pinned != nil {
+		query = query.Where("pinned = ?", *filter.Pinned)
+	}
+	if !filter.IncludeSnoozed {
+		query = query.Where("snoozed_until IS NULL OR snoozed_until <= ?", time.Now())
+	}
+	// Scheduled notifications don't exist for the user until they are due
+	return query.Where("send_at IS NULL OR send_at <= ?", time.Now())
+}
+
+func (r *Repository) SearchNotifications(ctx context.Context, userID string, search domain.SearchQuery, offset, limit int) ([]domain.SearchResult, bool, error) {
+	language := search.Language
+	if language == "" {
+		language = r.searchLanguage
+	}
+
+	// Rank and page first so snippets are only built for the returned rows
+	ranked := filterNotifications(
+		r.db.WithContext(ctx).Table("notifications, websearch_to_tsquery(?::regconfig, ?) AS query", language, search.Text),
+		domain.TenantFromContext(ctx), userID, search.Filter).
+		Select("notifications.*, query, ts_rank_cd(search_vector, query) AS rank").
+		Where("search_vector @@ query").
+		Order("rank DESC, created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit + 1)
+
+	var rows []struct {
+		domain.Notification
+		Rank           float64
+		SubjectSnippet string
+		BodySnippet    string
+	}
+	if err := r.db.WithContext(ctx).
+		Table("(?) AS ranked", ranked).
+		Select(`ranked.*,
+			ts_headline(?::regconfig, coalesce(subject, ''), query, ?) AS subject_snippet,
+			ts_headline(?::regconfig, coalesce(body, ''), query, ?) AS body_snippet`,
+			language, searchHeadlineOptions, language, searchHeadlineOptions).
+		Order("rank DESC, created_at DESC, id DESC").
+		Scan(&rows).Error; err != nil {
+		r.logger.Error("Failed to search notifications",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return nil, false, domain.ErrDatabase
+	}
+
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	results := make([]domain.SearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = domain.SearchResult{
+			Notification:   row.Notification,
+			Rank:           row.Rank,
+			SubjectSnippet: row.SubjectSnippet,
+			BodySnippet:    row.BodySnippet,
+		}
+	}
+	return results, more, nil
 }
 
 func (r *Repository) MarkAsRead(ctx context.Context, notificationID, userID string) error {
+	tenantID := domain.TenantFromContext(ctx)
+
+	// Only unread rows are updated so the unread counter is decremented once
+	var updated domain.Notification
 	result := r.db.WithContext(ctx).
-		Model(&domain.Notification{}).
-		Where("id = ? AND user_id = ?", notificationID, userID).
+		Model(&updated).
+		Clauses(clause.Returning{}).
+		Where("tenant_id = ? AND id = ? AND user_id = ? AND is_read = ? AND deleted_at IS NULL", tenantID, notificationID, userID, false).
 		Update("is_read", true)
 	if result.Error != nil {
 		r.logger.Error("Failed to mark notification as read",
@@ -112,7 +224,26 @@ func (r *Repository) MarkAsRead(ctx context.Context, notificationID, userID stri
 			zap.Error(result.Error))
 		return domain.ErrDatabase
 	}
-	if result.RowsAffected == 0 {
+	if result.RowsAffected == 1 {
+		// Archived and snoozed notifications are not part of the badge
+		if updated.ArchivedAt == nil && (updated.SnoozedUntil == nil || !updated.SnoozedUntil.After(time.Now())) {
+			r.redis.IncrUnread(ctx, tenantID, userID, updated.Category, -1)
+		}
+		return nil
+	}
+
+	// Nothing updated: either already read or not this user's notification
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Notification{}).
+		Where("tenant_id = ? AND id = ? AND user_id = ? AND deleted_at IS NULL", tenantID, notificationID, userID).
+		Count(&count).Error; err != nil {
+		r.logger.Error("Failed to check notification",
+			zap.String("notification_id", notificationID),
+			zap.Error(err))
+		return domain.ErrDatabase
+	}
+	if count == 0 {
 		r.logger.Warn("Notification not found or unauthorized",
 			zap.String("notification_id", notificationID),
 			zap.String("user_id", userID))
@@ -122,9 +253,11 @@ func (r *Repository) MarkAsRead(ctx context.Context, notificationID, userID stri
 }
 
 func (r *Repository) MarkAllAsRead(ctx context.Context, userID string) error {
+	tenantID := domain.TenantFromContext(ctx)
 	result := r.db.WithContext(ctx).
 		Model(&domain.Notification{}).
-		Where("user_id = ? AND is_read = ?", userID, false).
+		Where("tenant_id = ? AND user_id = ? AND is_read = ? AND deleted_at IS NULL", tenantID, userID, false).
+		Where("snoozed_until IS NULL OR snoozed_until <= ?", time.Now()).
 		Update("is_read", true)
 	if result.Error != nil {
 		r.logger.Error("Failed to mark all notifications as read",
@@ -135,34 +268,413 @@ func (r *Repository) MarkAllAsRead(ctx context.Context, userID string) error {
 	r.logger.Info("Marked all notifications as read",
 		zap.String("user_id", userID),
 		zap.Int64("rows_affected", result.RowsAffected))
+
+	// Invalidate rather than zero the counter, so notifications saved since
+	// the update are not lost from it
+	if result.RowsAffected > 0 {
+		r.redis.InvalidateUnread(ctx, tenantID, userID)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteNotifications(ctx context.Context, userID string, notificationIDs []string) (int64, error) {
+	return r.updateNotifications(ctx, "delete", userID, notificationIDs, map[string]interface{}{"deleted_at": time.Now()})
+}
+
+func (r *Repository) ArchiveNotifications(ctx context.Context, userID string, notificationIDs []string, archived bool) (int64, error) {
+	var archivedAt *time.Time
+	if archived {
+		now := time.Now()
+		archivedAt = &now
+	}
+	return r.updateNotifications(ctx, "archive", userID, notificationIDs, map[string]interface{}{"archived_at": archivedAt})
+}
+
+func (r *Repository) SnoozeNotifications(ctx context.Context, userID string, notificationIDs []string, until time.Time) (int64, error) {
+	return r.updateNotifications(ctx, "snooze", userID, notificationIDs, map[string]interface{}{"snoozed_until": until, "is_read": false})
+}
+
+func (r *Repository) PinNotifications(ctx context.Context, userID string, notificationIDs []string, pinned bool) (int64, error) {
+	return r.updateNotifications(ctx, "pin", userID, notificationIDs, map[string]interface{}{"pinned": pinned})
+}
+
+// updateNotifications applies a lifecycle change to the user's live
+// notifications among notificationIDs. Any of these changes can move a
+// notification in or out of the unread badge, so the cached count is
+// invalidated rather than adjusted.
+func (r *Repository) updateNotifications(ctx context.Context, action, userID string, notificationIDs []string, updates map[string]interface{}) (int64, error) {
+	tenantID := domain.TenantFromContext(ctx)
+	result := r.db.WithContext(ctx).
+		Model(&domain.Notification{}).
+		Where("tenant_id = ? AND user_id = ? AND id IN ? AND deleted_at IS NULL", tenantID, userID, notificationIDs).
+		Updates(updates)
+	if result.Error != nil {
+		r.logger.Error("Failed to "+action+" notifications",
+			zap.String("user_id", userID),
+			zap.Strings("notification_ids", notificationIDs),
+			zap.Error(result.Error))
+		return 0, domain.ErrDatabase
+	}
+	if result.RowsAffected > 0 {
+		r.redis.InvalidateUnread(ctx, tenantID, userID)
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *Repository) GetUnreadCount(ctx context.Context, userID string) (domain.UnreadCount, error) {
+	tenantID := domain.TenantFromContext(ctx)
+	if count, found, err := r.redis.GetUnread(ctx, tenantID, userID); err == nil && found {
+		return count, nil
+	}
+
+	count, err := r.countUnread(ctx, tenantID, userID)
+	if err != nil {
+		return domain.UnreadCount{}, err
+	}
+	r.redis.SetUnread(ctx, tenantID, userID, count)
+	return count, nil
+}
+
+func (r *Repository) WatchUnreadCount(ctx context.Context, userID string) (<-chan struct{}, error) {
+	changes, err := r.redis.SubscribeUnread(ctx, domain.TenantFromContext(ctx), userID)
+	if err != nil {
+		return nil, domain.ErrDatabase
+	}
+	return changes, nil
+}
+
+func (r *Repository) ReconcileUnreadCounts(ctx context.Context) error {
+	users, err := r.redis.UnreadUsers(ctx)
+	if err != nil {
+		return domain.ErrDatabase
+	}
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		count, err := r.countUnread(ctx, user.TenantId, user.UserId)
+		if err != nil {
+			return err
+		}
+		r.redis.SetUnread(ctx, user.TenantId, user.UserId, count)
+	}
+	r.logger.Info("Reconciled unread counters", zap.Int("users", len(users)))
 	return nil
 }
 
-func (r *Repository) CheckIfProcessed(ctx context.Context, notificationID string) (bool, error) {
-	var processed domain.ProcessedNotification
+func (r *Repository) countUnread(ctx context.Context, tenantID, userID string) (domain.UnreadCount, error) {
+	var rows []struct {
+		Category string
+		Count    int64
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Notification{}).
+		Select("category, COUNT(*) AS count").
+		Where("tenant_id = ? AND user_id = ? AND is_read = ? AND deleted_at IS NULL AND archived_at IS NULL", tenantID, userID, false).
+		Where("snoozed_until IS NULL OR snoozed_until <= ?", time.Now()).
+		Where("send_at IS NULL OR send_at <= ?", time.Now()).
+		Group("category").
+		Scan(&rows).Error; err != nil {
+		r.logger.Error("Failed to count unread notifications",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return domain.UnreadCount{}, domain.ErrDatabase
+	}
+
+	count := domain.UnreadCount{ByCategory: make(map[string]int64)}
+	for _, row := range rows {
+		count.Total += row.Count
+		if row.Category != "" {
+			count.ByCategory[row.Category] = row.Count
+		}
+	}
+	return count, nil
+}
+
+func (r *Repository) ClaimScheduledNotifications(ctx context.Context, limit int) ([]domain.Notification, error) {
+	// SKIP LOCKED lets replicas claim disjoint batches without waiting
+	var claimed []domain.Notification
+	if err := r.db.WithContext(ctx).Raw(`
+		UPDATE notifications SET queued_at = now()
+		WHERE (id, created_at) IN (
+			SELECT id, created_at FROM notifications
+			WHERE send_at <= now() AND queued_at IS NULL AND deleted_at IS NULL
+			ORDER BY send_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED)
+		RETURNING *`, limit).
+		Scan(&claimed).Error; err != nil {
+		r.logger.Error("Failed to claim scheduled notifications", zap.Error(err))
+		return nil, domain.ErrDatabase
+	}
+
+	// The notifications just became visible
+	for _, notification := range claimed {
+		if !notification.IsRead {
+			r.redis.InvalidateUnread(ctx, notification.TenantId, notification.UserId)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *Repository) ReleaseScheduledNotification(ctx context.Context, notification domain.Notification) error {
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Notification{}).
+		Where("id = ? AND created_at = ?", notification.ID, notification.CreatedAt).
+		Update("queued_at", nil).Error; err != nil {
+		r.logger.Error("Failed to release scheduled notification",
+			zap.String("notification_id", notification.ID),
+			zap.Error(err))
+		return domain.ErrDatabase
+	}
+	return nil
+}
+
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, key domain.IdempotencyKey, staleBefore time.Time) (domain.IdempotencyKey, bool, error) {
+	key.TenantId = domain.TenantFromContext(ctx)
+	key.CompletedAt = nil
+
+	// The primary key serialises concurrent reservations; only an expired
+	// or abandoned key is taken over
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "caller"}, {Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"request_hash", "notifications", "created_at", "completed_at", "expires_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{clause.Expr{
+				SQL:  "idempotency_keys.expires_at <= ? OR (idempotency_keys.completed_at IS NULL AND idempotency_keys.created_at < ?)",
+				Vars: []interface{}{time.Now(), staleBefore},
+			}}},
+		}).
+		Create(&key)
+	if result.Error != nil {
+		r.logger.Error("Failed to reserve idempotency key", zap.Error(result.Error))
+		return domain.IdempotencyKey{}, false, domain.ErrDatabase
+	}
+	if result.RowsAffected > 0 {
+		return key, true, nil
+	}
+
+	var existing domain.IdempotencyKey
 	err := r.db.WithContext(ctx).
-		Where("notification_id = ?", notificationID).
-		First(&processed).Error
+		Where("tenant_id = ? AND caller = ? AND key = ?", key.TenantId, key.Caller, key.Key).
+		First(&existing).Error
 	if err == gorm.ErrRecordNotFound {
-		return false, nil
+		// Released between the insert and this read
+		return domain.IdempotencyKey{}, false, domain.ErrIdempotencyKeyInProgress
 	}
 	if err != nil {
-		r.logger.Error("Failed to check if notification processed",
-			zap.String("notification_id", notificationID),
+		r.logger.Error("Failed to get idempotency key", zap.Error(err))
+		return domain.IdempotencyKey{}, false, domain.ErrDatabase
+	}
+	return existing, false, nil
+}
+
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error {
+	now := time.Now()
+	// A struct update so the notifications go through their JSON serializer
+	if err := r.db.WithContext(ctx).
+		Model(&domain.IdempotencyKey{}).
+		Where("tenant_id = ? AND caller = ? AND key = ?", domain.TenantFromContext(ctx), key.Caller, key.Key).
+		Select("notifications", "completed_at").
+		Updates(&domain.IdempotencyKey{Notifications: key.Notifications, CompletedAt: &now}).Error; err != nil {
+		r.logger.Error("Failed to complete idempotency key", zap.Error(err))
+		return domain.ErrDatabase
+	}
+	return nil
+}
+
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error {
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND caller = ? AND key = ? AND completed_at IS NULL", domain.TenantFromContext(ctx), key.Caller, key.Key).
+		Delete(&domain.IdempotencyKey{}).Error; err != nil {
+		r.logger.Error("Failed to release idempotency key", zap.Error(err))
+		return domain.ErrDatabase
+	}
+	return nil
+}
+
+func (r *Repository) CreateRule(ctx context.Context, rule domain.NotificationRule) (domain.NotificationRule, error) {
+	rule.ID = uuid.New().String()
+	rule.TenantId = domain.TenantFromContext(ctx)
+	if err := r.db.WithContext(ctx).Create(&rule).Error; err != nil {
+		r.logger.Error("Failed to create notification rule", zap.Error(err))
+		return domain.NotificationRule{}, domain.ErrDatabase
+	}
+	return rule, nil
+}
+
+func (r *Repository) UpdateRule(ctx context.Context, rule domain.NotificationRule) (domain.NotificationRule, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.NotificationRule{}).
+		Where("tenant_id = ? AND id = ?", domain.TenantFromContext(ctx), rule.ID).
+		Select("*").Omit("id", "tenant_id", "created_at").
+		Updates(&rule)
+	if result.Error != nil {
+		r.logger.Error("Failed to update notification rule",
+			zap.String("rule_id", rule.ID),
+			zap.Error(result.Error))
+		return domain.NotificationRule{}, domain.ErrDatabase
+	}
+	if result.RowsAffected == 0 {
+		return domain.NotificationRule{}, domain.ErrRuleNotFound
+	}
+	return r.GetRule(ctx, rule.ID)
+}
+
+func (r *Repository) DeleteRule(ctx context.Context, ruleID string) error {
+	result := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND id = ?", domain.TenantFromContext(ctx), ruleID).
+		Delete(&domain.NotificationRule{})
+	if result.Error != nil {
+		r.logger.Error("Failed to delete notification rule",
+			zap.String("rule_id", ruleID),
+			zap.Error(result.Error))
+		return domain.ErrDatabase
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrRuleNotFound
+	}
+	return nil
+}
+
+func (r *Repository) GetRule(ctx context.Context, ruleID string) (domain.NotificationRule, error) {
+	var rule domain.NotificationRule
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND id = ?", domain.TenantFromContext(ctx), ruleID).
+		First(&rule).Error
+	if err == gorm.ErrRecordNotFound {
+		return domain.NotificationRule{}, domain.ErrRuleNotFound
+	}
+	if err != nil {
+		r.logger.Error("Failed to get notification rule",
+			zap.String("rule_id", ruleID),
 			zap.Error(err))
-		return false, domain.ErrDatabase
+		return domain.NotificationRule{}, domain.ErrDatabase
 	}
-	return true, nil
+	return rule, nil
 }
 
-func (r *Repository) MarkAsProcessed(ctx context.Context, notificationID string) error {
-	processed := domain.ProcessedNotification{
+func (r *Repository) ListRules(ctx context.Context, eventType string, enabledOnly bool) ([]domain.NotificationRule, error) {
+	query := r.db.WithContext(ctx).Where("tenant_id = ?", domain.TenantFromContext(ctx))
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+	if enabledOnly {
+		query = query.Where("enabled")
+	}
+	var rules []domain.NotificationRule
+	if err := query.Order("created_at, id").Find(&rules).Error; err != nil {
+		r.logger.Error("Failed to list notification rules",
+			zap.String("event_type", eventType),
+			zap.Error(err))
+		return nil, domain.ErrDatabase
+	}
+	return rules, nil
+}
+
+func (r *Repository) ClaimNotification(ctx context.Context, notificationID, owner string, lease time.Duration) (domain.ClaimOutcome, error) {
+	now := time.Now()
+	expires := now.Add(lease)
+	claim := domain.ProcessedNotification{
 		NotificationId: notificationID,
-		CreatedAt:      time.Now(),
+		Owner:          owner,
+		LeaseExpiresAt: &expires,
+		CreatedAt:      now,
+	}
+
+	// The insert and the takeover of an expired lease are one statement, so
+	// concurrent consumers cannot both acquire the claim
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "notification_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"owner", "lease_expires_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{clause.Expr{
+				SQL:  "processed_notifications.processed_at IS NULL AND processed_notifications.lease_expires_at < ?",
+				Vars: []interface{}{now},
+			}}},
+		}).
+		Create(&claim)
+	if result.Error != nil {
+		r.logger.Error("Failed to claim notification",
+			zap.String("notification_id", notificationID),
+			zap.Error(result.Error))
+		return 0, domain.ErrDatabase
+	}
+	if result.RowsAffected > 0 {
+		return domain.ClaimAcquired, nil
+	}
+
+	var existing domain.ProcessedNotification
+	err := r.db.WithContext(ctx).
+		Where("notification_id = ?", notificationID).
+		First(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		// Released since the insert; the caller retries
+		return domain.ClaimHeld, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get notification claim",
+			zap.String("notification_id", notificationID),
+			zap.Error(err))
+		return 0, domain.ErrDatabase
 	}
-	if err := r.db.WithContext(ctx).Create(&processed).Error; err != nil {
+	if existing.ProcessedAt != nil {
+		return domain.ClaimProcessed, nil
+	}
+	return domain.ClaimHeld, nil
+}
+
+func (r *Repository) CompleteNotification(ctx context.Context, notificationID, owner string) error {
+	result := r.db.WithContext(ctx).
+		Model(&domain.ProcessedNotification{}).
+		Where("notification_id = ? AND owner = ? AND processed_at IS NULL", notificationID, owner).
+		Updates(map[string]interface{}{"processed_at": time.Now(), "lease_expires_at": nil})
+	if result.Error != nil {
 		r.logger.Error("Failed to mark notification as processed",
 			zap.String("notification_id", notificationID),
+			zap.Error(result.Error))
+		return domain.ErrDatabase
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrClaimLost
+	}
+	return nil
+}
+
+func (r *Repository) ReleaseNotification(ctx context.Context, notificationID, owner string) error {
+	if err := r.db.WithContext(ctx).
+		Where("notification_id = ? AND owner = ? AND processed_at IS NULL", notificationID, owner).
+		Delete(&domain.ProcessedNotification{}).Error; err != nil {
+		r.logger.Error("Failed to release notification claim",
+			zap.String("notification_id", notificationID),
+			zap.Error(err))
+		return domain.ErrDatabase
+	}
+	return nil
+}
+
+func (r *Repository) GetPreferences(ctx context.Context, userID string) (domain.UserPreferences, error) {
+	var preferences domain.UserPreferences
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ?", domain.TenantFromContext(ctx), userID).
+		First(&preferences).Error
+	if err == gorm.ErrRecordNotFound {
+		return domain.UserPreferences{TenantId: domain.TenantFromContext(ctx), UserId: userID}, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get user preferences",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return domain.UserPreferences{}, domain.ErrDatabase
+	}
+	return preferences, nil
+}
+
+func (r *Repository) SavePreferences(ctx context.Context, preferences domain.UserPreferences) error {
+	preferences.TenantId = domain.TenantFromContext(ctx)
+	if err := r.db.WithContext(ctx).Save(&preferences).Error; err != nil {
+		r.logger.Error("Failed to save user preferences",
+			zap.String("user_id", preferences.UserId),
 			zap.Error(err))
 		return domain.ErrDatabase
 	}
@@ -171,6 +683,7 @@ func (r *Repository) MarkAsProcessed(ctx context.Context, notificationID string)
 
 func (r *Repository) SendEmail(ctx context.Context, recipient, subject, body string) error {
 	notification := domain.Notification{
+		TenantId:  domain.TenantFromContext(ctx),
 		Recipient: recipient,
 		Subject:   subject,
 		Body:      body,
@@ -180,9 +693,71 @@ func (r *Repository) SendEmail(ctx context.Context, recipient, subject, body str
 }
 
 func (r *Repository) SaveOTP(ctx context.Context, otp domain.OTP) error {
+	if otp.TenantId == "" {
+		otp.TenantId = domain.TenantFromContext(ctx)
+	}
 	return r.redis.SaveOTP(ctx, otp)
 }
 
 func (r *Repository) GetOTP(ctx context.Context, email string) (domain.OTP, error) {
-	return r.redis.GetOTP(ctx, email)
+	return r.redis.GetOTP(ctx, domain.TenantFromContext(ctx), email)
+}
+
+func (r *Repository) ConsumeOTP(ctx context.Context, email string) (bool, error) {
+	return r.redis.ConsumeOTP(ctx, domain.TenantFromContext(ctx), email)
+}
+
+func (r *Repository) RecordOTPFailure(ctx context.Context, email string, expiresAt time.Time) (int64, error) {
+	return r.redis.RecordOTPFailure(ctx, domain.TenantFromContext(ctx), email, expiresAt)
+}
+
+func (r *Repository) SaveTOTPEnrollment(ctx context.Context, enrollment domain.TOTPEnrollment) error {
+	enrollment.TenantId = domain.TenantFromContext(ctx)
+	if err := r.db.WithContext(ctx).Save(&enrollment).Error; err != nil {
+		r.logger.Error("Failed to save TOTP enrollment",
+			zap.String("user_id", enrollment.UserId),
+			zap.Error(err))
+		return domain.ErrDatabase
+	}
+	return nil
+}
+
+func (r *Repository) GetTOTPEnrollment(ctx context.Context, userID string) (domain.TOTPEnrollment, error) {
+	var enrollment domain.TOTPEnrollment
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ?", domain.TenantFromContext(ctx), userID).
+		First(&enrollment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return domain.TOTPEnrollment{}, domain.ErrTOTPNotEnrolled
+		}
+		r.logger.Error("Failed to get TOTP enrollment",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return domain.TOTPEnrollment{}, domain.ErrDatabase
+	}
+	return enrollment, nil
+}
+
+func (r *Repository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	// Conditional update so two concurrent verifications of the same code
+	// cannot both succeed.
+	result := r.db.WithContext(ctx).
+		Model(&domain.TOTPEnrollment{}).
+		Where("tenant_id = ? AND user_id = ? AND last_used_step < ?", domain.TenantFromContext(ctx), userID, step).
+		Updates(map[string]interface{}{"last_used_step": step, "confirmed": true})
+	if result.Error != nil {
+		r.logger.Error("Failed to record TOTP step",
+			zap.String("user_id", userID),
+			zap.Error(result.Error))
+		return false, domain.ErrDatabase
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *Repository) SaveMagicLink(ctx context.Context, tokenID string, ttl time.Duration) error {
+	return r.redis.SaveMagicLink(ctx, tokenID, ttl)
+}
+
+func (r *Repository) ConsumeMagicLink(ctx context.Context, tokenID string) (bool, error) {
+	return r.redis.ConsumeMagicLink(ctx, tokenID)
 }
@@ -0,0 +1,445 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/redis"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder is a gorm logger that keeps the SQL of every statement.
+type sqlRecorder struct {
+	logger.Interface
+	mu  sync.Mutex
+	sql []string
+}
+
+func (r *sqlRecorder) LogMode(logger.LogLevel) logger.Interface { return r }
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sql = append(r.sql, sql)
+}
+
+// dryRunRepository returns a repository that builds statements without a
+// database, and the recorder of their SQL.
+func dryRunRepository(t *testing.T) (*Repository, *sqlRecorder) {
+	t.Helper()
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               recorder,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return NewRepository(&DB{db: db, logger: zap.NewNop()}, nil, nil, "english", zap.NewNop()), recorder
+}
+
+func TestGetAllNotificationsKeysetQuery(t *testing.T) {
+	created := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		after *domain.PageCursor
+		want  []string
+		not   []string
+	}{
+		{
+			name: "first page",
+			want: []string{`ORDER BY pinned DESC, created_at DESC, id DESC LIMIT 21`},
+			not:  []string{`(pinned, created_at, id) <`},
+		},
+		{
+			name:  "next page",
+			after: &domain.PageCursor{Pinned: true, CreatedAt: created, ID: "7f0c1a52-3c1e-4d8e-9a55-0b7a8f0e9d11"},
+			want: []string{
+				`(pinned, created_at, id) < (true, '2026-10-18 09:30:00', '7f0c1a52-3c1e-4d8e-9a55-0b7a8f0e9d11')`,
+				`ORDER BY pinned DESC, created_at DESC, id DESC LIMIT 21`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, recorder := dryRunRepository(t)
+			ctx := domain.ContextWithTenant(context.Background(), "acme")
+			if _, _, _, err := repo.GetAllNotifications(ctx, "u1", domain.NotificationFilter{}, tt.after, 20); err != nil {
+				t.Fatalf("GetAllNotifications: %v", err)
+			}
+			if len(recorder.sql) != 2 {
+				t.Fatalf("ran %d statements, want a count and a page: %q", len(recorder.sql), recorder.sql)
+			}
+			count, page := recorder.sql[0], recorder.sql[1]
+			if strings.Contains(count, "(pinned, created_at, id)") || strings.Contains(count, "LIMIT") {
+				t.Errorf("count is restricted to the page: %s", count)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(page, want) {
+					t.Errorf("page query lacks %q: %s", want, page)
+				}
+			}
+			for _, not := range tt.not {
+				if strings.Contains(page, not) {
+					t.Errorf("page query has %q: %s", not, page)
+				}
+			}
+			if !strings.Contains(page, `tenant_id = 'acme' AND user_id = 'u1'`) {
+				t.Errorf("page query is not scoped to the tenant and user: %s", page)
+			}
+		})
+	}
+}
+
+// testRepository returns a repository on the migrated database named by
+// TEST_DATABASE_URL, skipping the test if it is not set, caching unread
+// counts in a fresh in-memory Redis.
+func testRepository(t *testing.T) *Repository {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := NewDB(dsn, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := NewMigrator(context.Background(), db, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	defer migrator.Close()
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	redisClient, err := redis.NewRedisClient(miniredis.RunT(t).Addr(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	t.Cleanup(func() { redisClient.Close() })
+	return NewRepository(db, nil, redisClient, "english", zap.NewNop())
+}
+
+func TestClaimNotificationConcurrent(t *testing.T) {
+	repo := testRepository(t)
+	ctx := context.Background()
+	id := uuid.New().String()
+
+	const consumers = 16
+	outcomes := make([]domain.ClaimOutcome, consumers)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			outcome, err := repo.ClaimNotification(ctx, id, owner(i), time.Minute)
+			if err != nil {
+				t.Errorf("consumer %d: %v", i, err)
+			}
+			outcomes[i] = outcome
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	winner := -1
+	for i, outcome := range outcomes {
+		switch outcome {
+		case domain.ClaimAcquired:
+			if winner >= 0 {
+				t.Fatalf("consumers %d and %d both acquired the claim", winner, i)
+			}
+			winner = i
+		case domain.ClaimHeld:
+		default:
+			t.Errorf("consumer %d: outcome %v, want acquired or held", i, outcome)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("no consumer acquired the claim")
+	}
+
+	if err := repo.CompleteNotification(ctx, id, owner(winner+1)); !errors.Is(err, domain.ErrClaimLost) {
+		t.Errorf("CompleteNotification by a loser = %v, want ErrClaimLost", err)
+	}
+	if err := repo.CompleteNotification(ctx, id, owner(winner)); err != nil {
+		t.Fatalf("CompleteNotification by the winner: %v", err)
+	}
+	if outcome, err := repo.ClaimNotification(ctx, id, owner(winner+1), time.Minute); err != nil || outcome != domain.ClaimProcessed {
+		t.Errorf("claim after completion = %v, %v, want processed", outcome, err)
+	}
+}
+
+func TestClaimNotificationLease(t *testing.T) {
+	repo := testRepository(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		setup func(id string)
+		want  domain.ClaimOutcome
+		lost  bool // whether the first owner lost the claim
+	}{
+		{
+			name:  "live lease is held",
+			setup: func(id string) { repo.ClaimNotification(ctx, id, "first", time.Minute) },
+			want:  domain.ClaimHeld,
+		},
+		{
+			name:  "expired lease is taken over",
+			setup: func(id string) { repo.ClaimNotification(ctx, id, "first", -time.Second) },
+			want:  domain.ClaimAcquired,
+			lost:  true,
+		},
+		{
+			name: "released claim is acquired",
+			setup: func(id string) {
+				repo.ClaimNotification(ctx, id, "first", time.Minute)
+				repo.ReleaseNotification(ctx, id, "first")
+			},
+			want: domain.ClaimAcquired,
+			lost: true,
+		},
+		{
+			name: "release by another owner is ignored",
+			setup: func(id string) {
+				repo.ClaimNotification(ctx, id, "first", time.Minute)
+				repo.ReleaseNotification(ctx, id, "second")
+			},
+			want: domain.ClaimHeld,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := uuid.New().String()
+			tt.setup(id)
+			outcome, err := repo.ClaimNotification(ctx, id, "second", time.Minute)
+			if err != nil || outcome != tt.want {
+				t.Fatalf("ClaimNotification = %v, %v, want %v", outcome, err, tt.want)
+			}
+			err = repo.CompleteNotification(ctx, id, "first")
+			if lost := errors.Is(err, domain.ErrClaimLost); lost != tt.lost {
+				t.Errorf("first owner's CompleteNotification = %v, lost = %v, want %v", err, lost, tt.lost)
+			}
+		})
+	}
+}
+
+func TestGetAllNotificationsKeyset(t *testing.T) {
+	repo := testRepository(t)
+	tenant := "keyset-" + uuid.New().String()[:8]
+	ctx := domain.ContextWithTenant(context.Background(), tenant)
+	userId := uuid.New().String()
+
+	// Ties on created_at are broken by id, so no page skips or repeats one
+	created := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	var notifications []domain.Notification
+	for i := range 7 {
+		notifications = append(notifications, domain.Notification{
+			ID:           uuid.New().String(),
+			TenantId:     tenant,
+			UserId:       userId,
+			Type:         domain.InAppNotification,
+			Body:         "body",
+			Pinned:       i == 5,
+			SearchConfig: "english",
+			CreatedAt:    created.Add(time.Duration(i/2) * time.Minute),
+		})
+	}
+	if err := repo.db.Create(&notifications).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	t.Cleanup(func() { repo.db.Where("tenant_id = ?", tenant).Delete(&domain.Notification{}) })
+
+	var want []string
+	if err := repo.db.Model(&domain.Notification{}).
+		Where("tenant_id = ?", tenant).
+		Order("pinned DESC, created_at DESC, id DESC").
+		Pluck("id", &want).Error; err != nil {
+		t.Fatalf("pluck: %v", err)
+	}
+
+	var got []string
+	var after *domain.PageCursor
+	for pages := 0; ; pages++ {
+		if pages > len(notifications) {
+			t.Fatal("pagination does not end")
+		}
+		page, next, total, err := repo.GetAllNotifications(ctx, userId, domain.NotificationFilter{}, after, 2)
+		if err != nil {
+			t.Fatalf("GetAllNotifications: %v", err)
+		}
+		if total != int64(len(notifications)) {
+			t.Errorf("total = %d, want %d", total, len(notifications))
+		}
+		for _, n := range page {
+			got = append(got, n.ID)
+		}
+		if next == nil {
+			break
+		}
+		// Pages resume from their token, as a client would send it
+		cursor, err := domain.DecodePageCursor(next.Encode())
+		if err != nil {
+			t.Fatalf("DecodePageCursor: %v", err)
+		}
+		after = &cursor
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("paged ids = %v, want %v", got, want)
+	}
+	if got[0] != notifications[5].ID {
+		t.Errorf("first id = %s, want the pinned notification", got[0])
+	}
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	repo := testRepository(t)
+	tenant := "totp-" + uuid.New().String()[:8]
+	ctx := domain.ContextWithTenant(context.Background(), tenant)
+	userId := uuid.New().String()
+	if err := repo.SaveTOTPEnrollment(ctx, domain.TOTPEnrollment{UserId: userId, Secret: "aes-gcm$id$sealed"}); err != nil {
+		t.Fatalf("SaveTOTPEnrollment: %v", err)
+	}
+	t.Cleanup(func() { repo.db.Where("tenant_id = ?", tenant).Delete(&domain.TOTPEnrollment{}) })
+
+	steps := []struct {
+		name string
+		ctx  context.Context
+		step int64
+		want bool
+	}{
+		{"first step", ctx, 100, true},
+		{"same step replayed", ctx, 100, false},
+		{"earlier step", ctx, 99, false},
+		{"other tenant", domain.ContextWithTenant(context.Background(), tenant+"-other"), 101, false},
+		{"later step", ctx, 101, true},
+	}
+	for _, step := range steps {
+		got, err := repo.UseTOTPStep(step.ctx, userId, step.step)
+		if err != nil {
+			t.Fatalf("%s: UseTOTPStep: %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: UseTOTPStep() = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	enrollment, err := repo.GetTOTPEnrollment(ctx, userId)
+	if err != nil {
+		t.Fatalf("GetTOTPEnrollment: %v", err)
+	}
+	if !enrollment.Confirmed || enrollment.LastUsedStep != 101 {
+		t.Errorf("enrollment = %+v, want confirmed at step 101", enrollment)
+	}
+
+	// Concurrent verifications of one code accept it once
+	const verifiers = 8
+	results := make(chan bool, verifiers)
+	for range verifiers {
+		go func() {
+			fresh, err := repo.UseTOTPStep(ctx, userId, 102)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- fresh
+		}()
+	}
+	accepted := 0
+	for range verifiers {
+		if <-results {
+			accepted++
+		}
+	}
+	if accepted != 1 {
+		t.Errorf("step accepted %d times, want once", accepted)
+	}
+}
+
+func owner(i int) string {
+	return "consumer-" + string(rune('a'+i))
+}
+
+func TestUnreadCountFollowsReads(t *testing.T) {
+	repo := testRepository(t)
+	tenant := "unread-" + uuid.New().String()[:8]
+	ctx := domain.ContextWithTenant(context.Background(), tenant)
+	userId := uuid.New().String()
+	t.Cleanup(func() { repo.db.Where("tenant_id = ?", tenant).Delete(&domain.Notification{}) })
+
+	changes, err := repo.WatchUnreadCount(ctx, userId)
+	if err != nil {
+		t.Fatalf("WatchUnreadCount: %v", err)
+	}
+	save := func(category string) string {
+		t.Helper()
+		notification := domain.Notification{ID: uuid.New().String(), UserId: userId, Type: domain.InAppNotification, Category: category, Body: "body"}
+		if err := repo.SaveNotification(ctx, notification); err != nil {
+			t.Fatalf("SaveNotification: %v", err)
+		}
+		return notification.ID
+	}
+	first := save("course")
+
+	// The first read loads the counter, which later changes keep current
+	steps := []struct {
+		name    string
+		change  func() error
+		want    domain.UnreadCount
+		changed bool
+	}{
+		{"loaded", func() error { return nil },
+			domain.UnreadCount{Total: 1, ByCategory: map[string]int64{"course": 1}}, true},
+		{"saved", func() error { save("course"); save("billing"); return nil },
+			domain.UnreadCount{Total: 3, ByCategory: map[string]int64{"course": 2, "billing": 1}}, true},
+		{"read", func() error { return repo.MarkAsRead(ctx, first, userId) },
+			domain.UnreadCount{Total: 2, ByCategory: map[string]int64{"course": 1, "billing": 1}}, true},
+		{"read again", func() error { return repo.MarkAsRead(ctx, first, userId) },
+			domain.UnreadCount{Total: 2, ByCategory: map[string]int64{"course": 1, "billing": 1}}, false},
+		{"all read", func() error { return repo.MarkAllAsRead(ctx, userId) },
+			domain.UnreadCount{ByCategory: map[string]int64{}}, true},
+	}
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		count, err := repo.GetUnreadCount(ctx, userId)
+		if err != nil {
+			t.Fatalf("%s: GetUnreadCount: %v", step.name, err)
+		}
+		if !count.Equal(step.want) {
+			t.Errorf("%s: GetUnreadCount() = %+v, want %+v", step.name, count, step.want)
+		}
+
+		changed := false
+		for drained := false; !drained; {
+			select {
+			case <-changes:
+				changed = true
+			case <-time.After(100 * time.Millisecond):
+				drained = true
+			}
+		}
+		if changed != step.changed {
+			t.Errorf("%s: watchers notified = %v, want %v", step.name, changed, step.changed)
+		}
+	}
+}
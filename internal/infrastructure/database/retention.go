@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// retentionBatchSize bounds each DELETE so long purges don't hold locks.
+const retentionBatchSize = 5000
+
+// RetentionPolicy deletes notifications of a type, a category or both once
+// they are older than MaxAge; an empty Type or Category matches any. With
+// ReadOnly, unread notifications are kept.
+type RetentionPolicy struct {
+	Type     domain.NotificationType
+	Category string
+	MaxAge   time.Duration
+	ReadOnly bool
+}
+
+type RetentionConfig struct {
+	Policies []RetentionPolicy
+	// Whole partitions entirely older than PartitionMaxAge are dropped, or
+	// detached into the notification_archive schema when ArchivePartitions
+	// is set. Zero keeps all partitions.
+	PartitionMaxAge   time.Duration
+	ArchivePartitions bool
+	// PartitionsAhead is how many future monthly partitions to keep created.
+	PartitionsAhead int
+	// ProcessedMaxAge should exceed the Kafka topic retention, so a replayed
+	// message is still recognised as processed.
+	ProcessedMaxAge time.Duration
+}
+
+// Retention maintains notification partitions and purges expired rows. It
+// is meant to run on a single replica, see LeaderElector.
+type Retention struct {
+	db     *gorm.DB
+	config RetentionConfig
+	logger *zap.Logger
+}
+
+func NewRetention(db *DB, config RetentionConfig, logger *zap.Logger) *Retention {
+	return &Retention{db: db.DB(), config: config, logger: logger}
+}
+
+// RunEvery runs a retention pass immediately and then every interval until
+// ctx is done.
+func (r *Retention) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Run(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error("Retention pass failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run performs one retention pass.
+func (r *Retention) Run(ctx context.Context) error {
+	now := time.Now()
+	partitioned, err := isPartitioned(ctx, r.db)
+	if err != nil {
+		r.logger.Error("Failed to inspect notifications table", zap.Error(err))
+		return domain.ErrDatabase
+	}
+
+	if partitioned {
+		if err := ensurePartitions(ctx, r.db, now, r.config.PartitionsAhead); err != nil {
+			r.logger.Error("Failed to create notification partitions", zap.Error(err))
+			return domain.ErrDatabase
+		}
+	} else {
+		r.logger.Warn("Notifications table is not partitioned, skipping partition maintenance")
+	}
+
+	for _, policy := range r.config.Policies {
+		if err := r.applyPolicy(ctx, policy, now); err != nil {
+			return err
+		}
+	}
+
+	if partitioned && r.config.PartitionMaxAge > 0 {
+		if err := r.expirePartitions(ctx, now.Add(-r.config.PartitionMaxAge)); err != nil {
+			return err
+		}
+	}
+
+	if r.config.ProcessedMaxAge > 0 {
+		if err := r.pruneProcessed(ctx, now.Add(-r.config.ProcessedMaxAge)); err != nil {
+			return err
+		}
+	}
+	return r.pruneIdempotencyKeys(ctx, now)
+}
+
+func (r *Retention) applyPolicy(ctx context.Context, policy RetentionPolicy, now time.Time) error {
+	condition, args := policy.condition(now)
+
+	// (id, created_at) is the primary key; ctid is not unique across partitions
+	deleted, err := r.deleteInBatches(ctx, `DELETE FROM notifications WHERE (id, created_at) IN (
+		SELECT id, created_at FROM notifications WHERE `+condition+` LIMIT ?)`,
+		append(args, retentionBatchSize)...)
+	if err != nil {
+		r.logger.Error("Failed to apply retention policy",
+			zap.String("type", string(policy.Type)),
+			zap.String("category", policy.Category),
+			zap.Error(err))
+		return domain.ErrDatabase
+	}
+	if deleted > 0 {
+		r.logger.Info("Deleted expired notifications",
+			zap.String("type", string(policy.Type)),
+			zap.String("category", policy.Category),
+			zap.Duration("max_age", policy.MaxAge),
+			zap.Int64("count", deleted))
+	}
+	return nil
+}
+
+// condition returns the WHERE clause selecting the notifications policy
+// expires at now, with its arguments.
+func (p RetentionPolicy) condition(now time.Time) (string, []interface{}) {
+	condition := "created_at < ?"
+	args := []interface{}{now.Add(-p.MaxAge)}
+	if p.Type != "" {
+		condition += " AND type = ?"
+		args = append(args, p.Type)
+	}
+	if p.Category != "" {
+		condition += " AND category = ?"
+		args = append(args, p.Category)
+	}
+	if p.ReadOnly {
+		condition += " AND is_read = true"
+	}
+	return condition, args
+}
+
+func (r *Retention) expirePartitions(ctx context.Context, cutoff time.Time) error {
+	partitions, err := listPartitions(ctx, r.db)
+	if err != nil {
+		r.logger.Error("Failed to list notification partitions", zap.Error(err))
+		return domain.ErrDatabase
+	}
+
+	for _, p := range partitions {
+		if p.to.After(cutoff) {
+			continue
+		}
+		if r.config.ArchivePartitions {
+			err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec("CREATE SCHEMA IF NOT EXISTS notification_archive").Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE notifications DETACH PARTITION " + p.name).Error; err != nil {
+					return err
+				}
+				return tx.Exec("ALTER TABLE " + p.name + " SET SCHEMA notification_archive").Error
+			})
+		} else {
+			err = r.db.WithContext(ctx).Exec("DROP TABLE " + p.name).Error
+		}
+		if err != nil {
+			r.logger.Error("Failed to expire notification partition",
+				zap.String("partition", p.name),
+				zap.Error(err))
+			return domain.ErrDatabase
+		}
+		r.logger.Info("Expired notification partition",
+			zap.String("partition", p.name),
+			zap.Bool("archived", r.config.ArchivePartitions))
+	}
+	return nil
+}
+
+func (r *Retention) pruneProcessed(ctx context.Context, cutoff time.Time) error {
+	deleted, err := r.deleteInBatches(ctx, `DELETE FROM processed_notifications WHERE notification_id IN (
+		SELECT notification_id FROM processed_notifications WHERE created_at < ? LIMIT ?)`,
+		cutoff, retentionBatchSize)
+	if err != nil {
+		r.logger.Error("Failed to prune processed notifications", zap.Error(err))
+		return domain.ErrDatabase
+	}
+	if deleted > 0 {
+		r.logger.Info("Pruned processed notifications", zap.Int64("count", deleted))
+	}
+	return nil
+}
+
+func (r *Retention) pruneIdempotencyKeys(ctx context.Context, now time.Time) error {
+	deleted, err := r.deleteInBatches(ctx, `DELETE FROM idempotency_keys WHERE (tenant_id, caller, key) IN (
+		SELECT tenant_id, caller, key FROM idempotency_keys WHERE expires_at < ? LIMIT ?)`,
+		now, retentionBatchSize)
+	if err != nil {
+		r.logger.Error("Failed to prune idempotency keys", zap.Error(err))
+		return domain.ErrDatabase
+	}
+	if deleted > 0 {
+		r.logger.Info("Pruned expired idempotency keys", zap.Int64("count", deleted))
+	}
+	return nil
+}
+
+// deleteInBatches repeats a batched DELETE until it affects fewer rows than
+// a full batch. The batch size must be the last query argument.
+func (r *Retention) deleteInBatches(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	var total int64
+	for {
+		result := r.db.WithContext(ctx).Exec(sql, args...)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < retentionBatchSize {
+			return total, nil
+		}
+	}
+}
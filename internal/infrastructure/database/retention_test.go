@@ -0,0 +1,84 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+)
+
+func TestRetentionPolicyCondition(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cutoff := now.Add(-720 * time.Hour)
+
+	tests := []struct {
+		name      string
+		policy    RetentionPolicy
+		condition string
+		args      []interface{}
+	}{
+		{
+			name:      "type",
+			policy:    RetentionPolicy{Type: domain.InAppNotification, MaxAge: 720 * time.Hour},
+			condition: "created_at < ? AND type = ?",
+			args:      []interface{}{cutoff, domain.InAppNotification},
+		},
+		{
+			name:      "category",
+			policy:    RetentionPolicy{Category: domain.CategorySecurity, MaxAge: 720 * time.Hour},
+			condition: "created_at < ? AND category = ?",
+			args:      []interface{}{cutoff, domain.CategorySecurity},
+		},
+		{
+			name:      "type and category, read only",
+			policy:    RetentionPolicy{Type: domain.EmailNotification, Category: "marketing", MaxAge: 720 * time.Hour, ReadOnly: true},
+			condition: "created_at < ? AND type = ? AND category = ? AND is_read = true",
+			args:      []interface{}{cutoff, domain.EmailNotification, "marketing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition, args := tt.policy.condition(now)
+			if condition != tt.condition {
+				t.Errorf("condition = %q, want %q", condition, tt.condition)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestMonthPartition(t *testing.T) {
+	tests := []struct {
+		at       time.Time
+		name     string
+		from, to time.Time
+	}{
+		{
+			at:   time.Date(2026, 4, 17, 23, 59, 0, 0, time.UTC),
+			name: "notifications_y2026m04",
+			from: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			at:   time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+			name: "notifications_y2026m12",
+			from: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := monthPartition(tt.at)
+			if p.name != tt.name || !p.from.Equal(tt.from) || !p.to.Equal(tt.to) {
+				t.Errorf("monthPartition(%v) = %+v, want {%s %v %v}", tt.at, p, tt.name, tt.from, tt.to)
+			}
+			month, err := time.Parse(partitionNameLayout, p.name)
+			if err != nil || !month.Equal(p.from) {
+				t.Errorf("partition name %q parses to %v, %v", p.name, month, err)
+			}
+		})
+	}
+}
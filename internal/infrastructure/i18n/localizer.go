@@ -0,0 +1,242 @@
+// Package i18n renders email templates in the recipient's language, using
+// ICU MessageFormat catalogs and CLDR plural, number and date rules.
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/ar"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/fr"
+)
+
+// layoutFile wraps every page template.
+const layoutFile = "layout.html"
+
+// translators supplies the CLDR rules of each language a catalog may be
+// written in.
+var translators = map[string]func() locales.Translator{
+	"ar": ar.New,
+	"en": en.New,
+	"fr": fr.New,
+}
+
+// rtlLanguages are written right to left.
+var rtlLanguages = map[string]bool{
+	"ar": true,
+	"fa": true,
+	"he": true,
+	"ur": true,
+}
+
+type catalog struct {
+	locale     string
+	translator locales.Translator
+	dir        string // "ltr" or "rtl"
+	messages   map[string]message
+}
+
+// Localizer implements domain.Localizer over a template tree laid out as
+// described in the template package.
+type Localizer struct {
+	pages    map[string]*template.Template
+	catalogs map[string]*catalog
+}
+
+// NewLocalizer parses every page and catalog in fsys up front, so a
+// malformed template or message fails at startup rather than on send.
+func NewLocalizer(fsys fs.FS) (*Localizer, error) {
+	l := &Localizer{
+		pages:    make(map[string]*template.Template),
+		catalogs: make(map[string]*catalog),
+	}
+
+	files, err := fs.Glob(fsys, "locales/*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		c, err := loadCatalog(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		l.catalogs[c.locale] = c
+	}
+	fallback, ok := l.catalogs[domain.DefaultLocale]
+	if !ok {
+		return nil, fmt.Errorf("no catalog for default locale %q", domain.DefaultLocale)
+	}
+
+	// "t" is rebound per render; this stub only lets the templates parse
+	layout, err := template.New(layoutFile).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"t": func(string) string { return "" }}).
+		ParseFS(fsys, layoutFile)
+	if err != nil {
+		return nil, err
+	}
+	files, err = fs.Glob(fsys, "*.html")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file == layoutFile {
+			continue
+		}
+		page, err := template.Must(layout.Clone()).ParseFS(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(file, ".html")
+		if _, ok := fallback.messages[name+".subject"]; !ok {
+			return nil, fmt.Errorf("template %s: no %s.subject message in the %s catalog", name, name, domain.DefaultLocale)
+		}
+		l.pages[name] = page
+	}
+	return l, nil
+}
+
+func loadCatalog(fsys fs.FS, file string) (*catalog, error) {
+	locale := normalize(strings.TrimSuffix(path.Base(file), ".json"))
+	language, _, _ := strings.Cut(locale, "-")
+	newTranslator, ok := translators[language]
+	if !ok {
+		return nil, fmt.Errorf("catalog %s: unsupported language %q", file, language)
+	}
+
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	var sources map[string]string
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("catalog %s: %w", file, err)
+	}
+
+	c := &catalog{
+		locale:     locale,
+		translator: newTranslator(),
+		dir:        "ltr",
+		messages:   make(map[string]message, len(sources)),
+	}
+	if rtlLanguages[language] {
+		c.dir = "rtl"
+	}
+	for id, source := range sources {
+		m, err := parseMessage(source)
+		if err != nil {
+			return nil, fmt.Errorf("catalog %s: message %s: %w", file, id, err)
+		}
+		c.messages[id] = m
+	}
+	return c, nil
+}
+
+func (l *Localizer) Render(name string, locales []string, tenant domain.Tenant, vars map[string]interface{}) (domain.LocalizedMessage, error) {
+	page, ok := l.pages[name]
+	if !ok {
+		return domain.LocalizedMessage{}, fmt.Errorf("%w %q", domain.ErrUnknownTemplate, name)
+	}
+	c := l.resolve(locales)
+	translate := l.translator(c, tenant, vars)
+
+	subject, err := translate(name + ".subject")
+	if err != nil {
+		return domain.LocalizedMessage{}, err
+	}
+	page, err = page.Clone()
+	if err != nil {
+		return domain.LocalizedMessage{}, err
+	}
+	var body bytes.Buffer
+	err = page.Funcs(template.FuncMap{"t": translate}).ExecuteTemplate(&body, layoutFile, struct {
+		Lang, Dir, Subject string
+		Vars               map[string]interface{}
+		Brand              map[string]string
+	}{c.locale, c.dir, subject, vars, tenant.Branding})
+	if err != nil {
+		return domain.LocalizedMessage{}, fmt.Errorf("template %s: %w", name, err)
+	}
+	return domain.LocalizedMessage{Locale: c.locale, Subject: subject, Body: body.String()}, nil
+}
+
+// RenderText renders the "<name>.subject" and "<name>.body" messages, so
+// text templates need no page.
+func (l *Localizer) RenderText(name string, locales []string, tenant domain.Tenant, vars map[string]interface{}) (domain.LocalizedMessage, error) {
+	if _, ok := l.catalogs[domain.DefaultLocale].messages[name+".body"]; !ok {
+		return domain.LocalizedMessage{}, fmt.Errorf("%w %q", domain.ErrUnknownTemplate, name)
+	}
+	c := l.resolve(locales)
+	translate := l.translator(c, tenant, vars)
+
+	subject, err := translate(name + ".subject")
+	if err != nil {
+		return domain.LocalizedMessage{}, err
+	}
+	body, err := translate(name + ".body")
+	if err != nil {
+		return domain.LocalizedMessage{}, err
+	}
+	return domain.LocalizedMessage{Locale: c.locale, Subject: subject, Body: body}, nil
+}
+
+// translator returns a function formatting messages of c with vars and the
+// tenant's name as "academy".
+func (l *Localizer) translator(c *catalog, tenant domain.Tenant, vars map[string]interface{}) func(string) (string, error) {
+	args := make(map[string]interface{}, len(vars)+1)
+	args["academy"] = tenant.Name
+	for k, v := range vars {
+		args[k] = v
+	}
+	return func(id string) (string, error) {
+		return l.format(c, id, args)
+	}
+}
+
+// resolve returns the catalog for the first supported locale, trying each
+// tag before its base language, e.g. "fr-CA" then "fr".
+func (l *Localizer) resolve(locales []string) *catalog {
+	for _, locale := range locales {
+		locale = normalize(locale)
+		for locale != "" {
+			if c, ok := l.catalogs[locale]; ok {
+				return c
+			}
+			i := strings.LastIndexByte(locale, '-')
+			if i < 0 {
+				break
+			}
+			locale = locale[:i]
+		}
+	}
+	return l.catalogs[domain.DefaultLocale]
+}
+
+// format formats message id from c, or from the default catalog if c lacks
+// it, so a partial translation still renders.
+func (l *Localizer) format(c *catalog, id string, args map[string]interface{}) (string, error) {
+	m, ok := c.messages[id]
+	if !ok {
+		c = l.catalogs[domain.DefaultLocale]
+		if m, ok = c.messages[id]; !ok {
+			return "", fmt.Errorf("unknown message %q", id)
+		}
+	}
+	var b strings.Builder
+	if err := m.format(&formatter{translator: c.translator, args: args}, &b); err != nil {
+		return "", fmt.Errorf("message %s: %w", id, err)
+	}
+	return b.String(), nil
+}
+
+func normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
@@ -0,0 +1,60 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/shared/template"
+)
+
+func TestLocalizerRender(t *testing.T) {
+	l, err := NewLocalizer(template.FS)
+	if err != nil {
+		t.Fatalf("NewLocalizer: %v", err)
+	}
+	tenant := domain.Tenant{Branding: map[string]string{
+		"home_url":      "https://example.com",
+		"logo_url":      "https://example.com/logo.png",
+		"support_email": "support@example.com",
+	}}
+	vars := map[string]interface{}{
+		"user_name":  "Ann",
+		"code":       "123456",
+		"minutes":    10,
+		"expires_at": time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name    string
+		locales []string
+		dir     string
+	}{
+		{"requested locale", []string{"fr"}, `dir="ltr"`},
+		{"right to left", []string{"ar"}, `dir="rtl"`},
+		{"region falls back to language", []string{"fr-CA"}, `dir="ltr"`},
+		{"unknown falls back to default", []string{"xx", ""}, `dir="ltr"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := l.Render("activation-mail", tt.locales, tenant, vars)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if message.Subject == "" {
+				t.Error("empty subject")
+			}
+			if !strings.Contains(message.Body, "123456") {
+				t.Error("body lacks the code")
+			}
+			if !strings.Contains(message.Body, tt.dir) {
+				t.Errorf("body lacks %s", tt.dir)
+			}
+		})
+	}
+
+	if _, err := l.Render("no-such-template", []string{"en"}, tenant, vars); err == nil {
+		t.Error("Render of an unknown template succeeded")
+	}
+}
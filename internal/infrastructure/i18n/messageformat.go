@@ -0,0 +1,401 @@
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/locales"
+)
+
+// This file implements the subset of ICU MessageFormat used by the message
+// catalogs: simple, number, date and time arguments, plural, selectordinal
+// and select, '#' inside plurals, and apostrophe quoting.
+//
+//	"{count, plural, =0 {No new messages} one {# new message} other {# new messages}}"
+//	"Expires at {expires_at, time, short}"
+
+type message []part
+
+type part interface {
+	format(f *formatter, b *strings.Builder) error
+}
+
+type text string
+
+// pound is '#' in a plural case: the plural value less the offset.
+type pound struct{}
+
+type argument struct {
+	name  string
+	kind  string // "", "number", "date" or "time"
+	style string
+}
+
+type choice struct {
+	name   string
+	kind   string // "plural", "selectordinal" or "select"
+	offset float64
+	cases  map[string]message
+}
+
+var argumentStyles = map[string][]string{
+	"number": {"", "integer"},
+	"date":   {"", "short", "medium", "long", "full"},
+	"time":   {"", "short", "medium", "long", "full"},
+}
+
+func parseMessage(src string) (message, error) {
+	p := &parser{src: src}
+	m, err := p.message(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unmatched '}'")
+	}
+	return m, nil
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// message parses up to an unmatched '}' or the end of input. inPlural
+// makes '#' special.
+func (p *parser) message(inPlural bool) (message, error) {
+	var m message
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			m = append(m, text(literal.String()))
+			literal.Reset()
+		}
+	}
+
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == '\'':
+			p.pos++
+			literal.WriteString(p.quoted(inPlural))
+		case c == '{':
+			flush()
+			p.pos++
+			arg, err := p.argument(inPlural)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, arg)
+		case c == '}':
+			flush()
+			return m, nil
+		case c == '#' && inPlural:
+			flush()
+			p.pos++
+			m = append(m, pound{})
+		default:
+			literal.WriteByte(c)
+			p.pos++
+		}
+	}
+	flush()
+	return m, nil
+}
+
+// quoted handles the text after an apostrophe. A doubled apostrophe is a
+// literal one, and an apostrophe before a special character starts quoted
+// text running to the next lone apostrophe. Any other apostrophe is literal.
+func (p *parser) quoted(inPlural bool) string {
+	if p.pos >= len(p.src) {
+		return "'"
+	}
+	switch c := p.src[p.pos]; {
+	case c == '\'':
+		p.pos++
+		return "'"
+	case c == '{' || c == '}' || c == '|' || (c == '#' && inPlural):
+	default:
+		return "'"
+	}
+
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		if c != '\'' {
+			b.WriteByte(c)
+			continue
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+			b.WriteByte('\'')
+			p.pos++
+			continue
+		}
+		break
+	}
+	return b.String()
+}
+
+// argument parses what follows '{' up to and including the matching '}'.
+func (p *parser) argument(inPlural bool) (part, error) {
+	name := p.word()
+	if name == "" {
+		return nil, p.errorf("expected argument name")
+	}
+	if p.consume('}') {
+		return argument{name: name}, nil
+	}
+	if !p.consume(',') {
+		return nil, p.errorf("expected ',' or '}' after argument %q", name)
+	}
+
+	kind := p.word()
+	switch kind {
+	case "number", "date", "time":
+		arg := argument{name: name, kind: kind}
+		if p.consume(',') {
+			arg.style = p.word()
+		}
+		if !contains(argumentStyles[kind], arg.style) {
+			return nil, p.errorf("unsupported %s style %q", kind, arg.style)
+		}
+		if !p.consume('}') {
+			return nil, p.errorf("expected '}' after argument %q", name)
+		}
+		return arg, nil
+	case "plural", "selectordinal", "select":
+		if !p.consume(',') {
+			return nil, p.errorf("expected ',' after %s", kind)
+		}
+		return p.choice(name, kind, inPlural)
+	}
+	return nil, p.errorf("unsupported argument type %q", kind)
+}
+
+func (p *parser) choice(name, kind string, inPlural bool) (part, error) {
+	c := choice{name: name, kind: kind, cases: make(map[string]message)}
+	for !p.consume('}') {
+		selector := p.word()
+		if selector == "" {
+			return nil, p.errorf("expected selector or '}' in argument %q", name)
+		}
+		if offset, ok := strings.CutPrefix(selector, "offset:"); ok && kind == "plural" && len(c.cases) == 0 {
+			n, err := strconv.ParseFloat(offset, 64)
+			if err != nil {
+				return nil, p.errorf("invalid offset %q", offset)
+			}
+			c.offset = n
+			continue
+		}
+		if !p.consume('{') {
+			return nil, p.errorf("expected '{' after selector %q", selector)
+		}
+		m, err := p.message(inPlural || kind != "select")
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume('}') {
+			return nil, p.errorf("unterminated case %q of argument %q", selector, name)
+		}
+		c.cases[selector] = m
+	}
+	if _, ok := c.cases["other"]; !ok {
+		return nil, p.errorf("%s argument %q has no other case", kind, name)
+	}
+	return c, nil
+}
+
+// word skips whitespace and reads up to the next whitespace or syntax
+// character.
+func (p *parser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n{},'", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// consume skips whitespace and reports whether c follows, consuming it.
+func (p *parser) consume(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+type formatter struct {
+	translator locales.Translator
+	args       map[string]interface{}
+	plural     *number // value of the innermost plural, for '#'
+}
+
+type number struct {
+	n float64
+	v uint64 // visible fraction digits
+}
+
+func (m message) format(f *formatter, b *strings.Builder) error {
+	for _, p := range m {
+		if err := p.format(f, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t text) format(_ *formatter, b *strings.Builder) error {
+	b.WriteString(string(t))
+	return nil
+}
+
+func (pound) format(f *formatter, b *strings.Builder) error {
+	b.WriteString(f.translator.FmtNumber(f.plural.n, f.plural.v))
+	return nil
+}
+
+func (a argument) format(f *formatter, b *strings.Builder) error {
+	value, ok := f.args[a.name]
+	if !ok {
+		return fmt.Errorf("missing argument %q", a.name)
+	}
+
+	switch a.kind {
+	case "number":
+		num, ok := toNumber(value)
+		if !ok {
+			return fmt.Errorf("argument %q is not a number", a.name)
+		}
+		if a.style == "integer" {
+			num = number{n: math.Round(num.n)}
+		}
+		b.WriteString(f.translator.FmtNumber(num.n, num.v))
+	case "date", "time":
+		t, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("argument %q is not a time", a.name)
+		}
+		b.WriteString(formatTime(f.translator, a.kind, a.style, t))
+	default:
+		if t, ok := value.(time.Time); ok {
+			b.WriteString(formatTime(f.translator, "date", "", t))
+		} else if num, ok := toNumber(value); ok {
+			b.WriteString(f.translator.FmtNumber(num.n, num.v))
+		} else {
+			fmt.Fprint(b, value)
+		}
+	}
+	return nil
+}
+
+func (c choice) format(f *formatter, b *strings.Builder) error {
+	value, ok := f.args[c.name]
+	if !ok {
+		return fmt.Errorf("missing argument %q", c.name)
+	}
+	if c.kind == "select" {
+		return c.pick(fmt.Sprint(value)).format(f, b)
+	}
+
+	num, ok := toNumber(value)
+	if !ok {
+		return fmt.Errorf("argument %q is not a number", c.name)
+	}
+	// Exact matches take precedence over plural categories
+	selected, ok := c.cases["="+strconv.FormatFloat(num.n, 'f', -1, 64)]
+	num.n -= c.offset
+	if !ok {
+		var rule locales.PluralRule
+		if c.kind == "plural" {
+			rule = f.translator.CardinalPluralRule(num.n, num.v)
+		} else {
+			rule = f.translator.OrdinalPluralRule(num.n, num.v)
+		}
+		selected = c.pick(strings.ToLower(rule.String()))
+	}
+
+	outer := f.plural
+	f.plural = &num
+	defer func() { f.plural = outer }()
+	return selected.format(f, b)
+}
+
+func (c choice) pick(key string) message {
+	if m, ok := c.cases[key]; ok {
+		return m
+	}
+	return c.cases["other"]
+}
+
+func toNumber(value interface{}) (number, bool) {
+	switch v := value.(type) {
+	case int:
+		return number{n: float64(v)}, true
+	case int32:
+		return number{n: float64(v)}, true
+	case int64:
+		return number{n: float64(v)}, true
+	case uint:
+		return number{n: float64(v)}, true
+	case uint32:
+		return number{n: float64(v)}, true
+	case uint64:
+		return number{n: float64(v)}, true
+	case float32:
+		return toNumber(float64(v))
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if _, fraction, ok := strings.Cut(s, "."); ok {
+			return number{n: v, v: uint64(len(fraction))}, true
+		}
+		return number{n: v}, true
+	}
+	return number{}, false
+}
+
+func formatTime(translator locales.Translator, kind, style string, t time.Time) string {
+	if kind == "time" {
+		switch style {
+		case "medium":
+			return translator.FmtTimeMedium(t)
+		case "long":
+			return translator.FmtTimeLong(t)
+		case "full":
+			return translator.FmtTimeFull(t)
+		}
+		return translator.FmtTimeShort(t)
+	}
+	switch style {
+	case "short":
+		return translator.FmtDateShort(t)
+	case "long":
+		return translator.FmtDateLong(t)
+	case "full":
+		return translator.FmtDateFull(t)
+	}
+	return translator.FmtDateMedium(t)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func format(t *testing.T, language, src string, args map[string]interface{}) (string, error) {
+	t.Helper()
+	m, err := parseMessage(src)
+	if err != nil {
+		t.Fatalf("parseMessage(%q): %v", src, err)
+	}
+	var b strings.Builder
+	err = m.format(&formatter{translator: translators[language](), args: args}, &b)
+	return b.String(), err
+}
+
+func TestFormatPlural(t *testing.T) {
+	const inbox = "{count, plural, =0 {No new messages} one {# new message} other {# new messages}}"
+	const arabic = "{n, plural, zero {zero} one {one} two {two} few {few} many {many} other {other}}"
+	const french = "{n, plural, one {# jour} other {# jours}}"
+	const guests = "{n, plural, offset:1 =0 {nobody} =1 {just {host}} one {{host} and # guest} other {{host} and # guests}}"
+
+	tests := []struct {
+		name     string
+		language string
+		src      string
+		args     map[string]interface{}
+		want     string
+	}{
+		{"exact match wins", "en", inbox, map[string]interface{}{"count": 0}, "No new messages"},
+		{"en one", "en", inbox, map[string]interface{}{"count": 1}, "1 new message"},
+		{"en other", "en", inbox, map[string]interface{}{"count": 1234}, "1,234 new messages"},
+		{"en fraction is other", "en", inbox, map[string]interface{}{"count": 1.5}, "1.5 new messages"},
+		{"ar zero", "ar", arabic, map[string]interface{}{"n": 0}, "zero"},
+		{"ar one", "ar", arabic, map[string]interface{}{"n": 1}, "one"},
+		{"ar two", "ar", arabic, map[string]interface{}{"n": 2}, "two"},
+		{"ar few", "ar", arabic, map[string]interface{}{"n": 3}, "few"},
+		{"ar many", "ar", arabic, map[string]interface{}{"n": 11}, "many"},
+		{"ar other", "ar", arabic, map[string]interface{}{"n": 100}, "other"},
+		{"fr zero is one", "fr", french, map[string]interface{}{"n": 0}, "0 jour"},
+		{"fr two is other", "fr", french, map[string]interface{}{"n": 2}, "2 jours"},
+		{"offset exact", "en", guests, map[string]interface{}{"n": 1, "host": "Ann"}, "just Ann"},
+		{"offset one", "en", guests, map[string]interface{}{"n": 2, "host": "Ann"}, "Ann and 1 guest"},
+		{"offset other", "en", guests, map[string]interface{}{"n": 4, "host": "Ann"}, "Ann and 3 guests"},
+		{"ordinal one", "en", "{n, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}", map[string]interface{}{"n": 21}, "21st"},
+		{"ordinal two", "en", "{n, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}", map[string]interface{}{"n": 22}, "22nd"},
+		{"ordinal teen", "en", "{n, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}", map[string]interface{}{"n": 13}, "13th"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format(t, tt.language, tt.src, tt.args)
+			if err != nil {
+				t.Fatalf("format: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSelect(t *testing.T) {
+	const nested = "{gender, select, female {{n, plural, one {She has # course} other {She has # courses}}} other {{n, plural, one {They have # course} other {They have # courses}}}}"
+	const pluralOfSelect = "{n, plural, one {{role, select, admin {# admin} other {# member}}} other {{role, select, admin {# admins} other {# members}}}}"
+
+	tests := []struct {
+		name string
+		src  string
+		args map[string]interface{}
+		want string
+	}{
+		{"select case", "{role, select, admin {Admin} other {User}}", map[string]interface{}{"role": "admin"}, "Admin"},
+		{"select falls back to other", "{role, select, admin {Admin} other {User}}", map[string]interface{}{"role": "guest"}, "User"},
+		{"plural nested in select", nested, map[string]interface{}{"gender": "female", "n": 1}, "She has 1 course"},
+		{"plural nested in select other", nested, map[string]interface{}{"gender": "x", "n": 3}, "They have 3 courses"},
+		{"pound inside select inside plural", pluralOfSelect, map[string]interface{}{"n": 2, "role": "admin"}, "2 admins"},
+		{"pound outside plural is literal", "Room #{n}", map[string]interface{}{"n": 4}, "Room #4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format(t, "en", tt.src, tt.args)
+			if err != nil {
+				t.Fatalf("format: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatQuoting(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"doubled apostrophe", "It''s {name}", "It's Ann"},
+		{"lone apostrophe is literal", "Don't wait, {name}", "Don't wait, Ann"},
+		{"quoted braces", "Use '{name}' literally", "Use {name} literally"},
+		{"quoted text with doubled apostrophe", "'{it''s}' {name}", "{it's} Ann"},
+		{"quoted pound in plural", "{n, plural, other {'#'# items}}", "#2 items"},
+		{"trailing apostrophe", "{name}'", "Ann'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format(t, "en", tt.src, map[string]interface{}{"name": "Ann", "n": 2})
+			if err != nil {
+				t.Fatalf("format: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMessageMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"unterminated argument", "Hello {name"},
+		{"empty argument", "Hello {}"},
+		{"unmatched close", "Hello }"},
+		{"unknown type", "{n, currency}"},
+		{"unknown style", "{n, number, percent}"},
+		{"plural without other", "{n, plural, one {one}}"},
+		{"case without braces", "{n, plural, one one other {x}}"},
+		{"unterminated case", "{n, plural, other {x"},
+		{"invalid offset", "{n, plural, offset:x other {x}}"},
+		{"missing comma after kind", "{n, plural other {x}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if m, err := parseMessage(tt.src); err == nil {
+				t.Errorf("parseMessage(%q) = %v, want an error", tt.src, m)
+			}
+		})
+	}
+}
+
+func TestFormatArgumentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		args map[string]interface{}
+	}{
+		{"missing argument", "Hello {name}", nil},
+		{"missing plural argument", "{n, plural, other {x}}", nil},
+		{"plural of text", "{n, plural, other {x}}", map[string]interface{}{"n": "three"}},
+		{"number of text", "{n, number}", map[string]interface{}{"n": "three"}},
+		{"time of number", "{n, time, short}", map[string]interface{}{"n": 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := format(t, "en", tt.src, tt.args); err == nil {
+				t.Errorf("format(%q) = %q, want an error", tt.src, got)
+			}
+		})
+	}
+}
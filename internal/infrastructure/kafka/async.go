@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// BatchConfig tunes how AsyncProducer groups messages into requests. Zero
+// fields keep sarama's defaults.
+type BatchConfig struct {
+	Messages  int           // Flush once this many messages are buffered
+	Bytes     int           // Flush once this many bytes are buffered
+	Frequency time.Duration // Flush at least this often
+}
+
+// AsyncProducer batches messages for high-volume fan-out. ProduceAsync
+// returns once a message is buffered and reports its delivery to a
+// callback; Produce waits for the delivery, so concurrent callers share
+// batches.
+type AsyncProducer struct {
+	producer sarama.AsyncProducer
+	logger   *zap.Logger
+	wg       sync.WaitGroup
+}
+
+func NewAsyncProducer(brokers []string, client ClientConfig, batch BatchConfig, logger *zap.Logger) (*AsyncProducer, error) {
+	config, err := newConfig(client)
+	if err != nil {
+		logger.Error("Invalid Kafka producer config", zap.Error(err))
+		return nil, err
+	}
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	config.Producer.Flush.Messages = batch.Messages
+	config.Producer.Flush.Bytes = batch.Bytes
+	config.Producer.Flush.Frequency = batch.Frequency
+
+	producer, err := sarama.NewAsyncProducer(brokers, config)
+	if err != nil {
+		logger.Error("Failed to create Kafka async producer", zap.Error(err))
+		return nil, err
+	}
+
+	logger.Info("Kafka async producer initialized successfully")
+	return newAsyncProducer(producer, logger), nil
+}
+
+// newAsyncProducer wraps producer, reporting each delivery to the callback
+// of its message.
+func newAsyncProducer(producer sarama.AsyncProducer, logger *zap.Logger) *AsyncProducer {
+	p := &AsyncProducer{producer: producer, logger: logger}
+	p.wg.Add(2)
+	go func() {
+		defer p.wg.Done()
+		for msg := range producer.Successes() {
+			p.logger.Debug("Message sent to Kafka",
+				zap.String("topic", msg.Topic),
+				zap.Int32("partition", msg.Partition),
+				zap.Int64("offset", msg.Offset))
+			deliver(msg, nil)
+		}
+	}()
+	go func() {
+		defer p.wg.Done()
+		for err := range producer.Errors() {
+			p.logger.Error("Failed to send Kafka message",
+				zap.String("topic", err.Msg.Topic),
+				zap.Error(err.Err))
+			deliver(err.Msg, err.Err)
+		}
+	}()
+	return p
+}
+
+// deliver reports the outcome of a message to its callback.
+func deliver(msg *sarama.ProducerMessage, err error) {
+	if done, ok := msg.Metadata.(func(error)); ok {
+		done(err)
+	}
+}
+
+// Produce sends a message like Producer.Produce.
+func (p *AsyncProducer) Produce(ctx context.Context, topic, key string, message []byte, headers map[string]string) error {
+	result := make(chan error, 1)
+	p.ProduceAsync(ctx, topic, key, message, headers, func(err error) {
+		result <- err
+	})
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		// The message may still be delivered
+		return ctx.Err()
+	}
+}
+
+// ProduceAsync buffers a message and calls done, from another goroutine,
+// once the brokers acknowledge it or it fails. done is called with ctx's
+// error if ctx ends before the message is buffered. It must not be called
+// after Close.
+func (p *AsyncProducer) ProduceAsync(ctx context.Context, topic, key string, message []byte, headers map[string]string, done func(error)) {
+	msg := newMessage(ctx, topic, key, message, headers)
+	msg.Metadata = done
+	select {
+	case p.producer.Input() <- msg:
+	case <-ctx.Done():
+		done(ctx.Err())
+	}
+}
+
+// Close flushes buffered messages, waits for their callbacks and closes the
+// producer.
+func (p *AsyncProducer) Close() error {
+	p.producer.AsyncClose()
+	p.wg.Wait()
+	p.logger.Info("Kafka async producer closed successfully")
+	return nil
+}
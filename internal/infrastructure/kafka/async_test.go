@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// fakeAsyncProducer acknowledges every message it is given, failing those
+// of the topics in failures. Its input is only read once started.
+type fakeAsyncProducer struct {
+	sarama.AsyncProducer
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+	failures  map[string]error
+}
+
+func newFakeAsyncProducer(failures map[string]error) *fakeAsyncProducer {
+	return &fakeAsyncProducer{
+		input:     make(chan *sarama.ProducerMessage),
+		successes: make(chan *sarama.ProducerMessage),
+		errors:    make(chan *sarama.ProducerError),
+		failures:  failures,
+	}
+}
+
+func (p *fakeAsyncProducer) start() {
+	go func() {
+		defer close(p.successes)
+		defer close(p.errors)
+		for msg := range p.input {
+			if err := p.failures[msg.Topic]; err != nil {
+				p.errors <- &sarama.ProducerError{Msg: msg, Err: err}
+			} else {
+				p.successes <- msg
+			}
+		}
+	}()
+}
+
+func (p *fakeAsyncProducer) Input() chan<- *sarama.ProducerMessage     { return p.input }
+func (p *fakeAsyncProducer) Successes() <-chan *sarama.ProducerMessage { return p.successes }
+func (p *fakeAsyncProducer) Errors() <-chan *sarama.ProducerError      { return p.errors }
+func (p *fakeAsyncProducer) AsyncClose()                               { close(p.input) }
+
+func TestAsyncProducerProduce(t *testing.T) {
+	rejected := errors.New("message too large")
+	fake := newFakeAsyncProducer(map[string]error{"rejected": rejected})
+	fake.start()
+	p := newAsyncProducer(fake, zap.NewNop())
+	defer p.Close()
+
+	tests := []struct {
+		topic string
+		want  error
+	}{
+		{testTopic, nil},
+		{"rejected", rejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			if err := p.Produce(context.Background(), tt.topic, "u1", []byte("value"), nil); !errors.Is(err, tt.want) {
+				t.Errorf("Produce() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAsyncProducerCallbacks(t *testing.T) {
+	rejected := errors.New("message too large")
+	fake := newFakeAsyncProducer(map[string]error{"rejected": rejected})
+	fake.start()
+	p := newAsyncProducer(fake, zap.NewNop())
+
+	const messages = 50
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	outcomes := make(map[string]error)
+	for i := range messages {
+		topic := testTopic
+		if i%5 == 0 {
+			topic = "rejected"
+		}
+		key := fmt.Sprintf("u%d", i)
+		p.ProduceAsync(context.Background(), topic, key, []byte("value"), nil, func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[key]++
+			outcomes[key] = err
+		})
+	}
+	// Close returns only once every callback has run
+	p.Close()
+
+	if len(calls) != messages {
+		t.Fatalf("%d callbacks run, want %d", len(calls), messages)
+	}
+	for i := range messages {
+		key := fmt.Sprintf("u%d", i)
+		var want error
+		if i%5 == 0 {
+			want = rejected
+		}
+		if calls[key] != 1 || outcomes[key] != want {
+			t.Errorf("%s: called %d times with %v, want once with %v", key, calls[key], outcomes[key], want)
+		}
+	}
+}
+
+func TestAsyncProducerContextEnds(t *testing.T) {
+	// Not started, so nothing is buffered
+	fake := newFakeAsyncProducer(nil)
+	p := &AsyncProducer{producer: fake, logger: zap.NewNop()}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	p.ProduceAsync(ctx, testTopic, "u1", []byte("value"), nil, func(err error) { done <- err })
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("callback error = %v, want %v", err, context.Canceled)
+	}
+	if err := p.Produce(ctx, testTopic, "u1", []byte("value"), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Produce() = %v, want %v", err, context.Canceled)
+	}
+}
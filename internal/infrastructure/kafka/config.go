@@ -0,0 +1,154 @@
+package kafka
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// ClientConfig holds the settings shared by every producer and consumer.
+type ClientConfig struct {
+	ClientID string
+	SASL     SASLConfig
+	TLS      *TLSConfig // nil for plaintext
+}
+
+// SASLConfig authenticates to the brokers; an empty Mechanism disables it.
+type SASLConfig struct {
+	Mechanism string // PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+	Username  string
+	Password  string
+}
+
+// TLSConfig encrypts broker connections. An empty CAFile trusts the system
+// roots; CertFile and KeyFile present a client certificate.
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// GroupConfig configures how a consumer group joins and rebalances.
+type GroupConfig struct {
+	InitialOffset     string // Where a new group starts: "oldest" or "newest"
+	SessionTimeout    time.Duration
+	HeartbeatInterval time.Duration
+	RebalanceStrategy string // "roundrobin", "range" or "sticky"
+}
+
+// TopicConfig is how the notification consumer processes one topic.
+type TopicConfig struct {
+	Name        string
+	Workers     int // Concurrent workers per partition
+	MaxAttempts int // Delivery attempts per notification, through the retry tiers
+}
+
+// newConfig returns a sarama config with the client settings applied.
+func newConfig(client ClientConfig) (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	if client.ClientID != "" {
+		config.ClientID = client.ClientID
+	}
+
+	switch client.SASL.Mechanism {
+	case "":
+	case sarama.SASLTypePlaintext:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: sha256.New} }
+	case sarama.SASLTypeSCRAMSHA512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: sha512.New} }
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism %q", client.SASL.Mechanism)
+	}
+	if client.SASL.Mechanism != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.User = client.SASL.Username
+		config.Net.SASL.Password = client.SASL.Password
+	}
+
+	if client.TLS != nil {
+		tlsConfig, err := client.TLS.load()
+		if err != nil {
+			return nil, err
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+	return config, nil
+}
+
+func (c TLSConfig) load() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates in Kafka CA file")
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// apply sets the group settings on config.
+func (g GroupConfig) apply(config *sarama.Config) error {
+	switch g.InitialOffset {
+	case "oldest":
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	case "newest":
+		config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	default:
+		return fmt.Errorf("unsupported initial offset %q", g.InitialOffset)
+	}
+
+	switch g.RebalanceStrategy {
+	case "roundrobin":
+		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	case "range":
+		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRange()}
+	case "sticky":
+		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	default:
+		return fmt.Errorf("unsupported rebalance strategy %q", g.RebalanceStrategy)
+	}
+
+	if g.SessionTimeout > 0 {
+		config.Consumer.Group.Session.Timeout = g.SessionTimeout
+	}
+	if g.HeartbeatInterval > 0 {
+		config.Consumer.Group.Heartbeat.Interval = g.HeartbeatInterval
+	}
+	return nil
+}
+
+// newGroupConfig returns a sarama config for a consumer group.
+func newGroupConfig(client ClientConfig, group GroupConfig) (*sarama.Config, error) {
+	config, err := newConfig(client)
+	if err != nil {
+		return nil, err
+	}
+	if err := group.apply(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
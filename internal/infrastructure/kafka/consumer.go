@@ -2,47 +2,165 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/Shafeeqth/notification-service/internal/domain"
 	"github.com/Shafeeqth/notification-service/internal/infrastructure/notification"
 	"github.com/Shopify/sarama"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// claimLease is how long a consumer owns a notification it is delivering.
+// After that another consumer may take it over.
+const claimLease = 2 * time.Minute
+
+// claimPollInterval is how often a notification claimed by another consumer
+// is checked until that consumer completes, releases or loses it.
+var claimPollInterval = 5 * time.Second
+
+// sendTimeout bounds a delivery well within its claimLease. A rate limit
+// that would outlast it fails the delivery with its retry-after instead,
+// for a retry topic to wait out.
+const sendTimeout = time.Minute
+
+// dlqSuffix is appended to a topic to name its dead-letter topic.
+const dlqSuffix = ".dlq"
+
+// republishBackoff is how long a message that could not be dead-lettered
+// waits before it is tried again, doubling up to maxRepublishBackoff.
+var republishBackoff = time.Second
+
+const maxRepublishBackoff = 30 * time.Second
+
+// retryTier is a retry topic, named by appending suffix to the topic it
+// retries, whose messages are delivered no earlier than delay after the
+// failure that sent them there.
+type retryTier struct {
+	suffix string
+	delay  time.Duration
+}
+
+// retryTiers take a failed notification in turn, each retry waiting
+// longer; a notification failing on the last tier is dead-lettered.
+var retryTiers = []retryTier{
+	{".retry.30s", 30 * time.Second},
+	{".retry.5m", 5 * time.Minute},
+	{".retry.1h", time.Hour},
+}
+
+// route tells which configured topic a subscribed topic serves, and
+// which retry tier it is, or -1 for the topic itself.
+type route struct {
+	topic TopicConfig
+	tier  int
+}
+
+// Publisher produces raw messages, such as retries and dead letters.
+type Publisher interface {
+	Produce(ctx context.Context, topic, key string, message []byte, headers map[string]string) error
+}
+
+// partitionPauser stops and restarts fetching partitions, as
+// sarama.ConsumerGroup does.
+type partitionPauser interface {
+	Pause(partitions map[string][]int32)
+	Resume(partitions map[string][]int32)
+}
+
 type Consumer struct {
 	consumerGroup sarama.ConsumerGroup
 	repo          domain.NotificationRepository
 	sender        *notification.NotificationSender
+	codec         *EventCodec
+	publisher     Publisher
 	logger        *zap.Logger
-	workers       int
-	retries       int
+	owner         string
+	routes        map[string]route
 }
 
-func NewConsumer(brokers []string, groupId string, repo domain.NotificationRepository, sender *notification.NotificationSender, logger *zap.Logger, workers, retries int) (*Consumer, error) {
-	config := sarama.NewConfig()
-	config.Consumer.Group.Rebalance.Strategy = sarama.NewBalanceStrategyRoundRobin()
-	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+// NewConsumer creates the notification consumer of a group, processing
+// each of topics and its retry topics with the topic's workers and retry
+// policy. Failed notifications are republished to the retry topics and
+// dead-lettered through publisher.
+func NewConsumer(brokers []string, groupId string, client ClientConfig, group GroupConfig, topics []TopicConfig, repo domain.NotificationRepository, sender *notification.NotificationSender, codec *EventCodec, publisher Publisher, logger *zap.Logger) (*Consumer, error) {
+	config, err := newGroupConfig(client, group)
+	if err != nil {
+		logger.Error("Invalid Kafka consumer config", zap.Error(err))
+		return nil, err
+	}
 
 	consumerGroup, err := sarama.NewConsumerGroup(brokers, groupId, config)
 	if err != nil {
 		logger.Error("Failed to create Kafka consumer group", zap.Error(err))
 		return nil, err
 	}
-	return &Consumer{consumerGroup: consumerGroup, repo: repo, logger: logger, sender: sender, workers: workers, retries: retries}, nil
+	return &Consumer{consumerGroup: consumerGroup, repo: repo, logger: logger, sender: sender, codec: codec, publisher: publisher, owner: consumerOwner(), routes: newRoutes(topics)}, nil
 
 }
 
+// newRoutes routes each of topics and its retry tiers to the topic.
+func newRoutes(topics []TopicConfig) map[string]route {
+	routes := make(map[string]route, len(topics)*(len(retryTiers)+1))
+	for _, topic := range topics {
+		routes[topic.Name] = route{topic: topic, tier: -1}
+		for i, tier := range retryTiers {
+			routes[topic.Name+tier.suffix] = route{topic: topic, tier: i}
+		}
+	}
+	return routes
+}
+
+// subscribed lists the topics of routes.
+func subscribed(routes map[string]route) []string {
+	topics := make([]string, 0, len(routes))
+	for name := range routes {
+		topics = append(topics, name)
+	}
+	return topics
+}
+
+// retrier delays, retries and dead-letters the messages of the topics it
+// routes.
+type retrier struct {
+	publisher Publisher
+	pauser    partitionPauser
+	logger    *zap.Logger
+	routes    map[string]route
+}
+
 type ConsumerHandler struct {
-	repo    domain.NotificationRepository
-	sender  *notification.NotificationSender
-	logger  *zap.Logger
-	jobs    chan *sarama.ConsumerMessage
-	wg      sync.WaitGroup
-	workers int
-	retries int
+	retrier
+	repo   domain.NotificationRepository
+	sender *notification.NotificationSender
+	codec  *EventCodec
+	owner  string
+}
+
+// consumerOwner names this process in notification claims.
+func consumerOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "consumer"
+	}
+	return host + "/" + uuid.New().String()
+}
+
+// laneBuffer bounds the messages queued for each worker of a claim, so a
+// slow worker holds back the partition instead of buffering it.
+const laneBuffer = 16
+
+// job is a consumed message with its decoded notification, or the error
+// decoding it.
+type job struct {
+	msg          *sarama.ConsumerMessage
+	notification domain.Notification
+	err          error
 }
 
 func (h *ConsumerHandler) Setup(sarama.ConsumerGroupSession) error {
@@ -51,172 +169,387 @@ func (h *ConsumerHandler) Setup(sarama.ConsumerGroupSession) error {
 func (h *ConsumerHandler) Cleanup(sarama.ConsumerGroupSession) error {
 	return nil
 }
-func (h *ConsumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for i := 0; i < h.workers; i++ {
-		h.wg.Add(1)
-		go func() {
-			defer h.wg.Done()
-			for msg := range h.jobs {
-				var notification domain.Notification
-				if err := json.Unmarshal(msg.Value, &notification); err != nil {
-					h.logger.Error("Failed to un-marshall notification", zap.String("topic", msg.Topic), zap.Error(err))
-					continue
-				}
 
-				// check for idempotency
-				processed, err := h.repo.CheckIfProcessed(context.Background(), notification.ID)
-				if err != nil {
-					h.logger.Error("Failed to check if notification processed",
-						zap.String("notification_id", notification.ID),
-						zap.Error(err))
-					continue
-				}
-				if processed {
-					h.logger.Warn("Notification already processed",
-						zap.String("notification_id", notification.ID),
-						zap.Error(err))
-					continue
+// ConsumeClaim processes one partition with its topic's workers. Messages with
+// the same key, the user ID unless the producer set one, go to the same
+// worker and so are processed in order. Every message is settled, so one
+// that fails never holds back the offsets after it. When the session ends,
+// the workers finish the messages already handed to them before
+// ConsumeClaim returns, so their offsets are committed by this session.
+func (h *ConsumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	offsets := newOffsetTracker(session, claim.Topic(), claim.Partition())
+	r := h.routes[claim.Topic()]
+	lanes := make([]chan job, max(r.topic.Workers, 1))
+	var wg sync.WaitGroup
+	for i := range lanes {
+		lanes[i] = make(chan job, laneBuffer)
+		wg.Add(1)
+		go func(lane <-chan job) {
+			defer wg.Done()
+			for j := range lane {
+				if h.handle(session.Context(), j) {
+					offsets.settle(j.msg.Offset)
 				}
+			}
+		}(lanes[i])
+	}
+	defer func() {
+		for _, lane := range lanes {
+			close(lane)
+		}
+		wg.Wait()
+	}()
 
-				// Retry logic
-				for attempt := 1; attempt <= h.retries; attempt++ {
-					err := h.sender.Send(context.Background(), notification)
-					if err == nil {
-						break
-					}
-					h.logger.Warn("Failed to send notification, retrying",
-						zap.String("notification_id", notification.ID), zap.Int("attempt", attempt), zap.Error(err))
-					if attempt == h.retries {
-						h.logger.Error("Failed to send notification after retries",
-							zap.String("notification_id", notification.ID),
-							zap.Error(err))
-						continue
-					}
-					time.Sleep(time.Second * time.Duration(attempt))
-				}
-				if err := h.repo.MarkAsProcessed(context.Background(), notification.ID); err != nil {
-					h.logger.Error("Failed to mark notification as processed",
-						zap.String("notification_id", notification.ID), zap.Error(err))
-					continue
-				}
-				session.MarkMessage(msg, "")
-				h.logger.Info("Notification processed",
-					zap.String("topic", msg.Topic),
-					zap.Int32("partition", msg.Partition),
-					zap.Int64("offset", msg.Offset))
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
 			}
+			if r.tier >= 0 && !h.await(session.Context(), msg) {
+				return nil
+			}
+			j := job{msg: msg}
+			j.notification, j.err = h.codec.DecodeNotification(msg)
+			offsets.add(msg.Offset)
+			select {
+			case lanes[laneOf(j, len(lanes))] <- j:
+			case <-session.Context().Done():
+				return nil
+			}
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
 
-		}()
+// laneOf picks the worker for a message by its ordering key.
+func laneOf(j job, lanes int) int {
+	key := j.msg.Key
+	if len(key) == 0 {
+		key = []byte(j.notification.UserId)
 	}
-	for msg := range claim.Messages() {
-		h.jobs <- msg
+	hash := fnv.New32a()
+	hash.Write(key)
+	return int(hash.Sum32() % uint32(lanes))
+}
+
+// handle processes a job and reports whether it is settled, that is its
+// offset may be committed. A job is settled once it is delivered, skipped,
+// or handed to a retry or dead-letter topic; only one still unsettled when
+// wait ends is left for the next session to redeliver. Undecodable
+// messages, including those of an unknown version, go to the dead-letter
+// topic: redelivering them cannot help, but a newer or fixed consumer may
+// replay them from there.
+func (h *ConsumerHandler) handle(wait context.Context, j job) bool {
+	if j.err != nil {
+		h.logger.Error("Failed to decode notification",
+			zap.String("topic", j.msg.Topic),
+			zap.Int32("partition", j.msg.Partition),
+			zap.Int64("offset", j.msg.Offset),
+			zap.Error(j.err))
+		return h.deadLetter(wait, j.msg, j.err)
 	}
+	return h.process(wait, j.msg, j.notification)
+}
 
-	// close jobs channel and wait for workers to finish
-	close(h.jobs)
-	return nil
+// await holds a retried message until its not-before time, and reports
+// false if wait ends first. The partition is paused meanwhile, so the
+// broker stops fetching messages that would only queue behind it. Every
+// message of a retry topic is delayed equally, so holding back a partition
+// for its oldest message never delays one that is already due.
+func (h *retrier) await(wait context.Context, msg *sarama.ConsumerMessage) bool {
+	notBefore, err := time.Parse(time.RFC3339Nano, headerValues(msg.Headers)[HeaderRetryNotBefore])
+	if err != nil {
+		return true
+	}
+	delay := time.Until(notBefore)
+	if delay <= 0 {
+		return true
+	}
+
+	partitions := map[string][]int32{msg.Topic: {msg.Partition}}
+	h.pauser.Pause(partitions)
+	defer h.pauser.Resume(partitions)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-wait.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
 
-	// Feed messages to workers
-
-	// for msg := range claim.Messages() {
-	// 	var notification domain.Notification
-	// 	if err := json.Unmarshal(msg.Value, &notification); err != nil {
-	// 		h.logger.Error("Failed to unmarshal notification",
-	// 			zap.String("topic", msg.Topic),
-	// 			zap.Error(err))
-	// 		continue
-	// 	}
-	// 	// todo
-	// 	if err := h.repo.SendEmail(notification.UserId, notification.Subject, notification.Body); err != nil {
-	// 		h.logger.Error("Failed to process notification",
-	// 			zap.String("topic", msg.Topic),
-	// 			zap.Error(err))
-	// 		continue
-	// 	}
-	// 	session.MarkMessage(msg, "")
-	// 	h.logger.Info("Notification processed",
-	// 		zap.String("topic", msg.Topic),
-	// 		zap.Int32("partition", msg.Partition),
-	// 		zap.Int64("offset", msg.Offset))
-	// }
-	// return nil
-
-}
-
-func (c *Consumer) ConsumeNotifications() error {
-
-	topics := []string{
-		"email-notifications",
-		"sms-notifications",
-		"push-notifications"}
+// attempt returns which delivery attempt msg is, counting from 1.
+func attempt(msg *sarama.ConsumerMessage) int {
+	n, err := strconv.Atoi(headerValues(msg.Headers)[HeaderRetryAttempt])
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// retried returns the retry tier msg was last sent to, or -1 if it has not
+// been retried. Messages republished before the tier was recorded fall
+// back to the tier of the topic they came from.
+func (r route) retried(msg *sarama.ConsumerMessage) int {
+	tier, err := strconv.Atoi(headerValues(msg.Headers)[HeaderRetryTier])
+	if err != nil || tier < -1 || tier >= len(retryTiers) {
+		return r.tier
+	}
+	return tier
+}
+
+// retry republishes a failed message to the tier after the one it was
+// last sent to, or dead-letters it if the failure is permanent or its
+// attempts or tiers are used up, and reports whether the message is
+// settled. A rate-limited failure goes to the first remaining tier that
+// waits at least its retry-after, so each tier keeps delaying its messages
+// equally; the tier used is recorded so the next retry continues from it.
+// A message that could not be republished is dead-lettered instead, so it
+// is not lost.
+func (h *retrier) retry(wait context.Context, msg *sarama.ConsumerMessage, failure *domain.DeliveryError) bool {
+	r := h.routes[msg.Topic]
+	n := attempt(msg)
+	next := r.retried(msg) + 1
+	if failure.Class == domain.DeliveryPermanent || n >= r.topic.MaxAttempts || next >= len(retryTiers) {
+		return h.deadLetter(wait, msg, failure)
+	}
+
+	for next < len(retryTiers)-1 && retryTiers[next].delay < failure.RetryAfter {
+		next++
+	}
+	tier := retryTiers[next]
+	notBefore := time.Now().Add(max(tier.delay, failure.RetryAfter))
+	headers := headerValues(msg.Headers)
+	headers[HeaderRetryAttempt] = strconv.Itoa(n + 1)
+	headers[HeaderRetryNotBefore] = notBefore.UTC().Format(time.RFC3339Nano)
+	headers[HeaderRetryError] = failure.Error()
+	headers[HeaderRetryTier] = strconv.Itoa(next)
+
+	topic := r.topic.Name + tier.suffix
+	if err := h.publisher.Produce(context.Background(), topic, string(msg.Key), msg.Value, headers); err != nil {
+		h.logger.Error("Failed to republish message for retry, dead-lettering it",
+			zap.String("topic", topic), zap.Int64("offset", msg.Offset), zap.Error(err))
+		return h.deadLetter(wait, msg, failure)
+	}
+	h.logger.Warn("Message scheduled for retry",
+		zap.String("topic", topic),
+		zap.Int("attempt", n+1),
+		zap.Time("not_before", notBefore))
+	return true
+}
+
+// deadLetter dead-letters a message of any of a topic's retry tiers to
+// the topic's own dead-letter topic.
+func (h *retrier) deadLetter(wait context.Context, msg *sarama.ConsumerMessage, reason error) bool {
+	return deadLetter(wait, h.publisher, h.logger, h.routes[msg.Topic].topic.Name+dlqSuffix, msg, reason)
+}
+
+// deadLetter copies msg to the dead-letter topic with the reason and its
+// origin, trying again with backoff until it is stored, and reports
+// whether it was. It reports false only if wait ends first, leaving the
+// message for the next session so it is not lost.
+func deadLetter(wait context.Context, dlq Publisher, logger *zap.Logger, topic string, msg *sarama.ConsumerMessage, reason error) bool {
+	headers := headerValues(msg.Headers)
+	headers[HeaderDLQError] = reason.Error()
+	headers[HeaderDLQTopic] = msg.Topic
+	headers[HeaderDLQPartition] = strconv.Itoa(int(msg.Partition))
+	headers[HeaderDLQOffset] = strconv.FormatInt(msg.Offset, 10)
+
+	for backoff := republishBackoff; ; backoff = min(backoff*2, maxRepublishBackoff) {
+		err := dlq.Produce(context.Background(), topic, string(msg.Key), msg.Value, headers)
+		if err == nil {
+			break
+		}
+		logger.Error("Failed to dead-letter message, retrying",
+			zap.String("topic", topic),
+			zap.Int64("offset", msg.Offset),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+		select {
+		case <-wait.Done():
+			return false
+		case <-time.After(backoff):
+		}
+	}
+	logger.Warn("Message dead-lettered",
+		zap.String("topic", topic), zap.Int64("offset", msg.Offset))
+	return true
+}
+
+// offsetTracker marks a partition's offsets in order: an offset is marked
+// only once its message and every message before it are settled, so a
+// restart never skips a message still in flight.
+type offsetTracker struct {
+	session   sarama.ConsumerGroupSession
+	topic     string
+	partition int32
+
+	mu      sync.Mutex
+	pending []int64 // Offsets in arrival order, oldest first
+	settled map[int64]bool
+}
+
+func newOffsetTracker(session sarama.ConsumerGroupSession, topic string, partition int32) *offsetTracker {
+	return &offsetTracker{session: session, topic: topic, partition: partition, settled: make(map[int64]bool)}
+}
+
+func (t *offsetTracker) add(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, offset)
+}
+
+func (t *offsetTracker) settle(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.settled[offset] = true
+	next := int64(-1)
+	for len(t.pending) > 0 && t.settled[t.pending[0]] {
+		delete(t.settled, t.pending[0])
+		next = t.pending[0] + 1
+		t.pending = t.pending[1:]
+	}
+	if next >= 0 {
+		// The committed offset is the next message to consume
+		t.session.MarkOffset(t.topic, t.partition, next, "")
+	}
+}
+
+// process delivers one notification under a claim, so it is sent once
+// however often it is redelivered or whichever consumer receives it, and
+// reports whether the message is settled. A failed delivery releases the
+// claim and is handed to a retry topic or the dead-letter topic, as its
+// classification calls for, so the partition moves on instead of waiting
+// for the retry. A claim that could not be decided is retried the same
+// way. A suppressed delivery is settled as processed, since no retry or
+// replay can reach the recipient. Only a message still waiting on another
+// consumer's claim when wait ends is left unsettled, for the next session
+// to redeliver.
+func (h *ConsumerHandler) process(wait context.Context, msg *sarama.ConsumerMessage, notification domain.Notification) bool {
+	// Messages produced before multi-tenancy carry no tenant
+	ctx := contextFromHeaders(context.Background(), msg.Headers)
+	if notification.TenantId != "" {
+		ctx = domain.ContextWithTenant(ctx, notification.TenantId)
+	} else {
+		notification.TenantId = domain.TenantFromContext(ctx)
+	}
+	if err := notification.ValidatePayload(); err != nil {
+		h.logger.Error("Invalid notification payload",
+			zap.String("notification_id", notification.ID),
+			zap.String("topic", msg.Topic),
+			zap.Error(err))
+		return h.deadLetter(wait, msg, err)
+	}
+
+	outcome, err := h.claim(wait, ctx, notification.ID)
+	if err != nil {
+		if wait.Err() != nil {
+			return false
+		}
+		h.logger.Error("Failed to claim notification",
+			zap.String("notification_id", notification.ID),
+			zap.Error(err))
+		return h.retry(wait, msg, domain.ClassifyDelivery(err))
+	}
+	if outcome == domain.ClaimProcessed {
+		h.logger.Warn("Notification already processed",
+			zap.String("notification_id", notification.ID))
+		return true
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	err = h.sender.Send(sendCtx, notification)
+	cancel()
+	if err != nil {
+		failure := domain.ClassifyDelivery(err)
+		if failure.Class == domain.DeliverySuppressed {
+			h.logger.Warn("Notification suppressed",
+				zap.String("notification_id", notification.ID),
+				zap.Error(err))
+			if err := h.repo.CompleteNotification(ctx, notification.ID, h.owner); err != nil {
+				h.logger.Error("Failed to mark suppressed notification as processed",
+					zap.String("notification_id", notification.ID), zap.Error(err))
+			}
+			return true
+		}
+		h.logger.Error("Failed to send notification",
+			zap.String("notification_id", notification.ID),
+			zap.Int("attempt", attempt(msg)),
+			zap.Stringer("class", failure.Class),
+			zap.Duration("retry_after", failure.RetryAfter),
+			zap.Error(err))
+		if err := h.repo.ReleaseNotification(ctx, notification.ID, h.owner); err != nil {
+			h.logger.Error("Failed to release notification claim",
+				zap.String("notification_id", notification.ID), zap.Error(err))
+		}
+		return h.retry(wait, msg, failure)
+	}
+
+	if err := h.repo.CompleteNotification(ctx, notification.ID, h.owner); err != nil {
+		// The notification went out; a redelivery would only duplicate it
+		if errors.Is(err, domain.ErrClaimLost) {
+			h.logger.Warn("Notification claim expired during delivery, it may be sent twice",
+				zap.String("notification_id", notification.ID))
+		} else {
+			h.logger.Error("Failed to mark notification as processed",
+				zap.String("notification_id", notification.ID), zap.Error(err))
+		}
+	}
+	h.logger.Info("Notification processed",
+		zap.String("notification_id", notification.ID),
+		zap.String("correlation_id", domain.TraceFromContext(ctx).CorrelationID),
+		zap.String("topic", msg.Topic),
+		zap.Int32("partition", msg.Partition),
+		zap.Int64("offset", msg.Offset))
+	return true
+}
+
+// claim acquires the notification or reports it processed. While another
+// consumer holds it, for instance the previous owner of the partition
+// during a rebalance, claim waits: skipping would lose the notification if
+// that consumer then failed. wait bounds the waiting.
+func (h *ConsumerHandler) claim(wait, ctx context.Context, notificationId string) (domain.ClaimOutcome, error) {
+	for {
+		outcome, err := h.repo.ClaimNotification(ctx, notificationId, h.owner, claimLease)
+		if err != nil || outcome != domain.ClaimHeld {
+			return outcome, err
+		}
+		h.logger.Info("Notification claimed by another consumer, waiting",
+			zap.String("notification_id", notificationId))
+		select {
+		case <-wait.Done():
+			return outcome, wait.Err()
+		case <-time.After(claimPollInterval):
+		}
+	}
+}
+
+// ConsumeNotifications consumes until ctx is done, then returns nil once
+// the in-flight messages are processed and their offsets marked.
+func (c *Consumer) ConsumeNotifications(ctx context.Context) error {
+	topics := subscribed(c.routes)
 	handler := &ConsumerHandler{
+		retrier: retrier{publisher: c.publisher, pauser: c.consumerGroup, logger: c.logger, routes: c.routes},
 		repo:    c.repo,
-		logger:  c.logger,
-		jobs:    make(chan *sarama.ConsumerMessage, 100),
-		workers: c.workers,
 		sender:  c.sender,
-		retries: c.retries,
+		codec:   c.codec,
+		owner:   c.owner,
 	}
 
-	for {
-		err := c.consumerGroup.Consume(context.Background(), topics, handler)
+	// Consume returns at every rebalance, and joins the new session when
+	// called again
+	for ctx.Err() == nil {
+		err := c.consumerGroup.Consume(ctx, topics, handler)
 		if err != nil {
-			c.logger.Error("Failed to consume email notifications", zap.Error(err))
+			c.logger.Error("Failed to consume notifications", zap.Error(err))
 			return err
 		}
 	}
-	// return c.consumeTopic("email-notifications", func(n domain.Notification) error {
-	// 	return c.repo.SendEmail(n.UserId, n.Subject, n.Body)
-	// })
-}
-
-// func (c *Consumer) ConsumeInAppNotifications() error {
-// 	return c.consumeTopic("inapp-notifications", func(n domain.Notification) error {
-// 		return c.repo.SendInApp(n.UserId, n.Message)
-// 	})
-// }
-
-// func (c *Consumer) consumeTopic(topic string, handler func(domain.Notification) error) error {
-// 	partitions, err := c.consumer.Partitions(topic)
-// 	if err != nil {
-// 		c.logger.Error("Failed to start consumer for topic", zap.String("topic", topic), zap.Error(err))
-// 		return err
-// 	}
-
-// 	for _, partition := range partitions {
-// 		pc, err := c.consumer.ConsumePartition(topic, partition, sarama.OffsetNewest)
-// 		if err != nil {
-// 			c.logger.Error("Failed to start consumer for partition",
-// 				zap.String("topic", topic),
-// 				zap.Int32("partition", partition),
-// 				zap.Error(err))
-// 		}
-
-// 		go func(pc sarama.PartitionConsumer) {
-// 			for msg := range pc.Messages() {
-// 				var notification domain.Notification
-// 				if err := json.Unmarshal(msg.Value, &notification); err != nil {
-// 					c.logger.Error("Failed to unmarshall notification",
-// 						zap.String("topic", topic),
-// 						zap.Error(err))
-// 					continue
-// 				}
-// 				if err := handler(notification); err != nil {
-// 					c.logger.Error("Failed to process notification",
-// 						zap.String("topic", topic),
-// 						zap.Error(err))
-// 					continue
-// 				}
-// 				c.logger.Info("Notification processed",
-// 					zap.String("topic", topic),
-// 					zap.Int32("partition", msg.Partition),
-// 					zap.Int64("offset", msg.Offset))
-// 			}
-
-// 		}(pc)
-// 	}
-// 	return nil
-// }
+	return nil
+}
 
 func (c *Consumer) Close() error {
 	if err := c.consumerGroup.Close(); err != nil {
@@ -0,0 +1,574 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/notification"
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// claimStore is an in-memory NotificationRepository with the claim
+// semantics of the database repository. Other methods are not used by the
+// consumer and panic.
+type claimStore struct {
+	domain.NotificationRepository
+
+	mu       sync.Mutex
+	now      time.Time
+	claims   map[string]*storedClaim
+	held     chan string // Receives the owner of every ClaimHeld outcome
+	claimErr error
+}
+
+type storedClaim struct {
+	owner     string
+	expires   time.Time
+	processed bool
+}
+
+func newClaimStore() *claimStore {
+	return &claimStore{now: time.Now(), claims: make(map[string]*storedClaim), held: make(chan string, 16)}
+}
+
+func (s *claimStore) ClaimNotification(_ context.Context, id, owner string, lease time.Duration) (domain.ClaimOutcome, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.claimErr != nil {
+		return 0, s.claimErr
+	}
+	c, ok := s.claims[id]
+	switch {
+	case !ok || (!c.processed && c.expires.Before(s.now)):
+		s.claims[id] = &storedClaim{owner: owner, expires: s.now.Add(lease)}
+		return domain.ClaimAcquired, nil
+	case c.processed:
+		return domain.ClaimProcessed, nil
+	}
+	select {
+	case s.held <- owner:
+	default:
+	}
+	return domain.ClaimHeld, nil
+}
+
+func (s *claimStore) CompleteNotification(_ context.Context, id, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.claims[id]
+	if !ok || c.owner != owner || c.processed {
+		return domain.ErrClaimLost
+	}
+	c.processed = true
+	return nil
+}
+
+func (s *claimStore) ReleaseNotification(_ context.Context, id, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.claims[id]; ok && c.owner == owner && !c.processed {
+		delete(s.claims, id)
+	}
+	return nil
+}
+
+func (s *claimStore) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+// gatedStrategy records deliveries. Each delivery signals started, then
+// waits for proceed if it is set, and fails with the next of errs.
+type gatedStrategy struct {
+	started chan struct{}
+	proceed chan struct{}
+
+	mu   sync.Mutex
+	sent int
+	errs []error
+}
+
+func (g *gatedStrategy) Send(ctx context.Context, _ domain.Notification) error {
+	if g.started != nil {
+		g.started <- struct{}{}
+	}
+	if g.proceed != nil {
+		select {
+		case <-g.proceed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errs) > 0 {
+		err := g.errs[0]
+		g.errs = g.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	g.sent++
+	return nil
+}
+
+func (g *gatedStrategy) deliveries() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.sent
+}
+
+// recordingPublisher records produced messages by topic. Each topic of
+// failures fails that many times first, or always if negative.
+type recordingPublisher struct {
+	mu       sync.Mutex
+	produced map[string][]map[string]string
+	failures map[string]int
+}
+
+func (p *recordingPublisher) Produce(_ context.Context, topic, _ string, _ []byte, headers map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := p.failures[topic]; n != 0 {
+		p.failures[topic] = n - 1
+		return errors.New("broker unavailable")
+	}
+	if p.produced == nil {
+		p.produced = make(map[string][]map[string]string)
+	}
+	p.produced[topic] = append(p.produced[topic], headers)
+	return nil
+}
+
+func (p *recordingPublisher) count(topic string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.produced[topic])
+}
+
+const testTopic = "notifications.email"
+
+func testHandler(owner string, repo domain.NotificationRepository, strategy notification.SenderStrategy, publisher Publisher) *ConsumerHandler {
+	routes := newRoutes([]TopicConfig{{Name: testTopic, Workers: 1, MaxAttempts: 4}})
+	sender := notification.NewNotificationSender(map[domain.NotificationType]notification.SenderStrategy{
+		domain.EmailNotification: strategy,
+	}, nil)
+	return &ConsumerHandler{
+		retrier: retrier{publisher: publisher, pauser: &recordingPauser{}, logger: zap.NewNop(), routes: routes},
+		repo:    repo,
+		sender:  sender,
+		owner:   owner,
+	}
+}
+
+// recordingPauser records paused partitions.
+type recordingPauser struct {
+	mu     sync.Mutex
+	paused map[string][]int32
+	pauses int
+}
+
+func (p *recordingPauser) Pause(partitions map[string][]int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = partitions
+	p.pauses++
+}
+
+func (p *recordingPauser) Resume(partitions map[string][]int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for topic := range partitions {
+		delete(p.paused, topic)
+	}
+}
+
+func testNotification() domain.Notification {
+	return domain.Notification{
+		ID:        "7f0c1a52-3c1e-4d8e-9a55-0b7a8f0e9d11",
+		TenantId:  domain.DefaultTenant,
+		UserId:    "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f",
+		Type:      domain.EmailNotification,
+		Recipient: "ann@example.com",
+		Subject:   "Welcome",
+		Body:      "Hello Ann",
+	}
+}
+
+func shortenClaimPoll(t *testing.T) {
+	t.Helper()
+	savedPoll, savedBackoff := claimPollInterval, republishBackoff
+	claimPollInterval, republishBackoff = 10*time.Millisecond, time.Millisecond
+	t.Cleanup(func() { claimPollInterval, republishBackoff = savedPoll, savedBackoff })
+}
+
+func TestProcessConcurrentDuplicate(t *testing.T) {
+	shortenClaimPoll(t)
+	repo := newClaimStore()
+	strategy := &gatedStrategy{started: make(chan struct{}, 2), proceed: make(chan struct{})}
+	publisher := &recordingPublisher{}
+	first := testHandler("consumer-a", repo, strategy, publisher)
+	second := testHandler("consumer-b", repo, strategy, publisher)
+	n := testNotification()
+
+	var wg sync.WaitGroup
+	settled := make([]bool, 2)
+	run := func(i int, h *ConsumerHandler, offset int64) {
+		defer wg.Done()
+		msg := &sarama.ConsumerMessage{Topic: testTopic, Offset: offset}
+		settled[i] = h.process(context.Background(), msg, n)
+	}
+
+	// The first consumer holds the claim while delivering; the second, a
+	// redelivery of the same notification, waits on it and then skips it
+	wg.Add(1)
+	go run(0, first, 1)
+	<-strategy.started
+	wg.Add(1)
+	go run(1, second, 2)
+	if owner := <-repo.held; owner != "consumer-b" {
+		t.Fatalf("held outcome reported to %q, want consumer-b", owner)
+	}
+	close(strategy.proceed)
+	wg.Wait()
+
+	if got := strategy.deliveries(); got != 1 {
+		t.Errorf("deliveries = %d, want 1", got)
+	}
+	if !settled[0] || !settled[1] {
+		t.Errorf("settled = %v, want both", settled)
+	}
+	if got := publisher.count(testTopic + retryTiers[0].suffix); got != 0 {
+		t.Errorf("retries = %d, want 0", got)
+	}
+}
+
+func TestProcessConcurrentRace(t *testing.T) {
+	shortenClaimPoll(t)
+	const consumers = 8
+	repo := newClaimStore()
+	strategy := &gatedStrategy{}
+	publisher := &recordingPublisher{}
+	n := testNotification()
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range consumers {
+		h := testHandler("consumer-"+string(rune('a'+i)), repo, strategy, publisher)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if !h.process(context.Background(), &sarama.ConsumerMessage{Topic: testTopic, Offset: int64(i)}, n) {
+				t.Errorf("consumer %d left the message unsettled", i)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got := strategy.deliveries(); got != 1 {
+		t.Errorf("deliveries = %d, want 1", got)
+	}
+}
+
+func TestProcessFailureReleasesClaim(t *testing.T) {
+	shortenClaimPoll(t)
+	repo := newClaimStore()
+	strategy := &gatedStrategy{errs: []error{domain.TransientError(errors.New("connection reset"))}}
+	publisher := &recordingPublisher{}
+	first := testHandler("consumer-a", repo, strategy, publisher)
+	second := testHandler("consumer-b", repo, strategy, publisher)
+	n := testNotification()
+
+	if !first.process(context.Background(), &sarama.ConsumerMessage{Topic: testTopic, Offset: 1}, n) {
+		t.Fatal("failed delivery left unsettled")
+	}
+	if got := publisher.count(testTopic + retryTiers[0].suffix); got != 1 {
+		t.Fatalf("retries = %d, want 1", got)
+	}
+
+	// The released claim lets the retry deliver at once
+	retried := &sarama.ConsumerMessage{Topic: testTopic + retryTiers[0].suffix, Offset: 1}
+	if !second.process(context.Background(), retried, n) {
+		t.Fatal("retry left unsettled")
+	}
+	if got := strategy.deliveries(); got != 1 {
+		t.Errorf("deliveries = %d, want 1", got)
+	}
+}
+
+func TestProcessExpiredLeaseTakeover(t *testing.T) {
+	shortenClaimPoll(t)
+	repo := newClaimStore()
+	n := testNotification()
+
+	// A consumer that stalls past its lease loses the claim to a redelivery
+	if outcome, _ := repo.ClaimNotification(context.Background(), n.ID, "stalled", claimLease); outcome != domain.ClaimAcquired {
+		t.Fatalf("first claim = %v, want acquired", outcome)
+	}
+	repo.advance(claimLease + time.Second)
+
+	strategy := &gatedStrategy{}
+	h := testHandler("consumer-b", repo, strategy, &recordingPublisher{})
+	if !h.process(context.Background(), &sarama.ConsumerMessage{Topic: testTopic, Offset: 1}, n) {
+		t.Fatal("takeover left unsettled")
+	}
+	if got := strategy.deliveries(); got != 1 {
+		t.Errorf("deliveries = %d, want 1", got)
+	}
+	if err := repo.CompleteNotification(context.Background(), n.ID, "stalled"); !errors.Is(err, domain.ErrClaimLost) {
+		t.Errorf("stalled CompleteNotification = %v, want ErrClaimLost", err)
+	}
+}
+
+func TestProcessWaitEndsWhileHeld(t *testing.T) {
+	shortenClaimPoll(t)
+	repo := newClaimStore()
+	n := testNotification()
+	if _, err := repo.ClaimNotification(context.Background(), n.ID, "other", claimLease); err != nil {
+		t.Fatal(err)
+	}
+
+	strategy := &gatedStrategy{}
+	h := testHandler("consumer-b", repo, strategy, &recordingPublisher{})
+	wait, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-repo.held
+		cancel()
+	}()
+	if h.process(wait, &sarama.ConsumerMessage{Topic: testTopic, Offset: 1}, n) {
+		t.Error("message settled while another consumer holds its claim")
+	}
+	if got := strategy.deliveries(); got != 0 {
+		t.Errorf("deliveries = %d, want 0", got)
+	}
+}
+
+// markingSession records the offsets marked on it.
+type markingSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *markingSession) MarkOffset(_ string, _ int32, offset int64, _ string) {
+	s.marked = append(s.marked, offset)
+}
+
+func TestOffsetTracker(t *testing.T) {
+	tests := []struct {
+		name   string
+		settle []int64
+		marked []int64
+	}{
+		{"in order", []int64{10, 11, 12, 13}, []int64{11, 12, 13, 14}},
+		{"out of order waits for the oldest", []int64{12, 11, 10, 13}, []int64{13, 14}},
+		{"gap holds back later offsets", []int64{10, 12, 13}, []int64{11}},
+		{"nothing before the oldest", []int64{13}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &markingSession{}
+			offsets := newOffsetTracker(session, testTopic, 0)
+			for offset := int64(10); offset < 14; offset++ {
+				offsets.add(offset)
+			}
+			for _, offset := range tt.settle {
+				offsets.settle(offset)
+			}
+			if !reflect.DeepEqual(session.marked, tt.marked) {
+				t.Errorf("marked %v, want %v", session.marked, tt.marked)
+			}
+		})
+	}
+}
+
+func TestHandleSettlesFailures(t *testing.T) {
+	dlq := testTopic + dlqSuffix
+	firstRetry := testTopic + retryTiers[0].suffix
+	broken := errors.New("database unavailable")
+
+	tests := []struct {
+		name      string
+		job       job
+		claimErr  error
+		sendErr   error
+		failures  map[string]int
+		cancel    bool
+		settled   bool
+		published map[string]int
+	}{
+		{
+			name:      "undecodable is dead-lettered",
+			job:       job{err: domain.ErrUnknownEventVersion},
+			settled:   true,
+			published: map[string]int{dlq: 1},
+		},
+		{
+			name:      "dead letter is retried until stored",
+			job:       job{err: domain.ErrUnknownEventVersion},
+			failures:  map[string]int{dlq: 3},
+			settled:   true,
+			published: map[string]int{dlq: 1},
+		},
+		{
+			name:      "undecided claim is retried",
+			claimErr:  broken,
+			settled:   true,
+			published: map[string]int{firstRetry: 1},
+		},
+		{
+			name:      "failed retry is dead-lettered",
+			sendErr:   domain.TransientError(errors.New("connection reset")),
+			failures:  map[string]int{firstRetry: -1},
+			settled:   true,
+			published: map[string]int{dlq: 1},
+		},
+		{
+			name:      "permanent failure is dead-lettered",
+			sendErr:   domain.PermanentError(errors.New("message rejected")),
+			settled:   true,
+			published: map[string]int{dlq: 1},
+		},
+		{
+			name:      "unstored dead letter waits for the next session",
+			job:       job{err: domain.ErrUnknownEventVersion},
+			failures:  map[string]int{dlq: -1},
+			cancel:    true,
+			settled:   false,
+			published: map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortenClaimPoll(t)
+			repo := newClaimStore()
+			repo.claimErr = tt.claimErr
+			publisher := &recordingPublisher{failures: tt.failures}
+			strategy := &gatedStrategy{errs: []error{tt.sendErr}}
+			h := testHandler("consumer-a", repo, strategy, publisher)
+
+			wait, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+			j := tt.job
+			j.msg = &sarama.ConsumerMessage{Topic: testTopic, Offset: 1}
+			if j.err == nil {
+				j.notification = testNotification()
+			}
+			if settled := h.handle(wait, j); settled != tt.settled {
+				t.Errorf("settled = %v, want %v", settled, tt.settled)
+			}
+			for topic, want := range tt.published {
+				if got := publisher.count(topic); got != want {
+					t.Errorf("%s got %d messages, want %d", topic, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAwaitPausesPartition(t *testing.T) {
+	tests := []struct {
+		name      string
+		notBefore time.Duration
+		cancel    bool
+		due       bool
+		paused    bool
+	}{
+		{"already due", -time.Second, false, true, false},
+		{"waits paused", 30 * time.Millisecond, false, true, true},
+		{"session ends", time.Hour, true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pauser := &recordingPauser{}
+			h := &retrier{pauser: pauser, logger: zap.NewNop()}
+			msg := &sarama.ConsumerMessage{
+				Topic:     testTopic + retryTiers[0].suffix,
+				Partition: 3,
+				Headers: []*sarama.RecordHeader{{
+					Key:   []byte(HeaderRetryNotBefore),
+					Value: []byte(time.Now().Add(tt.notBefore).Format(time.RFC3339Nano)),
+				}},
+			}
+			wait, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+
+			if due := h.await(wait, msg); due != tt.due {
+				t.Errorf("await() = %v, want %v", due, tt.due)
+			}
+			if paused := pauser.pauses > 0; paused != tt.paused {
+				t.Errorf("paused = %v, want %v", paused, tt.paused)
+			}
+			if len(pauser.paused) != 0 {
+				t.Errorf("partitions left paused: %v", pauser.paused)
+			}
+		})
+	}
+}
+
+func TestRetryContinuesFromTier(t *testing.T) {
+	dlq := testTopic + dlqSuffix
+	transient := domain.ClassifyDelivery(domain.TransientError(errors.New("connection reset")))
+	limited := func(after time.Duration) *domain.DeliveryError {
+		return domain.ClassifyDelivery(domain.RateLimitedError(errors.New("slow down"), after))
+	}
+
+	tests := []struct {
+		name    string
+		tier    int // Tier msg is consumed from, -1 for the topic itself
+		headers map[string]string
+		failure *domain.DeliveryError
+		topic   string
+		attempt string
+		next    string
+	}{
+		{"first failure", -1, nil, transient, testTopic + retryTiers[0].suffix, "2", "0"},
+		{"next tier", 0, map[string]string{HeaderRetryAttempt: "2", HeaderRetryTier: "0"}, transient, testTopic + retryTiers[1].suffix, "3", "1"},
+		{"rate limit skips tiers", -1, nil, limited(10 * time.Minute), testTopic + retryTiers[2].suffix, "2", "2"},
+		{"rate limit skips from a tier", 0, map[string]string{HeaderRetryAttempt: "2", HeaderRetryTier: "0"}, limited(time.Minute), testTopic + retryTiers[1].suffix, "3", "1"},
+		{"skipped tier is not used again", 2, map[string]string{HeaderRetryAttempt: "2", HeaderRetryTier: "2"}, transient, dlq, "", ""},
+		{"unrecorded tier falls back to the topic", 1, map[string]string{HeaderRetryAttempt: "2"}, transient, testTopic + retryTiers[2].suffix, "3", "2"},
+		{"attempts used up", 1, map[string]string{HeaderRetryAttempt: "4", HeaderRetryTier: "1"}, transient, dlq, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &recordingPublisher{}
+			h := testHandler("consumer-a", newClaimStore(), &gatedStrategy{}, publisher)
+			msg := consumerMessage(nil, tt.headers)
+			if tt.tier >= 0 {
+				msg.Topic += retryTiers[tt.tier].suffix
+			}
+
+			if !h.retry(context.Background(), msg, tt.failure) {
+				t.Fatal("retry() did not settle the message")
+			}
+			produced := publisher.produced[tt.topic]
+			if len(produced) != 1 {
+				t.Fatalf("produced %v, want one message to %s", publisher.produced, tt.topic)
+			}
+			if tt.topic == dlq {
+				return
+			}
+			if got := produced[0][HeaderRetryAttempt]; got != tt.attempt {
+				t.Errorf("%s = %q, want %q", HeaderRetryAttempt, got, tt.attempt)
+			}
+			if got := produced[0][HeaderRetryTier]; got != tt.next {
+				t.Errorf("%s = %q, want %q", HeaderRetryTier, got, tt.next)
+			}
+		})
+	}
+}
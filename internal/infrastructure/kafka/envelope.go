@@ -0,0 +1,239 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"github.com/Shopify/sarama"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	protobuf "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// NotificationRequested is the event type of notifications to deliver.
+const NotificationRequested = "notification.requested"
+
+// notificationRequestedVersion is the version produced. Version 1 was the
+// notification as unwrapped JSON.
+const notificationRequestedVersion = 2
+
+// EventFormat is how envelopes are encoded.
+type EventFormat string
+
+const (
+	FormatProtobuf EventFormat = "protobuf"
+	FormatJSON     EventFormat = "json"
+)
+
+// Content types of encoded envelopes. Messages without a content-type
+// header predate the envelope.
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// event is a notification.requested payload on its way to the current
+// version.
+type event struct {
+	version uint32
+	payload *anypb.Any // From version 2
+	legacy  []byte     // Version 1: the notification as JSON
+}
+
+// upcasters turn an event of each old version into the next version.
+var upcasters = map[uint32]func(event) (event, error){
+	1: upcastLegacyNotification,
+}
+
+func upcastLegacyNotification(e event) (event, error) {
+	var notification domain.Notification
+	if err := json.Unmarshal(e.legacy, &notification); err != nil {
+		return event{}, fmt.Errorf("%w: %v", domain.ErrMalformedEvent, err)
+	}
+	requested, err := toEvent(notification)
+	if err != nil {
+		return event{}, fmt.Errorf("%w: %v", domain.ErrMalformedEvent, err)
+	}
+	payload, err := anypb.New(requested)
+	if err != nil {
+		return event{}, err
+	}
+	return event{version: 2, payload: payload}, nil
+}
+
+// EventCodec encodes notifications as versioned envelopes, and decodes
+// envelopes of either format and any known version.
+type EventCodec struct {
+	format   EventFormat
+	producer string
+}
+
+// NewEventCodec creates a codec encoding in format, naming producer as the
+// source of its events.
+func NewEventCodec(format EventFormat, producer string) (*EventCodec, error) {
+	if format != FormatProtobuf && format != FormatJSON {
+		return nil, fmt.Errorf("unsupported event format %q", format)
+	}
+	return &EventCodec{format: format, producer: producer}, nil
+}
+
+// EncodeNotification returns the message for a notification and the
+// headers describing its encoding.
+func (c *EventCodec) EncodeNotification(notification domain.Notification) ([]byte, map[string]string, error) {
+	requested, err := toEvent(notification)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := anypb.New(requested)
+	if err != nil {
+		return nil, nil, err
+	}
+	envelope := &proto.EventEnvelope{
+		EventId:    uuid.New().String(),
+		EventType:  NotificationRequested,
+		Version:    notificationRequestedVersion,
+		OccurredAt: timestamppb.Now(),
+		Producer:   c.producer,
+		Payload:    payload,
+	}
+
+	var data []byte
+	contentType := contentTypeProtobuf
+	if c.format == FormatJSON {
+		data, err = protojson.Marshal(envelope)
+		contentType = contentTypeJSON
+	} else {
+		data, err = protobuf.Marshal(envelope)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, map[string]string{
+		HeaderContentType:   contentType,
+		HeaderEventType:     NotificationRequested,
+		HeaderSchemaVersion: strconv.Itoa(notificationRequestedVersion),
+	}, nil
+}
+
+// DecodeNotification decodes a notification message, upcasting old
+// versions. It returns an error matching domain.ErrUnknownEventVersion for
+// a type or version it doesn't know, and domain.ErrMalformedEvent for a
+// message it cannot parse.
+func (c *EventCodec) DecodeNotification(msg *sarama.ConsumerMessage) (domain.Notification, error) {
+	e, err := decodeEnvelope(msg)
+	if err != nil {
+		return domain.Notification{}, err
+	}
+	for e.version < notificationRequestedVersion {
+		upcast, ok := upcasters[e.version]
+		if !ok {
+			break
+		}
+		if e, err = upcast(e); err != nil {
+			return domain.Notification{}, err
+		}
+	}
+	if e.version != notificationRequestedVersion {
+		return domain.Notification{}, fmt.Errorf("%w: %s version %d", domain.ErrUnknownEventVersion, NotificationRequested, e.version)
+	}
+
+	var requested proto.NotificationRequested
+	if err := e.payload.UnmarshalTo(&requested); err != nil {
+		return domain.Notification{}, fmt.Errorf("%w: %v", domain.ErrMalformedEvent, err)
+	}
+	return fromEvent(&requested), nil
+}
+
+func decodeEnvelope(msg *sarama.ConsumerMessage) (event, error) {
+	headers := headerValues(msg.Headers)
+	var envelope proto.EventEnvelope
+	var err error
+	switch headers[HeaderContentType] {
+	case "":
+		// Before the envelope only version 1 existed
+		if version := headers[HeaderSchemaVersion]; version != "" && version != "1" {
+			return event{}, fmt.Errorf("%w: unwrapped version %s", domain.ErrUnknownEventVersion, version)
+		}
+		return event{version: 1, legacy: msg.Value}, nil
+	case contentTypeProtobuf:
+		err = protobuf.Unmarshal(msg.Value, &envelope)
+	case contentTypeJSON:
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(msg.Value, &envelope)
+	default:
+		return event{}, fmt.Errorf("%w: content type %q", domain.ErrMalformedEvent, headers[HeaderContentType])
+	}
+	if err != nil {
+		return event{}, fmt.Errorf("%w: %v", domain.ErrMalformedEvent, err)
+	}
+	if envelope.EventType != NotificationRequested {
+		return event{}, fmt.Errorf("%w: event type %q", domain.ErrUnknownEventVersion, envelope.EventType)
+	}
+	if envelope.Payload == nil {
+		return event{}, fmt.Errorf("%w: no payload", domain.ErrMalformedEvent)
+	}
+	return event{version: envelope.Version, payload: envelope.Payload}, nil
+}
+
+func toEvent(n domain.Notification) (*proto.NotificationRequested, error) {
+	requested := &proto.NotificationRequested{
+		Id:        n.ID,
+		TenantId:  n.TenantId,
+		UserId:    n.UserId,
+		Type:      string(n.Type),
+		Category:  n.Category,
+		Subject:   n.Subject,
+		Body:      n.Body,
+		Recipient: n.Recipient,
+		DeepLink:  n.DeepLink,
+		IconUrl:   n.IconURL,
+		ImageUrl:  n.ImageURL,
+		Priority:  string(n.Priority),
+	}
+	if !n.CreatedAt.IsZero() {
+		requested.CreatedAt = timestamppb.New(n.CreatedAt)
+	}
+	for _, a := range n.Actions {
+		requested.Actions = append(requested.Actions, &proto.NotificationAction{Id: a.ID, Label: a.Label, Url: a.URL})
+	}
+	if len(n.Metadata) > 0 {
+		metadata, err := structpb.NewStruct(n.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		requested.Metadata = metadata
+	}
+	return requested, nil
+}
+
+func fromEvent(e *proto.NotificationRequested) domain.Notification {
+	n := domain.Notification{
+		ID:        e.Id,
+		TenantId:  e.TenantId,
+		UserId:    e.UserId,
+		Type:      domain.NotificationType(e.Type),
+		Category:  e.Category,
+		Subject:   e.Subject,
+		Body:      e.Body,
+		Recipient: e.Recipient,
+		DeepLink:  e.DeepLink,
+		IconURL:   e.IconUrl,
+		ImageURL:  e.ImageUrl,
+		Priority:  domain.NotificationPriority(e.Priority),
+	}
+	if e.CreatedAt != nil {
+		n.CreatedAt = e.CreatedAt.AsTime()
+	}
+	for _, a := range e.Actions {
+		n.Actions = append(n.Actions, domain.NotificationAction{ID: a.Id, Label: a.Label, URL: a.Url})
+	}
+	if e.Metadata != nil {
+		n.Metadata = e.Metadata.AsMap()
+	}
+	return n
+}
@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"github.com/Shopify/sarama"
+	protobuf "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func consumerMessage(value []byte, headers map[string]string) *sarama.ConsumerMessage {
+	msg := &sarama.ConsumerMessage{Topic: testTopic, Value: value}
+	for key, value := range headers {
+		msg.Headers = append(msg.Headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	return msg
+}
+
+func richNotification() domain.Notification {
+	return domain.Notification{
+		ID:        "7f0c1a52-3c1e-4d8e-9a55-0b7a8f0e9d11",
+		TenantId:  "acme",
+		UserId:    "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f",
+		Type:      domain.PushNotification,
+		Category:  "course",
+		Subject:   "New lesson",
+		Body:      "Lesson 4 is out",
+		Recipient: "device-token",
+		DeepLink:  "app://courses/42",
+		IconURL:   "https://example.com/icon.png",
+		ImageURL:  "https://example.com/image.png",
+		Priority:  domain.PriorityHigh,
+		CreatedAt: time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC),
+		Actions:   []domain.NotificationAction{{ID: "open", Label: "Open", URL: "app://courses/42/4"}},
+		Metadata:  map[string]interface{}{"course_id": "42", "lesson": float64(4)},
+	}
+}
+
+func TestEventCodecRoundTrip(t *testing.T) {
+	for _, format := range []EventFormat{FormatProtobuf, FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			codec, err := NewEventCodec(format, "test")
+			if err != nil {
+				t.Fatalf("NewEventCodec: %v", err)
+			}
+			want := richNotification()
+			value, headers, err := codec.EncodeNotification(want)
+			if err != nil {
+				t.Fatalf("EncodeNotification: %v", err)
+			}
+			got, err := codec.DecodeNotification(consumerMessage(value, headers))
+			if err != nil {
+				t.Fatalf("DecodeNotification: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("decoded %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestEventCodecUpcastsLegacy(t *testing.T) {
+	const legacy = `{"ID":"7f0c1a52-3c1e-4d8e-9a55-0b7a8f0e9d11","TenantId":"acme","UserId":"0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f",` +
+		`"Type":"email","Subject":"Welcome","Body":"Hello","Recipient":"ann@example.com","CreatedAt":"2026-10-18T09:30:00Z",` +
+		`"Metadata":{"source":"signup"}}`
+	want := domain.Notification{
+		ID:        "7f0c1a52-3c1e-4d8e-9a55-0b7a8f0e9d11",
+		TenantId:  "acme",
+		UserId:    "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f",
+		Type:      domain.EmailNotification,
+		Subject:   "Welcome",
+		Body:      "Hello",
+		Recipient: "ann@example.com",
+		CreatedAt: time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC),
+		Metadata:  map[string]interface{}{"source": "signup"},
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"no headers", nil},
+		{"version header", map[string]string{HeaderSchemaVersion: "1"}},
+	}
+	codec, _ := NewEventCodec(FormatProtobuf, "test")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := codec.DecodeNotification(consumerMessage([]byte(legacy), tt.headers))
+			if err != nil {
+				t.Fatalf("DecodeNotification: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("decoded %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestEventCodecRejects(t *testing.T) {
+	envelope := func(mutate func(*proto.EventEnvelope)) []byte {
+		payload, err := anypb.New(&proto.NotificationRequested{Id: "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := &proto.EventEnvelope{EventType: NotificationRequested, Version: notificationRequestedVersion, Payload: payload}
+		mutate(e)
+		data, err := protobuf.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	protobufHeaders := map[string]string{HeaderContentType: contentTypeProtobuf}
+
+	tests := []struct {
+		name    string
+		value   []byte
+		headers map[string]string
+		want    error
+	}{
+		{"newer unwrapped version", []byte(`{}`), map[string]string{HeaderSchemaVersion: "3"}, domain.ErrUnknownEventVersion},
+		{"newer envelope version", envelope(func(e *proto.EventEnvelope) { e.Version = 3 }), protobufHeaders, domain.ErrUnknownEventVersion},
+		{"version without upcaster", envelope(func(e *proto.EventEnvelope) { e.Version = 0 }), protobufHeaders, domain.ErrUnknownEventVersion},
+		{"other event type", envelope(func(e *proto.EventEnvelope) { e.EventType = "user.created" }), protobufHeaders, domain.ErrUnknownEventVersion},
+		{"legacy not json", []byte("hello"), nil, domain.ErrMalformedEvent},
+		{"unknown content type", []byte("hello"), map[string]string{HeaderContentType: "text/plain"}, domain.ErrMalformedEvent},
+		{"corrupt protobuf", []byte{0xff, 0xff}, protobufHeaders, domain.ErrMalformedEvent},
+		{"corrupt json", []byte("{"), map[string]string{HeaderContentType: contentTypeJSON}, domain.ErrMalformedEvent},
+		{"no payload", envelope(func(e *proto.EventEnvelope) { e.Payload = nil }), protobufHeaders, domain.ErrMalformedEvent},
+		{"payload of another type", envelope(func(e *proto.EventEnvelope) { e.Payload, _ = anypb.New(&proto.EventEnvelope{}) }), protobufHeaders, domain.ErrMalformedEvent},
+	}
+	codec, _ := NewEventCodec(FormatProtobuf, "test")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := codec.DecodeNotification(consumerMessage(tt.value, tt.headers))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("DecodeNotification() = %+v, %v, want %v", n, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	protobuf "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// eventAttempts is how often an upstream event is handled before it is
+// dead-lettered.
+const eventAttempts = 4
+
+// EventHandler handles upstream domain events. It returns an error only if
+// handling the event again may succeed.
+type EventHandler interface {
+	HandleEvent(ctx context.Context, event domain.Event) error
+}
+
+// EventConsumer consumes the domain events of upstream services, such as
+// course.enrolled, and hands them to an EventHandler.
+type EventConsumer struct {
+	consumerGroup sarama.ConsumerGroup
+	routes        map[string]route
+	handler       EventHandler
+	publisher     Publisher
+	logger        *zap.Logger
+}
+
+// NewEventConsumer creates the event consumer of a group, consuming each
+// of topics and its retry topics. Failed events are republished to the
+// retry topics and dead-lettered through publisher, as notifications are.
+func NewEventConsumer(brokers []string, groupId string, client ClientConfig, group GroupConfig, topics []string, handler EventHandler, publisher Publisher, logger *zap.Logger) (*EventConsumer, error) {
+	config, err := newGroupConfig(client, group)
+	if err != nil {
+		logger.Error("Invalid Kafka event consumer config", zap.Error(err))
+		return nil, err
+	}
+
+	consumerGroup, err := sarama.NewConsumerGroup(brokers, groupId, config)
+	if err != nil {
+		logger.Error("Failed to create Kafka event consumer group", zap.Error(err))
+		return nil, err
+	}
+	configs := make([]TopicConfig, len(topics))
+	for i, topic := range topics {
+		configs[i] = TopicConfig{Name: topic, Workers: 1, MaxAttempts: eventAttempts}
+	}
+	return &EventConsumer{consumerGroup: consumerGroup, routes: newRoutes(configs), handler: handler, publisher: publisher, logger: logger}, nil
+}
+
+// ConsumeEvents consumes until ctx is done.
+func (c *EventConsumer) ConsumeEvents(ctx context.Context) error {
+	topics := subscribed(c.routes)
+	handler := &eventGroupHandler{
+		retrier: retrier{publisher: c.publisher, pauser: c.consumerGroup, logger: c.logger, routes: c.routes},
+		handler: c.handler,
+	}
+	for ctx.Err() == nil {
+		if err := c.consumerGroup.Consume(ctx, topics, handler); err != nil {
+			c.logger.Error("Failed to consume events", zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *EventConsumer) Close() error {
+	if err := c.consumerGroup.Close(); err != nil {
+		c.logger.Error("Failed to close Kafka event consumer group", zap.Error(err))
+		return err
+	}
+	c.logger.Info("Kafka event consumer group closed")
+	return nil
+}
+
+type eventGroupHandler struct {
+	retrier
+	handler EventHandler
+}
+
+func (h *eventGroupHandler) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+func (h *eventGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim handles a partition's events one at a time, holding those
+// of a retry topic until they are due. If the session ends before an event
+// is settled the claim stops without marking it, so it is redelivered.
+func (h *eventGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	r := h.routes[claim.Topic()]
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if r.tier >= 0 && !h.await(session.Context(), msg) {
+				return nil
+			}
+			if !h.handle(session.Context(), msg) {
+				return nil
+			}
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// handle applies an event and reports whether it is settled. Events that
+// cannot be decoded, or fail permanently, such as on an unsupported channel
+// or invalid payload, are dead-lettered at once; other failures go to the
+// next retry tier, until eventAttempts are used up.
+func (h *eventGroupHandler) handle(wait context.Context, msg *sarama.ConsumerMessage) bool {
+	event, err := decodeEvent(msg)
+	if err != nil {
+		h.logger.Error("Failed to decode event",
+			zap.String("topic", msg.Topic),
+			zap.Int64("offset", msg.Offset),
+			zap.Error(err))
+		return h.deadLetter(wait, msg, err)
+	}
+
+	ctx := contextFromHeaders(context.Background(), msg.Headers)
+	if err := h.handler.HandleEvent(ctx, event); err != nil {
+		h.logger.Warn("Failed to handle event",
+			zap.String("event_id", event.ID), zap.Int("attempt", attempt(msg)), zap.Error(err))
+		return h.retry(wait, msg, domain.ClassifyDelivery(err))
+	}
+	return true
+}
+
+// jsonEvent is an upstream event published as plain JSON rather than in
+// an envelope.
+type jsonEvent struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	TenantId   string                 `json:"tenant_id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// decodeEvent reads an upstream event either from an EventEnvelope with a
+// Struct payload or, without a content-type header, from plain JSON whose
+// type may instead come from the event-type header.
+func decodeEvent(msg *sarama.ConsumerMessage) (domain.Event, error) {
+	headers := headerValues(msg.Headers)
+	var envelope proto.EventEnvelope
+	var err error
+	switch headers[HeaderContentType] {
+	case "":
+		var e jsonEvent
+		if err := json.Unmarshal(msg.Value, &e); err != nil {
+			return domain.Event{}, fmt.Errorf("%w: %v", domain.ErrMalformedEvent, err)
+		}
+		if e.Type == "" {
+			e.Type = headers[HeaderEventType]
+		}
+		if e.Type == "" {
+			return domain.Event{}, fmt.Errorf("%w: no event type", domain.ErrMalformedEvent)
+		}
+		return domain.Event(e), nil
+	case contentTypeProtobuf:
+		err = protobuf.Unmarshal(msg.Value, &envelope)
+	case contentTypeJSON:
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(msg.Value, &envelope)
+	default:
+		return domain.Event{}, fmt.Errorf("%w: content type %q", domain.ErrMalformedEvent, headers[HeaderContentType])
+	}
+	if err != nil {
+		return domain.Event{}, fmt.Errorf("%w: %v", domain.ErrMalformedEvent, err)
+	}
+
+	if envelope.EventType == "" {
+		return domain.Event{}, fmt.Errorf("%w: no event type", domain.ErrMalformedEvent)
+	}
+	if envelope.Payload == nil {
+		return domain.Event{}, fmt.Errorf("%w: no payload", domain.ErrMalformedEvent)
+	}
+	var data structpb.Struct
+	if err := envelope.Payload.UnmarshalTo(&data); err != nil {
+		return domain.Event{}, fmt.Errorf("%w: payload is not a struct: %v", domain.ErrMalformedEvent, err)
+	}
+	event := domain.Event{
+		ID:   envelope.EventId,
+		Type: envelope.EventType,
+		Data: data.AsMap(),
+	}
+	if envelope.OccurredAt != nil {
+		event.OccurredAt = envelope.OccurredAt.AsTime()
+	}
+	return event, nil
+}
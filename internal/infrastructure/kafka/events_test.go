@@ -0,0 +1,89 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+const testEventTopic = "course-events"
+
+// failingEventHandler fails every event with err.
+type failingEventHandler struct {
+	err    error
+	events int
+}
+
+func (h *failingEventHandler) HandleEvent(context.Context, domain.Event) error {
+	h.events++
+	return h.err
+}
+
+func TestEventHandleSettles(t *testing.T) {
+	dlq := testEventTopic + dlqSuffix
+	event := []byte(`{"id":"e1","type":"course.enrolled","data":{"user_id":"0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f"}}`)
+	broken := errors.New("database unavailable")
+
+	tests := []struct {
+		name     string
+		value    []byte
+		tier     int // Tier the event is consumed from, -1 for the topic itself
+		headers  map[string]string
+		err      error
+		handled  bool
+		topic    string // Topic the event is republished to, if any
+		attempts string
+	}{
+		{"handled", event, -1, nil, nil, true, "", ""},
+		{"undecodable", []byte("not json"), -1, nil, nil, false, dlq, ""},
+		{"transient failure", event, -1, nil, broken, true, testEventTopic + retryTiers[0].suffix, "2"},
+		{"retried failure", event, 0, map[string]string{HeaderRetryAttempt: "2", HeaderRetryTier: "0"}, broken, true, testEventTopic + retryTiers[1].suffix, "3"},
+		{"unsupported channel", event, -1, nil, fmt.Errorf("%w: push", domain.ErrUnsupportedChannel), true, dlq, ""},
+		{"invalid payload", event, -1, nil, fmt.Errorf("%w: no recipient", domain.ErrInvalidPayload), true, dlq, ""},
+		{"attempts used up", event, 2, map[string]string{HeaderRetryAttempt: "4", HeaderRetryTier: "2"}, broken, true, dlq, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &recordingPublisher{}
+			handler := &failingEventHandler{err: tt.err}
+			h := &eventGroupHandler{
+				retrier: retrier{
+					publisher: publisher,
+					pauser:    &recordingPauser{},
+					logger:    zap.NewNop(),
+					routes:    newRoutes([]TopicConfig{{Name: testEventTopic, Workers: 1, MaxAttempts: eventAttempts}}),
+				},
+				handler: handler,
+			}
+			msg := consumerMessage(tt.value, tt.headers)
+			msg.Topic = testEventTopic
+			if tt.tier >= 0 {
+				msg.Topic += retryTiers[tt.tier].suffix
+			}
+
+			if !h.handle(context.Background(), msg) {
+				t.Fatal("handle() did not settle the event")
+			}
+			if handled := handler.events > 0; handled != tt.handled {
+				t.Errorf("handled = %v, want %v", handled, tt.handled)
+			}
+			if tt.topic == "" {
+				if len(publisher.produced) != 0 {
+					t.Errorf("produced %v, want nothing", publisher.produced)
+				}
+				return
+			}
+			produced := publisher.produced[tt.topic]
+			if len(produced) != 1 || len(publisher.produced) != 1 {
+				t.Fatalf("produced %v, want one message to %s", publisher.produced, tt.topic)
+			}
+			if tt.attempts != "" && produced[0][HeaderRetryAttempt] != tt.attempts {
+				t.Errorf("%s = %q, want %q", HeaderRetryAttempt, produced[0][HeaderRetryAttempt], tt.attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shopify/sarama"
+)
+
+// Message headers. Tenant and trace headers are set from the producing
+// context; the rest are supplied by the caller.
+const (
+	HeaderTenant        = "tenant-id"
+	HeaderCorrelationID = "correlation-id"
+	HeaderSchemaVersion = "schema-version"
+	HeaderTraceParent   = "traceparent"
+	HeaderTraceState    = "tracestate"
+	HeaderContentType   = "content-type"
+	HeaderEventType     = "event-type"
+)
+
+// Headers of a message republished to a retry topic.
+const (
+	HeaderRetryAttempt   = "retry-attempt"    // Delivery attempt it is on, from 2
+	HeaderRetryNotBefore = "retry-not-before" // RFC3339 time it is due
+	HeaderRetryError     = "retry-error"      // Why the last attempt failed
+	HeaderRetryTier      = "retry-tier"       // Index of the tier it was sent to
+)
+
+// Headers added to a message when it is dead-lettered.
+const (
+	HeaderDLQError     = "dlq-error"
+	HeaderDLQTopic     = "dlq-original-topic"
+	HeaderDLQPartition = "dlq-original-partition"
+	HeaderDLQOffset    = "dlq-original-offset"
+)
+
+// newMessage builds a record keyed by key, so sarama's hash partitioner
+// keeps messages with the same key, such as one user's notifications, on
+// one partition and in order.
+func newMessage(ctx context.Context, topic, key string, value []byte, headers map[string]string) *sarama.ProducerMessage {
+	msg := &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(value),
+		Headers: messageHeaders(ctx, headers),
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	return msg
+}
+
+// messageHeaders returns the tenant and trace of ctx and the given headers,
+// which take precedence. Empty values are left out.
+func messageHeaders(ctx context.Context, headers map[string]string) []sarama.RecordHeader {
+	trace := domain.TraceFromContext(ctx)
+	all := map[string]string{
+		HeaderTenant:        domain.TenantFromContext(ctx),
+		HeaderCorrelationID: trace.CorrelationID,
+		HeaderTraceParent:   trace.TraceParent,
+		HeaderTraceState:    trace.TraceState,
+	}
+	for k, v := range headers {
+		all[k] = v
+	}
+
+	keys := make([]string, 0, len(all))
+	for k, v := range all {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	records := make([]sarama.RecordHeader, len(keys))
+	for i, k := range keys {
+		records[i] = sarama.RecordHeader{Key: []byte(k), Value: []byte(all[k])}
+	}
+	return records
+}
+
+// headerValues returns a consumed message's headers by key.
+func headerValues(headers []*sarama.RecordHeader) map[string]string {
+	values := make(map[string]string, len(headers))
+	for _, h := range headers {
+		values[string(h.Key)] = string(h.Value)
+	}
+	return values
+}
+
+// contextFromHeaders restores the tenant and trace a message was produced
+// with.
+func contextFromHeaders(ctx context.Context, headers []*sarama.RecordHeader) context.Context {
+	values := headerValues(headers)
+	if tenantId := values[HeaderTenant]; tenantId != "" {
+		ctx = domain.ContextWithTenant(ctx, tenantId)
+	}
+	return domain.ContextWithTrace(ctx, domain.Trace{
+		CorrelationID: values[HeaderCorrelationID],
+		TraceParent:   values[HeaderTraceParent],
+		TraceState:    values[HeaderTraceState],
+	})
+}
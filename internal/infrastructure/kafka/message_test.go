@@ -0,0 +1,137 @@
+package kafka
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shopify/sarama"
+)
+
+var testTrace = domain.Trace{
+	CorrelationID: "c0ffee",
+	TraceParent:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	TraceState:    "vendor=1",
+}
+
+func TestNewMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want sarama.Encoder
+	}{
+		{"keyed", "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f", sarama.StringEncoder("0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f")},
+		// Unkeyed messages are spread by sarama's partitioner
+		{"no key", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := newMessage(context.Background(), testTopic, tt.key, []byte("value"), nil)
+			if msg.Topic != testTopic {
+				t.Errorf("topic = %q, want %q", msg.Topic, testTopic)
+			}
+			if !reflect.DeepEqual(msg.Key, tt.want) {
+				t.Errorf("key = %#v, want %#v", msg.Key, tt.want)
+			}
+			if value, _ := msg.Value.Encode(); string(value) != "value" {
+				t.Errorf("value = %q, want %q", value, "value")
+			}
+		})
+	}
+}
+
+func TestMessageHeaders(t *testing.T) {
+	traced := domain.ContextWithTrace(domain.ContextWithTenant(context.Background(), "acme"), testTrace)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		headers map[string]string
+		want    map[string]string
+	}{
+		{
+			name: "context tenant and trace",
+			ctx:  traced,
+			want: map[string]string{
+				HeaderTenant:        "acme",
+				HeaderCorrelationID: testTrace.CorrelationID,
+				HeaderTraceParent:   testTrace.TraceParent,
+				HeaderTraceState:    testTrace.TraceState,
+			},
+		},
+		{
+			name: "default tenant without a trace",
+			ctx:  context.Background(),
+			want: map[string]string{HeaderTenant: domain.DefaultTenant},
+		},
+		{
+			name:    "given headers take precedence",
+			ctx:     traced,
+			headers: map[string]string{HeaderTenant: "globex", HeaderCorrelationID: "replayed", HeaderSchemaVersion: "2"},
+			want: map[string]string{
+				HeaderTenant:        "globex",
+				HeaderCorrelationID: "replayed",
+				HeaderTraceParent:   testTrace.TraceParent,
+				HeaderTraceState:    testTrace.TraceState,
+				HeaderSchemaVersion: "2",
+			},
+		},
+		{
+			name:    "empty values are left out",
+			ctx:     domain.ContextWithTrace(context.Background(), domain.Trace{CorrelationID: "c0ffee"}),
+			headers: map[string]string{HeaderEventType: "", HeaderContentType: "application/json"},
+			want: map[string]string{
+				HeaderTenant:        domain.DefaultTenant,
+				HeaderCorrelationID: "c0ffee",
+				HeaderContentType:   "application/json",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := messageHeaders(tt.ctx, tt.headers)
+			got := make(map[string]string, len(records))
+			for i, record := range records {
+				if i > 0 && string(records[i-1].Key) >= string(record.Key) {
+					t.Errorf("header %q after %q, want sorted unique keys", record.Key, records[i-1].Key)
+				}
+				got[string(record.Key)] = string(record.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("headers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextFromHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		tenant string
+		trace  domain.Trace
+	}{
+		{"tenant and trace", domain.ContextWithTrace(domain.ContextWithTenant(context.Background(), "acme"), testTrace), "acme", testTrace},
+		{"default tenant", domain.ContextWithTrace(context.Background(), domain.Trace{CorrelationID: "c0ffee"}), domain.DefaultTenant, domain.Trace{CorrelationID: "c0ffee"}},
+		{"nothing", context.Background(), domain.DefaultTenant, domain.Trace{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// As a consumer receives the headers of a produced message
+			produced := newMessage(tt.ctx, testTopic, "", nil, nil)
+			consumed := make([]*sarama.RecordHeader, len(produced.Headers))
+			for i := range produced.Headers {
+				consumed[i] = &produced.Headers[i]
+			}
+
+			ctx := contextFromHeaders(context.Background(), consumed)
+			if tenant := domain.TenantFromContext(ctx); tenant != tt.tenant {
+				t.Errorf("tenant = %q, want %q", tenant, tt.tenant)
+			}
+			if trace := domain.TraceFromContext(ctx); trace != tt.trace {
+				t.Errorf("trace = %+v, want %+v", trace, tt.trace)
+			}
+		})
+	}
+}
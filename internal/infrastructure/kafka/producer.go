@@ -13,8 +13,12 @@ type Producer struct {
 }
 
 // NewProducer initializes a new Kafka producer.
-func NewProducer(brokers []string, logger *zap.Logger) (*Producer, error) {
-	config := sarama.NewConfig()
+func NewProducer(brokers []string, client ClientConfig, logger *zap.Logger) (*Producer, error) {
+	config, err := newConfig(client)
+	if err != nil {
+		logger.Error("Invalid Kafka producer config", zap.Error(err))
+		return nil, err
+	}
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Retry.Max = 5
 	config.Producer.Return.Successes = true
@@ -54,11 +58,11 @@ func NewProducer(brokers []string, logger *zap.Logger) (*Producer, error) {
 // 	return nil
 // }
 
-func (p *Producer) Produce(ctx context.Context, topic string, message []byte) error {
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(message),
-	}
+// Produce sends a message and waits for the brokers to acknowledge it.
+// Messages with the same key go to the same partition; headers are added
+// to those taken from ctx (see messageHeaders).
+func (p *Producer) Produce(ctx context.Context, topic, key string, message []byte, headers map[string]string) error {
+	msg := newMessage(ctx, topic, key, message, headers)
 	select {
 
 	case <-ctx.Done():
@@ -81,6 +85,12 @@ func (p *Producer) Produce(ctx context.Context, topic string, message []byte) er
 
 }
 
+// ProduceAsync sends a message like Produce and reports the result to
+// done before returning.
+func (p *Producer) ProduceAsync(ctx context.Context, topic, key string, message []byte, headers map[string]string, done func(error)) {
+	done(p.Produce(ctx, topic, key, message, headers))
+}
+
 // Close closes the Kafka producer.
 func (p *Producer) Close() error {
 	if err := p.producer.Close(); err != nil {
@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// scramClient authenticates with SCRAM (RFC 5802), which sarama supports
+// but leaves to the application to implement.
+type scramClient struct {
+	hash func() hash.Hash
+
+	username        string
+	password        string
+	nonce           string
+	clientFirstBare string
+	serverSignature []byte
+	step            int
+}
+
+func (c *scramClient) Begin(username, password, authzID string) error {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	// '=' and ',' are escaped in SCRAM names
+	c.username = strings.NewReplacer("=", "=3D", ",", "=2C").Replace(username)
+	c.password = password
+	c.nonce = base64.RawStdEncoding.EncodeToString(nonce)
+	c.step = 0
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	c.step++
+	switch c.step {
+	case 1:
+		c.clientFirstBare = "n=" + c.username + ",r=" + c.nonce
+		return "n,," + c.clientFirstBare, nil
+	case 2:
+		return c.clientFinal(challenge)
+	case 3:
+		return "", c.verifyServer(challenge)
+	}
+	return "", errors.New("SCRAM exchange already finished")
+}
+
+func (c *scramClient) Done() bool {
+	return c.step >= 3
+}
+
+// clientFinal answers the server-first message with the client proof.
+func (c *scramClient) clientFinal(serverFirst string) (string, error) {
+	attrs := scramAttributes(serverFirst)
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return "", errors.New("SCRAM server nonce does not extend the client nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return "", fmt.Errorf("SCRAM salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return "", errors.New("SCRAM iteration count is invalid")
+	}
+
+	salted, err := pbkdf2.Key(c.hash, c.password, salt, iterations, c.hash().Size())
+	if err != nil {
+		return "", err
+	}
+	clientKey := c.hmac(salted, "Client Key")
+	storedKey := c.hash()
+	storedKey.Write(clientKey)
+
+	// "biws" is the base64 GS2 header "n,,": no channel binding
+	withoutProof := "c=biws,r=" + nonce
+	authMessage := c.clientFirstBare + "," + serverFirst + "," + withoutProof
+	proof := c.hmac(storedKey.Sum(nil), authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	c.serverSignature = c.hmac(c.hmac(salted, "Server Key"), authMessage)
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verifyServer checks the server-final message proves the server knows
+// the password too.
+func (c *scramClient) verifyServer(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+	if e, ok := attrs["e"]; ok {
+		return fmt.Errorf("SCRAM authentication failed: %s", e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(signature, c.serverSignature) {
+		return errors.New("SCRAM server signature is invalid")
+	}
+	return nil
+}
+
+func (c *scramClient) hmac(key []byte, message string) []byte {
+	mac := hmac.New(c.hash, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+func scramAttributes(message string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(message, ",") {
+		if k, v, ok := strings.Cut(attr, "="); ok {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"strings"
+	"testing"
+)
+
+// scramExchange is a full SCRAM conversation with a fixed client nonce.
+type scramExchange struct {
+	name        string
+	hash        func() hash.Hash
+	username    string
+	password    string
+	nonce       string
+	clientFirst string
+	serverFirst string
+	clientFinal string
+	serverFinal string
+}
+
+// The example exchanges of RFC 5802 section 5 and RFC 7677 section 3.
+var scramVectors = []scramExchange{
+	{
+		name:        "RFC 5802 SHA-1",
+		hash:        sha1.New,
+		username:    "user",
+		password:    "pencil",
+		nonce:       "fyko+d2lbbFgONRv9qkxdawL",
+		clientFirst: "n,,n=user,r=fyko+d2lbbFgONRv9qkxdawL",
+		serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+		clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+		serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+	},
+	{
+		name:        "RFC 7677 SHA-256",
+		hash:        sha256.New,
+		username:    "user",
+		password:    "pencil",
+		nonce:       "rOprNGfwEbeRWgbNEkqO",
+		clientFirst: "n,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+		serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+		clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+	},
+}
+
+// begin starts c as for x, with x's nonce in place of a random one.
+func (x scramExchange) begin(t *testing.T) *scramClient {
+	t.Helper()
+	c := &scramClient{hash: x.hash}
+	if err := c.Begin(x.username, x.password, ""); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	c.nonce = x.nonce
+	return c
+}
+
+func TestScramClientVectors(t *testing.T) {
+	for _, x := range scramVectors {
+		t.Run(x.name, func(t *testing.T) {
+			c := x.begin(t)
+			steps := []struct{ challenge, want string }{
+				{"", x.clientFirst},
+				{x.serverFirst, x.clientFinal},
+				{x.serverFinal, ""},
+			}
+			for i, step := range steps {
+				if c.Done() {
+					t.Fatalf("step %d: done too early", i+1)
+				}
+				got, err := c.Step(step.challenge)
+				if err != nil {
+					t.Fatalf("step %d: %v", i+1, err)
+				}
+				if got != step.want {
+					t.Fatalf("step %d = %q, want %q", i+1, got, step.want)
+				}
+			}
+			if !c.Done() {
+				t.Error("not done after the server-final message")
+			}
+			if _, err := c.Step(""); err == nil {
+				t.Error("step after the exchange succeeded")
+			}
+		})
+	}
+}
+
+func TestScramClientRejectsServer(t *testing.T) {
+	x := scramVectors[1]
+	tests := []struct {
+		name        string
+		serverFirst string
+		serverFinal string
+		error       string
+	}{
+		{
+			name:        "nonce not extended",
+			serverFirst: "r=rOprNGfwEbeRWgbNEkqO,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			error:       "nonce",
+		},
+		{
+			name:        "other nonce",
+			serverFirst: "r=attackerNonce%hvYDpWUa2RaTCAfuxFIlj,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			error:       "nonce",
+		},
+		{
+			name:        "bad salt",
+			serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=***,i=4096",
+			error:       "salt",
+		},
+		{
+			name:        "bad iterations",
+			serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=0",
+			error:       "iteration",
+		},
+		{
+			name:        "wrong server signature",
+			serverFirst: x.serverFirst,
+			serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+			error:       "signature",
+		},
+		{
+			name:        "server error",
+			serverFirst: x.serverFirst,
+			serverFinal: "e=invalid-proof",
+			error:       "invalid-proof",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := x.begin(t)
+			if _, err := c.Step(""); err != nil {
+				t.Fatal(err)
+			}
+			_, err := c.Step(tt.serverFirst)
+			if err == nil && tt.serverFinal != "" {
+				_, err = c.Step(tt.serverFinal)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("exchange error = %v, want one mentioning %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestScramClientEscapesUsername(t *testing.T) {
+	c := &scramClient{hash: sha256.New}
+	if err := c.Begin("a=b,c", "pencil", ""); err != nil {
+		t.Fatal(err)
+	}
+	first, err := c.Step("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(first, "n,,n=a=3Db=2Cc,r=") {
+		t.Errorf("client-first = %q, want the name escaped", first)
+	}
+	if c.nonce == "" || !strings.HasSuffix(first, c.nonce) {
+		t.Errorf("client-first = %q does not end with the nonce %q", first, c.nonce)
+	}
+}
@@ -3,30 +3,32 @@ package email
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"net/smtp"
+	"net/textproto"
 	"sync"
 
 	"github.com/Shafeeqth/notification-service/internal/domain"
-	"github.com/Shafeeqth/notification-service/internal/infrastructure/ratelimit"
 	"github.com/jordan-wright/email"
 	"go.uber.org/zap"
 )
 
 type EmailSender struct {
-	smtpHost    string
-	smtpPort    string
-	username    string
-	password    string
-	logger      *zap.Logger
-	pool        *smtpPool
-	ratelimiter *ratelimit.RateLimiter
-	emailPool   sync.Pool
+	smtpHost  string
+	smtpPort  string
+	username  string
+	password  string
+	from      string
+	logger    *zap.Logger
+	pool      *smtpPool
+	emailPool *sync.Pool
 }
 
 type smtpPool struct {
 	conns chan *smtp.Client
 	mutex sync.Mutex
 	host  string
+	addr  string
 	auth  smtp.Auth
 }
 
@@ -34,11 +36,11 @@ func newSMTPPool(host, port, username, password string, size int) (*smtpPool, er
 	pool := &smtpPool{
 		conns: make(chan *smtp.Client, size),
 		host:  host,
+		addr:  host + ":" + port,
 		auth:  smtp.PlainAuth("", username, password, host),
 	}
-	addr := host + ":" + port
 	for i := 0; i < size; i++ {
-		client, err := smtp.Dial(addr)
+		client, err := smtp.Dial(pool.addr)
 		if err != nil {
 			return nil, err
 		}
@@ -63,7 +65,7 @@ func (p *smtpPool) Get() (*smtp.Client, error) {
 	case client := <-p.conns:
 		return client, nil
 	default:
-		client, err := smtp.Dial(p.host + ":587")
+		client, err := smtp.Dial(p.addr)
 		if err != nil {
 			return nil, err
 		}
@@ -100,14 +102,14 @@ func NewEmailSender(smtpHost, smtpPort, username, password string, logger *zap.L
 	logger.Info("Connected to SMTP pool", zap.String("smtphost", smtpHost))
 
 	return &EmailSender{
-		smtpHost:    smtpHost,
-		smtpPort:    smtpPort,
-		username:    username,
-		password:    password,
-		logger:      logger,
-		pool:        pool,
-		ratelimiter: ratelimit.NewRateLimiter(10, 20), // Helpful to not tag emails as spam (10 emails/sec, burst of 20)
-		emailPool: sync.Pool{
+		smtpHost: smtpHost,
+		smtpPort: smtpPort,
+		username: username,
+		password: password,
+		from:     username,
+		logger:   logger,
+		pool:     pool,
+		emailPool: &sync.Pool{
 			New: func() interface{} {
 				return email.NewEmail()
 			},
@@ -115,13 +117,17 @@ func NewEmailSender(smtpHost, smtpPort, username, password string, logger *zap.L
 	}, nil
 }
 
+// WithFrom returns a sender that shares this sender's SMTP connections but
+// sets a different From address, for tenants without their own account.
+// The envelope sender stays the authenticated username.
+func (e *EmailSender) WithFrom(from string) *EmailSender {
+	sender := *e
+	sender.from = from
+	return &sender
+}
+
 func (e *EmailSender) Send(ctx context.Context, notification domain.Notification) error {
-	// Check rate limit
-	if err := e.ratelimiter.Allow(ctx, notification.Recipient); err != nil {
-		e.logger.Warn("Rate limit exceeded for email sending",
-			zap.String("recipient", notification.Recipient))
-		return err
-	}
+	// Rate limits are applied by the notification sender before dispatch
 	e.logger.Info("Inside Send method (:)")
 
 	// Get email message from pool
@@ -132,17 +138,26 @@ func (e *EmailSender) Send(ctx context.Context, notification domain.Notification
 		msg.Subject = ""
 		msg.Text = nil
 		msg.HTML = nil
+		msg.Headers = textproto.MIMEHeader{}
 		e.emailPool.Put(msg)
 	}()
-	msg.From = e.username
+	msg.From = e.from
 	msg.To = []string{notification.Recipient}
 	msg.Subject = notification.Subject
 	msg.HTML = []byte(notification.Body) // Use HTML field for HTML content
+	switch notification.Priority {
+	case domain.PriorityHigh:
+		msg.Headers.Set("X-Priority", "1")
+		msg.Headers.Set("Importance", "high")
+	case domain.PriorityLow:
+		msg.Headers.Set("X-Priority", "5")
+		msg.Headers.Set("Importance", "low")
+	}
 
 	client, err := e.pool.Get()
 	if err != nil {
 		e.logger.Error("Failed to get SMTP client from pool", zap.Error(err))
-		return err
+		return classify(err, false)
 	}
 	/*
 		defer e.pool.Put(client)
@@ -163,12 +178,12 @@ func (e *EmailSender) Send(ctx context.Context, notification domain.Notification
 	// Start the SMTP mail transaction
 	if err := client.Mail(e.username); err != nil {
 		e.logger.Error("Failed to start mail transaction", zap.Error(err))
-		return err
+		return classify(err, false)
 	}
 	for _, addr := range msg.To {
 		if err := client.Rcpt(addr); err != nil {
 			e.logger.Error("Failed to add recipient", zap.String("recipient", addr), zap.Error(err))
-			return err
+			return classify(err, true)
 		}
 	}
 
@@ -176,22 +191,42 @@ func (e *EmailSender) Send(ctx context.Context, notification domain.Notification
 	writer, err := client.Data()
 	if err != nil {
 		e.logger.Error("Failed to get SMTP data writer", zap.Error(err))
-		return err
+		return classify(err, false)
 	}
 	data, err := msg.Bytes()
 	if err != nil {
 		e.logger.Error("Failed to generate email bytes", zap.Error(err))
-		return err
+		return domain.PermanentError(err)
 	}
 	if _, err := writer.Write(data); err != nil {
 		e.logger.Error("Failed to write email data", zap.Error(err))
-		return err
+		return classify(err, false)
 	}
 	if err := writer.Close(); err != nil {
 		e.logger.Error("Failed to close SMTP data writer", zap.Error(err))
-		return err
+		return classify(err, false)
 	}
 
 	e.logger.Info("Email sent successfully", zap.String("recipient", notification.Recipient))
 	return nil
 }
+
+// classify classifies an SMTP failure by its reply code: 4xx replies are
+// transient and 5xx permanent, except that a recipient rejected as
+// unavailable, not local or invalid (550, 551 or 553 to RCPT) is
+// suppressed. Failures without a reply, such as a broken connection, are
+// transient.
+func classify(err error, recipient bool) error {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		return domain.TransientError(err)
+	}
+	switch {
+	case recipient && (reply.Code == 550 || reply.Code == 551 || reply.Code == 553):
+		return domain.SuppressedError(err)
+	case reply.Code >= 500:
+		return domain.PermanentError(err)
+	default:
+		return domain.TransientError(err)
+	}
+}
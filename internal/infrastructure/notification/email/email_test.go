@@ -0,0 +1,42 @@
+package email
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"testing"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+)
+
+func TestClassify(t *testing.T) {
+	reply := func(code int) error { return &textproto.Error{Code: code, Msg: "reply"} }
+
+	tests := []struct {
+		name      string
+		err       error
+		recipient bool
+		class     domain.DeliveryClass
+	}{
+		{"broken connection", io.ErrUnexpectedEOF, false, domain.DeliveryTransient},
+		{"mailbox busy", reply(450), true, domain.DeliveryTransient},
+		{"server busy", reply(421), false, domain.DeliveryTransient},
+		{"mailbox unavailable", reply(550), true, domain.DeliverySuppressed},
+		{"user not local", reply(551), true, domain.DeliverySuppressed},
+		{"mailbox name invalid", reply(553), true, domain.DeliverySuppressed},
+		{"mailbox full", reply(552), true, domain.DeliveryPermanent},
+		{"550 to the message", reply(550), false, domain.DeliveryPermanent},
+		{"syntax error", reply(501), false, domain.DeliveryPermanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classify(tt.err, tt.recipient)
+			if got := domain.ClassifyDelivery(err).Class; got != tt.class {
+				t.Errorf("classify() = %v, want %v", got, tt.class)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("classify() = %v, lost the cause", err)
+			}
+		})
+	}
+}
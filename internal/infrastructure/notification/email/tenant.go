@@ -0,0 +1,27 @@
+package email
+
+import (
+	"context"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+)
+
+// TenantSender routes each email through the sender configured for the
+// notification's tenant.
+type TenantSender struct {
+	senders map[string]*EmailSender
+}
+
+// NewTenantSender creates the router. senders is keyed by tenant id and
+// must contain domain.DefaultTenant, which also serves unknown tenants.
+func NewTenantSender(senders map[string]*EmailSender) *TenantSender {
+	return &TenantSender{senders: senders}
+}
+
+func (t *TenantSender) Send(ctx context.Context, notification domain.Notification) error {
+	sender, ok := t.senders[notification.TenantId]
+	if !ok {
+		sender = t.senders[domain.DefaultTenant]
+	}
+	return sender.Send(ctx, notification)
+}
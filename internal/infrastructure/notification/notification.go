@@ -5,29 +5,46 @@ import (
 	"fmt"
 
 	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/ratelimit"
 )
 
 // implement strategy pattern to abstract notification channels
 // in order to avoid adding and removing of different messaging
 // strategies
 
+// SenderStrategy delivers notifications of one channel. Failures should be
+// classified with domain.DeliveryError, so they are retried, dead-lettered
+// or suppressed as they need; unclassified failures are retried.
 type SenderStrategy interface {
 	Send(ctx context.Context, notification domain.Notification) error
 }
 
 type NotificationSender struct {
 	strategies map[domain.NotificationType]SenderStrategy
+	limits     *ratelimit.Policy
 }
 
-func NewNotificationSender(strategies map[domain.NotificationType]SenderStrategy) *NotificationSender {
-	return &NotificationSender{strategies: strategies}
+// NewNotificationSender creates a sender that dispatches to strategies by
+// notification type. limits may be nil to disable rate limiting.
+func NewNotificationSender(strategies map[domain.NotificationType]SenderStrategy, limits *ratelimit.Policy) *NotificationSender {
+	return &NotificationSender{strategies: strategies, limits: limits}
 }
 
 func (s *NotificationSender) Send(ctx context.Context, notification domain.Notification) error {
 	strategy, exists := s.strategies[notification.Type]
 
 	if !exists {
-		return fmt.Errorf("no strategy found for notification type: %s", notification.Type)
+		return domain.PermanentError(fmt.Errorf("%w: %s", domain.ErrUnsupportedChannel, notification.Type))
+	}
+	if s.limits != nil {
+		scope := ratelimit.Scope{
+			Channel:   string(notification.Type),
+			Recipient: notification.Recipient,
+			Tenant:    notification.TenantId,
+		}
+		if err := s.limits.Allow(ctx, scope); err != nil {
+			return domain.ClassifyDelivery(err)
+		}
 	}
 	return strategy.Send(ctx, notification)
 }
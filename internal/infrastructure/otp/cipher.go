@@ -0,0 +1,99 @@
+package otp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+)
+
+const aesGCM = "aes-gcm"
+
+// Cipher encrypts secrets with AES-256-GCM under a server-side key. Every
+// ciphertext records the id of the key that produced it, so after a key
+// rotation secrets encrypted under the previous key still decrypt.
+//
+// Encoded format:
+//
+//	aes-gcm$<key id>$<base64 nonce and sealed secret>
+type Cipher struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewCipher creates a Cipher encrypting with currentKey. If previousKey
+// is set, secrets encrypted with it still decrypt. Keys must be at least
+// 32 bytes; the AES key is derived from them.
+func NewCipher(currentKey, previousKey string) (*Cipher, error) {
+	c := &Cipher{keys: make(map[string]cipher.AEAD)}
+	id, err := c.add(currentKey)
+	if err != nil {
+		return nil, err
+	}
+	c.current = id
+	if previousKey != "" {
+		if _, err := c.add(previousKey); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Cipher) add(secret string) (string, error) {
+	if len(secret) < 32 {
+		return "", errors.New("secret encryption key must be at least 32 bytes")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	// The id is a hash of the derived key, so it reveals nothing of it
+	sum := sha256.Sum256(key[:])
+	id := hex.EncodeToString(sum[:4])
+	c.keys[id] = aead
+	return id, nil
+}
+
+func (c *Cipher) Encrypt(subject, plaintext string) (string, error) {
+	aead := c.keys[c.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(subject))
+	return strings.Join([]string{aesGCM, c.current, base64.RawStdEncoding.EncodeToString(sealed)}, "$"), nil
+}
+
+func (c *Cipher) Decrypt(subject, encoded string) (string, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 3 || parts[0] != aesGCM {
+		return "", domain.ErrSecretFormat
+	}
+	aead, ok := c.keys[parts[1]]
+	if !ok {
+		return "", fmt.Errorf("secret encryption key %s is not configured", parts[1])
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", domain.ErrSecretFormat
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	// Fails if the ciphertext was altered or belongs to another subject
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(subject))
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
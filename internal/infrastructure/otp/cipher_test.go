@@ -0,0 +1,106 @@
+package otp
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+)
+
+const (
+	testKey         = "current-secret-encryption-key-0123456789"
+	testPreviousKey = "previous-secret-encryption-key-012345678"
+	testUserId      = "0f8e2d4c-7a5b-4c3d-8e9f-1a2b3c4d5e6f"
+	testSecret      = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher(testKey, "")
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	encoded, err := c.Encrypt(testUserId, testSecret)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if strings.Contains(encoded, testSecret) || !strings.HasPrefix(encoded, "aes-gcm$") {
+		t.Errorf("Encrypt() = %q, want an aes-gcm ciphertext", encoded)
+	}
+	again, _ := c.Encrypt(testUserId, testSecret)
+	if again == encoded {
+		t.Error("two encryptions are equal, want a fresh nonce each time")
+	}
+	got, err := c.Decrypt(testUserId, encoded)
+	if err != nil || got != testSecret {
+		t.Errorf("Decrypt() = %q, %v, want %q", got, err, testSecret)
+	}
+}
+
+func TestCipherRotation(t *testing.T) {
+	old, _ := NewCipher(testPreviousKey, "")
+	encoded, err := old.Encrypt(testUserId, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, _ := NewCipher(testKey, testPreviousKey)
+	if got, err := rotated.Decrypt(testUserId, encoded); err != nil || got != testSecret {
+		t.Errorf("Decrypt() under the previous key = %q, %v, want %q", got, err, testSecret)
+	}
+	if again, _ := rotated.Encrypt(testUserId, testSecret); strings.Split(again, "$")[1] == strings.Split(encoded, "$")[1] {
+		t.Error("new secrets are encrypted under the previous key")
+	}
+
+	retired, _ := NewCipher(testKey, "")
+	if _, err := retired.Decrypt(testUserId, encoded); err == nil {
+		t.Error("Decrypt() succeeded after the previous key was removed")
+	}
+}
+
+func TestCipherRejects(t *testing.T) {
+	c, _ := NewCipher(testKey, "")
+	encoded, err := c.Encrypt(testUserId, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(encoded, "$")
+	sealed, _ := base64.RawStdEncoding.DecodeString(parts[2])
+	sealed[len(sealed)/2] ^= 1
+	tampered := base64.RawStdEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name    string
+		subject string
+		encoded string
+		format  bool
+	}{
+		{"another user", "9b2c7a10-5d4e-4f3a-8b1c-2d3e4f5a6b7c", encoded, false},
+		{"altered ciphertext", testUserId, strings.Join([]string{parts[0], parts[1], tampered}, "$"), false},
+		{"plaintext", testUserId, testSecret, true},
+		{"other algorithm", testUserId, "aes-cbc$" + parts[1] + "$" + parts[2], true},
+		{"not base64", testUserId, parts[0] + "$" + parts[1] + "$***", true},
+		{"shorter than a nonce", testUserId, parts[0] + "$" + parts[1] + "$AAAA", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Decrypt(tt.subject, tt.encoded)
+			if err == nil {
+				t.Fatalf("Decrypt() = %q, want an error", got)
+			}
+			if format := errors.Is(err, domain.ErrSecretFormat); format != tt.format {
+				t.Errorf("Decrypt() = %v, format error %v, want %v", err, format, tt.format)
+			}
+		})
+	}
+}
+
+func TestNewCipherShortKey(t *testing.T) {
+	if _, err := NewCipher("too-short", ""); err == nil {
+		t.Error("NewCipher accepted a short key")
+	}
+	if _, err := NewCipher(testKey, "too-short"); err == nil {
+		t.Error("NewCipher accepted a short previous key")
+	}
+}
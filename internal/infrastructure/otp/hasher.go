@@ -0,0 +1,176 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"golang.org/x/crypto/argon2"
+)
+
+type HashAlgorithm string
+
+const (
+	HMACSHA256 HashAlgorithm = "hmac-sha256"
+	Argon2id   HashAlgorithm = "argon2id"
+)
+
+// argon2id parameters; OTPs are short lived so these favour latency over
+// the much heavier settings used for passwords.
+const (
+	argonTime    = 1
+	argonMemory  = 19 * 1024
+	argonThreads = 1
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+// Hasher hashes OTP codes with a server-side pepper. Every encoded hash
+// records the id of the pepper that produced it, so during a key rotation
+// codes issued under the previous pepper keep verifying until the
+// transition window closes.
+//
+// Encoded formats:
+//
+//	hmac-sha256$<pepper id>$<hex mac>
+//	argon2id$<pepper id>$<base64 salt>$<base64 key>
+type Hasher struct {
+	algorithm     HashAlgorithm
+	current       pepper
+	previous      *pepper
+	previousUntil time.Time
+	now           func() time.Time
+}
+
+type pepper struct {
+	id  string
+	key []byte
+}
+
+func newPepper(secret string) pepper {
+	sum := sha256.Sum256([]byte(secret))
+	return pepper{id: hex.EncodeToString(sum[:4]), key: []byte(secret)}
+}
+
+// NewHasher creates a Hasher using currentPepper for new hashes. If
+// previousPepper is set, hashes created with it are still accepted until
+// previousUntil; a zero previousUntil accepts them for as long as the
+// previous pepper stays configured.
+func NewHasher(algorithm HashAlgorithm, currentPepper, previousPepper string, previousUntil time.Time) (*Hasher, error) {
+	if currentPepper == "" {
+		return nil, errors.New("otp pepper must not be empty")
+	}
+	switch algorithm {
+	case "":
+		algorithm = HMACSHA256
+	case HMACSHA256, Argon2id:
+	default:
+		return nil, fmt.Errorf("unsupported otp hash algorithm: %s", algorithm)
+	}
+
+	h := &Hasher{
+		algorithm:     algorithm,
+		current:       newPepper(currentPepper),
+		previousUntil: previousUntil,
+		now:           time.Now,
+	}
+	if previousPepper != "" {
+		p := newPepper(previousPepper)
+		h.previous = &p
+	}
+	return h, nil
+}
+
+func (h *Hasher) Hash(subject, code string) (string, error) {
+	switch h.algorithm {
+	case Argon2id:
+		salt := make([]byte, argonSaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := h.argon2(h.current, subject, code, salt)
+		return strings.Join([]string{
+			string(Argon2id),
+			h.current.id,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		}, "$"), nil
+	default:
+		mac := h.mac(h.current, subject, code)
+		return strings.Join([]string{string(HMACSHA256), h.current.id, hex.EncodeToString(mac)}, "$"), nil
+	}
+}
+
+func (h *Hasher) Verify(subject, code, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 3 {
+		return false, domain.ErrOTPHashFormat
+	}
+
+	p, ok := h.pepperFor(parts[1])
+	if !ok {
+		// Issued under a pepper that is no longer accepted.
+		return false, nil
+	}
+
+	switch HashAlgorithm(parts[0]) {
+	case HMACSHA256:
+		if len(parts) != 3 {
+			return false, domain.ErrOTPHashFormat
+		}
+		want, err := hex.DecodeString(parts[2])
+		if err != nil {
+			return false, domain.ErrOTPHashFormat
+		}
+		return hmac.Equal(h.mac(p, subject, code), want), nil
+	case Argon2id:
+		if len(parts) != 4 {
+			return false, domain.ErrOTPHashFormat
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+		if err != nil {
+			return false, domain.ErrOTPHashFormat
+		}
+		want, err := base64.RawStdEncoding.DecodeString(parts[3])
+		if err != nil {
+			return false, domain.ErrOTPHashFormat
+		}
+		return subtle.ConstantTimeCompare(h.argon2(p, subject, code, salt), want) == 1, nil
+	default:
+		return false, domain.ErrOTPHashFormat
+	}
+}
+
+func (h *Hasher) pepperFor(id string) (pepper, bool) {
+	if id == h.current.id {
+		return h.current, true
+	}
+	if h.previous != nil && id == h.previous.id {
+		if h.previousUntil.IsZero() || h.now().Before(h.previousUntil) {
+			return *h.previous, true
+		}
+	}
+	return pepper{}, false
+}
+
+func (h *Hasher) mac(p pepper, subject, code string) []byte {
+	m := hmac.New(sha256.New, p.key)
+	m.Write([]byte(subject))
+	m.Write([]byte{0})
+	m.Write([]byte(code))
+	return m.Sum(nil)
+}
+
+// argon2 has no secret input in x/crypto, so the pepper is mixed in by
+// hashing the HMAC of the code rather than the code itself.
+func (h *Hasher) argon2(p pepper, subject, code string, salt []byte) []byte {
+	return argon2.IDKey(h.mac(p, subject, code), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+}
@@ -0,0 +1,139 @@
+package otp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+)
+
+const (
+	previousPepper = "previous-pepper-0123456789abcdef"
+	currentPepper = "current-pepper-0123456789abcdef"
+)
+
+func TestHasherRoundTrip(t *testing.T) {
+	for _, algorithm := range []HashAlgorithm{HMACSHA256, Argon2id} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			h, err := NewHasher(algorithm, currentPepper, "", time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := h.Hash("ann@example.com", "123456")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if !strings.HasPrefix(encoded, string(algorithm)+"$") || strings.Contains(encoded, "123456") {
+				t.Errorf("Hash() = %q", encoded)
+			}
+
+			tests := []struct {
+				subject, code string
+				want          bool
+			}{
+				{"ann@example.com", "123456", true},
+				{"ann@example.com", "123457", false},
+				{"bob@example.com", "123456", false},
+			}
+			for _, tt := range tests {
+				if ok, err := h.Verify(tt.subject, tt.code, encoded); err != nil || ok != tt.want {
+					t.Errorf("Verify(%s, %s) = %v, %v, want %v", tt.subject, tt.code, ok, err, tt.want)
+				}
+			}
+
+			// Another pepper with the same algorithm does not verify the hash
+			other, _ := NewHasher(algorithm, "another-pepper-0123456789abcdef", "", time.Time{})
+			if ok, _ := other.Verify("ann@example.com", "123456", encoded); ok {
+				t.Error("hash verified under another pepper")
+			}
+		})
+	}
+}
+
+func TestHasherPepperRotation(t *testing.T) {
+	until := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for _, algorithm := range []HashAlgorithm{HMACSHA256, Argon2id} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			before, _ := NewHasher(algorithm, previousPepper, "", time.Time{})
+			issued, err := before.Hash("ann@example.com", "123456")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name     string
+				previous string
+				until    time.Time
+				at       time.Time
+				want     bool
+			}{
+				{"within the window", previousPepper, until, until.Add(-time.Second), true},
+				{"window closed", previousPepper, until, until, false},
+				{"long after the window", previousPepper, until, until.Add(24 * time.Hour), false},
+				{"no window", previousPepper, time.Time{}, until.Add(24 * time.Hour), true},
+				{"previous pepper dropped", "", time.Time{}, until, false},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					h, err := NewHasher(algorithm, currentPepper, tt.previous, tt.until)
+					if err != nil {
+						t.Fatal(err)
+					}
+					h.now = func() time.Time { return tt.at }
+					if ok, err := h.Verify("ann@example.com", "123456", issued); err != nil || ok != tt.want {
+						t.Errorf("Verify() = %v, %v, want %v", ok, err, tt.want)
+					}
+					if ok, _ := h.Verify("ann@example.com", "654321", issued); ok {
+						t.Error("wrong code verified under the previous pepper")
+					}
+
+					// New hashes use the current pepper, whatever the window
+					current, err := h.Hash("ann@example.com", "123456")
+					if err != nil {
+						t.Fatal(err)
+					}
+					if ok, err := h.Verify("ann@example.com", "123456", current); err != nil || !ok {
+						t.Errorf("Verify() of a current hash = %v, %v, want true", ok, err)
+					}
+					if ok, _ := before.Verify("ann@example.com", "123456", current); ok {
+						t.Error("current hash verified under the previous pepper alone")
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestHasherMalformed(t *testing.T) {
+	h, err := NewHasher(HMACSHA256, currentPepper, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := h.current.id
+	for _, encoded := range []string{
+		"",
+		"plaintext",
+		"hmac-sha256$" + id,
+		"hmac-sha256$" + id + "$not-hex",
+		"hmac-sha256$" + id + "$00$00",
+		"argon2id$" + id + "$c2FsdA",
+		"argon2id$" + id + "$***$a2V5",
+		"argon2id$" + id + "$c2FsdA$***",
+		"md5$" + id + "$00",
+	} {
+		if ok, err := h.Verify("ann@example.com", "123456", encoded); ok || !errors.Is(err, domain.ErrOTPHashFormat) {
+			t.Errorf("Verify(%q) = %v, %v, want %v", encoded, ok, err, domain.ErrOTPHashFormat)
+		}
+	}
+}
+
+func TestNewHasherRejects(t *testing.T) {
+	if _, err := NewHasher(HMACSHA256, "", "", time.Time{}); err == nil {
+		t.Error("NewHasher accepted an empty pepper")
+	}
+	if _, err := NewHasher("bcrypt", currentPepper, "", time.Time{}); err == nil {
+		t.Error("NewHasher accepted an unsupported algorithm")
+	}
+}
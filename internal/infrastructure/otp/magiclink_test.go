@@ -0,0 +1,109 @@
+package otp
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+)
+
+const magicLinkSecret = "magic-link-signing-secret-0123456789abcdef"
+
+func testSigner(t *testing.T, now *time.Time) *MagicLinkSigner {
+	t.Helper()
+	s, err := NewMagicLinkSigner(magicLinkSecret, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("NewMagicLinkSigner: %v", err)
+	}
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestMagicLinkRoundTrip(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	s := testSigner(t, &now)
+	token, issued, err := s.Issue("acme", testUserId, "ann@example.com")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if issued.ID == "" || !issued.ExpiresAt.Equal(now.Add(15*time.Minute)) {
+		t.Errorf("issued claims = %+v", issued)
+	}
+	claims, err := s.Parse(token)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if claims != issued {
+		t.Errorf("Parse() = %+v, want %+v", claims, issued)
+	}
+
+	// Token ids are unique, so each link is redeemed on its own
+	_, other, _ := s.Issue("acme", testUserId, "ann@example.com")
+	if other.ID == issued.ID {
+		t.Error("two links share a token id")
+	}
+}
+
+func TestMagicLinkParseRejects(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	s := testSigner(t, &now)
+	token, _, err := s.Issue("acme", testUserId, "ann@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig, _ := strings.Cut(token, ".")
+
+	// A payload changed to another user, keeping the original signature
+	claims, _ := base64.RawURLEncoding.DecodeString(payload)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(claims), testUserId, "9b2c7a10-5d4e-4f3a-8b1c-2d3e4f5a6b7c", 1)))
+	// A validly signed payload that is not a token
+	signed := func(payload string) string {
+		encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+		return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded))
+	}
+	sigBytes, _ := base64.RawURLEncoding.DecodeString(sig)
+	sigBytes[0] ^= 1
+	other, _ := NewMagicLinkSigner("another-signing-secret-0123456789abcdef", time.Minute)
+	otherToken, _, _ := other.Issue("acme", testUserId, "ann@example.com")
+
+	tests := []struct {
+		name  string
+		token string
+		at    time.Duration // Time since issue
+	}{
+		{"tampered signature", payload + "." + base64.RawURLEncoding.EncodeToString(sigBytes), 0},
+		{"tampered payload", forged + "." + sig, 0},
+		{"signed by another secret", otherToken, 0},
+		{"expired", token, 15 * time.Minute},
+		{"long expired", token, 24 * time.Hour},
+		{"no signature", payload, 0},
+		{"empty", "", 0},
+		{"signature not base64", payload + ".***", 0},
+		{"payload not base64", "***." + base64.RawURLEncoding.EncodeToString(s.sign("***")), 0},
+		{"payload not json", signed("not json"), 0},
+		{"no token id", signed(`{"sub":"` + testUserId + `","exp":"2026-10-18T10:00:00Z"}`), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC).Add(tt.at)
+			claims, err := s.Parse(tt.token)
+			if !errors.Is(err, domain.ErrMagicLinkInvalid) {
+				t.Errorf("Parse() = %+v, %v, want %v", claims, err, domain.ErrMagicLinkInvalid)
+			}
+		})
+	}
+
+	now = time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC).Add(15*time.Minute - time.Second)
+	if _, err := s.Parse(token); err != nil {
+		t.Errorf("Parse() just before expiry = %v", err)
+	}
+}
+
+func TestNewMagicLinkSignerShortSecret(t *testing.T) {
+	if _, err := NewMagicLinkSigner("too-short", time.Minute); err == nil {
+		t.Error("NewMagicLinkSigner accepted a short secret")
+	}
+}
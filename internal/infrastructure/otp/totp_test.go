@@ -0,0 +1,134 @@
+package otp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of RFC 6238 appendix B, "12345678901234567890".
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1; the 8-digit codes end in these 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	totp := NewTOTP("EduLearn", 0)
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			at := time.Unix(tt.unix, 0)
+			step, ok, err := totp.Validate(rfc6238Secret, tt.code, at)
+			if err != nil || !ok {
+				t.Fatalf("Validate() = %v, %v, want the code accepted", ok, err)
+			}
+			if want := tt.unix / 30; step != want {
+				t.Errorf("step = %d, want %d", step, want)
+			}
+		})
+	}
+}
+
+func TestTOTPDrift(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	at := func(steps int) string { return hotpAt(t, now.Add(time.Duration(steps)*totpPeriod)) }
+
+	tests := []struct {
+		name  string
+		skew  int
+		drift int
+		ok    bool
+	}{
+		{"current step", 0, 0, true},
+		{"previous step without skew", 0, -1, false},
+		{"next step without skew", 0, 1, false},
+		{"previous step", 1, -1, true},
+		{"next step", 1, 1, true},
+		{"two steps behind", 1, -2, false},
+		{"two steps ahead", 1, 2, false},
+		{"wider skew", 2, -2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok, err := NewTOTP("EduLearn", tt.skew).Validate(rfc6238Secret, at(tt.drift), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok {
+				t.Fatalf("Validate() = %v, want %v", ok, tt.ok)
+			}
+			if want := now.Unix()/30 + int64(tt.drift); ok && step != want {
+				t.Errorf("step = %d, want the step the code was made for, %d", step, want)
+			}
+		})
+	}
+}
+
+func TestTOTPRejects(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	totp := NewTOTP("EduLearn", 1)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		err    bool
+	}{
+		{"wrong code", rfc6238Secret, "000000", false},
+		{"short code", rfc6238Secret, "00592", false},
+		{"8 digit code", rfc6238Secret, "89005924", false},
+		{"secret not base32", "not base32!", "005924", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok, err := totp.Validate(tt.secret, tt.code, now)
+			if ok || (err != nil) != tt.err {
+				t.Errorf("Validate() = %v, %v, want rejected with error %v", ok, err, tt.err)
+			}
+		})
+	}
+
+	// Secrets are accepted as users may type them
+	if _, ok, err := totp.Validate(" "+strings.ToLower(rfc6238Secret), "005924", now); err != nil || !ok {
+		t.Errorf("Validate() of a lower-case secret = %v, %v, want accepted", ok, err)
+	}
+}
+
+func TestTOTPGenerateSecret(t *testing.T) {
+	totp := NewTOTP("EduLearn", 1)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != totpSecretLen {
+		t.Errorf("secret %q decodes to %d bytes, %v, want %d", secret, len(key), err, totpSecretLen)
+	}
+	now := time.Now()
+	if _, ok, _ := totp.Validate(secret, hotpFor(t, secret, now), now); !ok {
+		t.Error("a code from the generated secret is rejected")
+	}
+	if uri := totp.ProvisioningURI(secret, "ann@example.com"); !strings.HasPrefix(uri, "otpauth://totp/EduLearn:ann@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("ProvisioningURI() = %q", uri)
+	}
+}
+
+func hotpAt(t *testing.T, at time.Time) string {
+	return hotpFor(t, rfc6238Secret, at)
+}
+
+func hotpFor(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hotp(key, at.Unix()/int64(totpPeriod.Seconds()))
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+)
+
+// Scope identifies what a send is charged against.
+type Scope struct {
+	Channel   string
+	Recipient string
+	Tenant    string
+}
+
+// Limits configures each scope. Tenants overrides Tenant for specific
+// tenant ids; a disabled (zero) Limit skips that scope.
+type Limits struct {
+	Global    Limit
+	Recipient Limit
+	Tenant    Limit
+	Channels  map[string]Limit
+	Tenants   map[string]Limit
+}
+
+// Factory builds the backing Limiter for one configured Limit.
+type Factory func(limit Limit) Limiter
+
+// Policy applies the global, tenant, channel and per-recipient limits in
+// turn. Each scope uses its own key prefix, so a single backend key space
+// can be shared by all of them.
+type Policy struct {
+	global    Limiter
+	recipient Limiter
+	tenant    Limiter
+	channels  map[string]Limiter
+	tenants   map[string]Limiter
+}
+
+func NewPolicy(limits Limits, factory Factory) *Policy {
+	build := func(l Limit) Limiter {
+		if !l.Enabled() {
+			return nil
+		}
+		return factory(l)
+	}
+
+	p := &Policy{
+		global:    build(limits.Global),
+		recipient: build(limits.Recipient),
+		tenant:    build(limits.Tenant),
+		channels:  make(map[string]Limiter),
+		tenants:   make(map[string]Limiter),
+	}
+	for channel, l := range limits.Channels {
+		if limiter := build(l); limiter != nil {
+			p.channels[channel] = limiter
+		}
+	}
+	for tenant, l := range limits.Tenants {
+		if limiter := build(l); limiter != nil {
+			p.tenants[tenant] = limiter
+		}
+	}
+	return p
+}
+
+func (p *Policy) Allow(ctx context.Context, scope Scope) error {
+	if p.global != nil {
+		if err := p.global.Allow(ctx, "global"); err != nil {
+			return err
+		}
+	}
+	if scope.Tenant != "" {
+		limiter, ok := p.tenants[scope.Tenant]
+		if !ok {
+			limiter = p.tenant
+		}
+		if limiter != nil {
+			if err := limiter.Allow(ctx, "tenant:"+scope.Tenant); err != nil {
+				return err
+			}
+		}
+	}
+	if limiter, ok := p.channels[scope.Channel]; ok {
+		if err := limiter.Allow(ctx, "channel:"+scope.Channel); err != nil {
+			return err
+		}
+	}
+	if p.recipient != nil && scope.Recipient != "" {
+		if err := p.recipient.Allow(ctx, "recipient:"+scope.Channel+":"+scope.Recipient); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Quota is a limit on the requests for one key.
+type Quota struct {
+	Key   string
+	Limit Limit
+}
+
+// QuotaReserver takes a slot of several quotas in one step. ReserveAll
+// takes a slot of every enabled quota and returns -1 if each has one free;
+// otherwise it takes none and returns the index of a full quota and how
+// long until it has a free slot.
+type QuotaReserver interface {
+	ReserveAll(ctx context.Context, quotas []Quota) (int, time.Duration, error)
+}
+
+// LocalQuotas is a per-process QuotaReserver, keeping at most size keys of
+// each limit like RateLimiter.
+type LocalQuotas struct {
+	mutex    sync.Mutex
+	limiters map[Limit]*RateLimiter
+	size     int
+}
+
+func NewLocalQuotas(size int) *LocalQuotas {
+	return &LocalQuotas{limiters: make(map[Limit]*RateLimiter), size: size}
+}
+
+func (q *LocalQuotas) ReserveAll(ctx context.Context, quotas []Quota) (int, time.Duration, error) {
+	// Held throughout, so no other request sees the slots taken before
+	// one is cancelled
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	var taken []*rate.Reservation
+	cancel := func() {
+		for _, reservation := range taken {
+			reservation.CancelAt(now)
+		}
+	}
+	for i, quota := range quotas {
+		if !quota.Limit.Enabled() {
+			continue
+		}
+		reservation := q.limiter(quota.Limit).get(quota.Key).ReserveN(now, 1)
+		if !reservation.OK() {
+			cancel()
+			return i, 0, domain.ErrRateLimit
+		}
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			cancel()
+			return i, delay, nil
+		}
+		taken = append(taken, reservation)
+	}
+	return -1, 0, nil
+}
+
+func (q *LocalQuotas) limiter(limit Limit) *RateLimiter {
+	limiter, ok := q.limiters[limit]
+	if !ok {
+		limiter = NewRateLimiter(limit.Rate, limit.Burst, q.size)
+		q.limiters[limit] = limiter
+	}
+	return limiter
+}
+
+// RedisQuotas is a distributed QuotaReserver, taking the slots of all
+// quotas in one script run. When Redis is unreachable it degrades to the
+// per-process fallback rather than failing requests.
+type RedisQuotas struct {
+	client   *redis.Client
+	fallback QuotaReserver
+	logger   *zap.Logger
+}
+
+func NewRedisQuotas(client *redis.Client, fallback QuotaReserver, logger *zap.Logger) *RedisQuotas {
+	return &RedisQuotas{client: client, fallback: fallback, logger: logger}
+}
+
+func (r *RedisQuotas) ReserveAll(ctx context.Context, quotas []Quota) (int, time.Duration, error) {
+	var keys []string
+	var args []interface{}
+	var indexes []int // Index in quotas of each key
+	for i, quota := range quotas {
+		if !quota.Limit.Enabled() {
+			continue
+		}
+		keys = append(keys, "ratelimit:"+quota.Key)
+		args = append(args, gcraArgs(quota.Limit)...)
+		indexes = append(indexes, i)
+	}
+	if len(keys) == 0 {
+		return -1, 0, nil
+	}
+
+	res, err := gcra.Run(ctx, r.client, keys, args...).Int64Slice()
+	if err != nil {
+		r.logger.Warn("Redis rate limiter unavailable, using local fallback",
+			zap.Strings("keys", keys), zap.Error(err))
+		return r.fallback.ReserveAll(ctx, quotas)
+	}
+	if res[0] == 0 {
+		return -1, 0, nil
+	}
+	return indexes[res[0]-1], time.Duration(res[1]) * time.Millisecond, nil
+}
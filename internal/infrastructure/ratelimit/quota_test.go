@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func testQuotaReservers(t *testing.T) map[string]QuotaReserver {
+	t.Helper()
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	down := miniredis.RunT(t)
+	unreachable := redis.NewClient(&redis.Options{Addr: down.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { unreachable.Close() })
+	down.Close()
+
+	return map[string]QuotaReserver{
+		"local":          NewLocalQuotas(0),
+		"redis":          NewRedisQuotas(client, NewLocalQuotas(0), zap.NewNop()),
+		"redis fallback": NewRedisQuotas(unreachable, NewLocalQuotas(0), zap.NewNop()),
+	}
+}
+
+func TestReserveAll(t *testing.T) {
+	ip := Quota{Key: "rpc:SendOTP:ip:10.0.0.1", Limit: Limit{Rate: 1, Burst: 3}}
+	email := Quota{Key: "rpc:SendOTP:email:ann@example.com", Limit: Limit{Rate: 1, Burst: 1}}
+	off := Quota{Key: "rpc:SendOTP:user:u1", Limit: Limit{}}
+
+	steps := []struct {
+		name   string
+		quotas []Quota
+		full   int
+	}{
+		{"both free", []Quota{ip, email}, -1},
+		{"email full", []Quota{ip, email}, 1},
+		// The rejected request above left the IP quota's two slots
+		{"ip slot", []Quota{ip}, -1},
+		{"last ip slot", []Quota{ip}, -1},
+		{"ip full", []Quota{ip, email}, 0},
+		{"disabled quota", []Quota{off, off}, -1},
+		{"no quotas", nil, -1},
+	}
+	for name, reserver := range testQuotaReservers(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, step := range steps {
+				full, retryAfter, err := reserver.ReserveAll(context.Background(), step.quotas)
+				if err != nil {
+					t.Fatalf("%s: ReserveAll: %v", step.name, err)
+				}
+				if full != step.full {
+					t.Fatalf("%s: full quota = %d, want %d", step.name, full, step.full)
+				}
+				if (full >= 0) != (retryAfter > 0) {
+					t.Errorf("%s: retry after %v with full quota %d", step.name, retryAfter, full)
+				}
+			}
+		})
+	}
+}
+
+func TestReserveAllConcurrent(t *testing.T) {
+	// Only one request fits the email quota, and only it may be charged to
+	// the shared IP quota
+	ip := Quota{Key: "rpc:SendOTP:ip:10.0.0.1", Limit: Limit{Rate: 0.01, Burst: 20}}
+	email := Quota{Key: "rpc:SendOTP:email:ann@example.com", Limit: Limit{Rate: 0.01, Burst: 1}}
+
+	for name, reserver := range testQuotaReservers(t) {
+		t.Run(name, func(t *testing.T) {
+			const requests = 10
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			admitted := 0
+			for range requests {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					full, _, err := reserver.ReserveAll(context.Background(), []Quota{ip, email})
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if full < 0 {
+						mu.Lock()
+						admitted++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			if admitted != 1 {
+				t.Fatalf("%d requests admitted, want 1", admitted)
+			}
+
+			left := 0
+			for {
+				full, _, err := reserver.ReserveAll(context.Background(), []Quota{ip})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if full >= 0 {
+					break
+				}
+				left++
+			}
+			if left != ip.Limit.Burst-1 {
+				t.Errorf("ip quota had %d slots left, want %d", left, ip.Limit.Burst-1)
+			}
+		})
+	}
+}
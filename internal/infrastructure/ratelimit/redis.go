@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// gcra implements the generic cell rate algorithm. It stores a single
+// "theoretical arrival time" per key, so every replica shares the same limit
+// with one round trip. Redis' own clock is used to avoid skew between pods.
+//
+// It takes a slot of every key in KEYS, whose emission interval and
+// tolerance in milliseconds are in ARGV in pairs, or of none if any key has
+// no free slot, so a request rejected by one limit is not charged to others.
+//
+// Returns {0, 0} if the slots were taken, else {index of the full key from
+// 1, retry_after_ms}.
+var gcra = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + tonumber(t[2]) / 1000
+
+local tats = {}
+for i, key in ipairs(KEYS) do
+	local emission = tonumber(ARGV[2 * i - 1])
+	local tolerance = tonumber(ARGV[2 * i])
+	local tat = tonumber(redis.call("GET", key))
+	if not tat or tat < now then
+		tat = now
+	end
+
+	local new_tat = tat + emission
+	local allow_at = new_tat - tolerance
+	if allow_at > now then
+		return {i, math.ceil(allow_at - now)}
+	end
+	tats[i] = new_tat
+end
+
+for i, key in ipairs(KEYS) do
+	redis.call("SET", key, tats[i], "PX", math.ceil(tats[i] - now))
+end
+return {0, 0}
+`)
+
+// gcraArgs returns the gcra arguments of limit.
+func gcraArgs(limit Limit) []interface{} {
+	emission := 1000 / limit.Rate
+	burst := math.Max(float64(limit.Burst), 1)
+	return []interface{}{emission, emission * burst}
+}
+
+// RedisLimiter is a distributed Limiter. When Redis is unreachable it
+// degrades to the per-process fallback rather than failing sends.
+type RedisLimiter struct {
+	client   *redis.Client
+	limit    Limit
+	fallback Reserver
+	logger   *zap.Logger
+}
+
+func NewRedisLimiter(client *redis.Client, limit Limit, fallback Reserver, logger *zap.Logger) *RedisLimiter {
+	return &RedisLimiter{client: client, limit: limit, fallback: fallback, logger: logger}
+}
+
+func (r *RedisLimiter) Allow(ctx context.Context, key string) error {
+	for {
+		retryAfter, err := r.Reserve(ctx, key)
+		if err != nil {
+			return err
+		}
+		if retryAfter == 0 {
+			return nil
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(retryAfter).After(deadline) {
+			return domain.RateLimitedError(domain.ErrRateLimit, retryAfter)
+		}
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return domain.RateLimitedError(domain.ErrRateLimit, retryAfter)
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *RedisLimiter) Reserve(ctx context.Context, key string) (time.Duration, error) {
+	if !r.limit.Enabled() {
+		return 0, nil
+	}
+	retryAfter, err := r.reserve(ctx, key)
+	if err != nil {
+		r.logger.Warn("Redis rate limiter unavailable, using local fallback",
+			zap.String("key", key), zap.Error(err))
+		return r.fallback.Reserve(ctx, key)
+	}
+	return retryAfter, nil
+}
+
+func (r *RedisLimiter) reserve(ctx context.Context, key string) (time.Duration, error) {
+	res, err := gcra.Run(ctx, r.client, []string{"ratelimit:" + key}, gcraArgs(r.limit)...).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	if res[0] == 0 {
+		return 0, nil
+	}
+	return time.Duration(res[1]) * time.Millisecond, nil
+}
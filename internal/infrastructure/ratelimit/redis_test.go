@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func newTestRedisLimiter(t *testing.T, limit Limit) (*RedisLimiter, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	fallback := NewRateLimiter(limit.Rate, limit.Burst, 0)
+	return NewRedisLimiter(client, limit, fallback, zap.NewNop()), server
+}
+
+func TestRedisLimiterGCRA(t *testing.T) {
+	// 10/s emits a slot every 100ms, with a burst of 3 on top
+	limit := Limit{Rate: 10, Burst: 3}
+
+	type step struct {
+		advance time.Duration
+		want    time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"burst is allowed", []step{{want: 0}, {want: 0}, {want: 0}}},
+		{"beyond burst waits one emission", []step{{want: 0}, {want: 0}, {want: 0}, {want: 100 * time.Millisecond}}},
+		{"rejection consumes nothing", []step{{want: 0}, {want: 0}, {want: 0}, {want: 100 * time.Millisecond}, {want: 100 * time.Millisecond}}},
+		{"slot frees after emission", []step{{want: 0}, {want: 0}, {want: 0}, {advance: 100 * time.Millisecond, want: 0}, {want: 100 * time.Millisecond}}},
+		{"partial wait", []step{{want: 0}, {want: 0}, {want: 0}, {advance: 40 * time.Millisecond, want: 60 * time.Millisecond}}},
+		{"idle refills burst only", []step{{advance: time.Hour, want: 0}, {want: 0}, {want: 0}, {want: 100 * time.Millisecond}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, server := newTestRedisLimiter(t, limit)
+			now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+			for i, s := range tt.steps {
+				now = now.Add(s.advance)
+				server.SetTime(now)
+				got, err := limiter.Reserve(context.Background(), "recipient:email:ann@example.com")
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				if got != s.want {
+					t.Fatalf("step %d: retry after %v, want %v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisLimiterKeysAreIndependent(t *testing.T) {
+	limiter, _ := newTestRedisLimiter(t, Limit{Rate: 1, Burst: 1})
+	ctx := context.Background()
+	if d, _ := limiter.Reserve(ctx, "tenant:acme"); d != 0 {
+		t.Fatalf("first reserve waits %v", d)
+	}
+	if d, _ := limiter.Reserve(ctx, "tenant:acme"); d == 0 {
+		t.Fatal("second reserve of the same key allowed")
+	}
+	if d, _ := limiter.Reserve(ctx, "tenant:globex"); d != 0 {
+		t.Fatalf("other key waits %v", d)
+	}
+}
+
+func TestRedisLimiterAllowDeadline(t *testing.T) {
+	limiter, _ := newTestRedisLimiter(t, Limit{Rate: 0.1, Burst: 1})
+	if err := limiter.Allow(context.Background(), "global"); err != nil {
+		t.Fatalf("first Allow: %v", err)
+	}
+
+	// The next slot is 10s away, beyond the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := limiter.Allow(ctx, "global")
+	if !errors.Is(err, domain.ErrRateLimit) {
+		t.Fatalf("Allow = %v, want ErrRateLimit", err)
+	}
+	failure := domain.ClassifyDelivery(err)
+	if failure.Class != domain.DeliveryRateLimited || failure.RetryAfter <= time.Second {
+		t.Errorf("classified as %v after %v, want rate limited after more than 1s", failure.Class, failure.RetryAfter)
+	}
+}
+
+func TestRedisLimiterFallback(t *testing.T) {
+	limiter, server := newTestRedisLimiter(t, Limit{Rate: 1, Burst: 2})
+	server.Close()
+
+	ctx := context.Background()
+	for i := range 2 {
+		if d, err := limiter.Reserve(ctx, "global"); err != nil || d != 0 {
+			t.Fatalf("reserve %d = %v, %v, want the local burst", i, d, err)
+		}
+	}
+	if d, err := limiter.Reserve(ctx, "global"); err != nil || d == 0 {
+		t.Errorf("Reserve = %v, %v, want the local limiter's wait", d, err)
+	}
+}
+
+func TestRedisLimiterDisabled(t *testing.T) {
+	limiter, server := newTestRedisLimiter(t, Limit{})
+	for range 3 {
+		if d, err := limiter.Reserve(context.Background(), "global"); err != nil || d != 0 {
+			t.Fatalf("Reserve = %v, %v, want no limit", d, err)
+		}
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("disabled limiter stored %v", keys)
+	}
+}
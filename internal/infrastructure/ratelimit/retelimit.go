@@ -1,39 +1,108 @@
 package ratelimit
 
 import (
+	"container/list"
 	"context"
 	"sync"
+	"time"
 
 	"github.com/Shafeeqth/notification-service/internal/domain"
 	"golang.org/x/time/rate"
 )
 
+// Limiter blocks until key may proceed, returning domain.ErrRateLimit if
+// that cannot happen before ctx is done.
+type Limiter interface {
+	Allow(ctx context.Context, key string) error
+}
+
+// Reserver is the non-blocking form of Limiter. Reserve takes a slot for key
+// if one is free and returns 0, otherwise it returns how long until the next
+// slot opens without consuming anything.
+type Reserver interface {
+	Reserve(ctx context.Context, key string) (time.Duration, error)
+}
+
+// Limit is a token-bucket style limit. A zero Rate disables limiting.
+type Limit struct {
+	Rate  float64 // Requests per second
+	Burst int     // Burst size
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// RateLimiter is a per-process limiter. Keys are kept in an LRU of at most
+// size entries so unique recipients cannot grow memory without bound.
 type RateLimiter struct {
-	limiters map[string]*rate.Limiter
+	limiters map[string]*list.Element
+	order    *list.List
 	mutex    sync.Mutex
 	rate     float64 // Requests per second
 	burst    int     // Burst size
+	size     int
+}
+
+type entry struct {
+	key     string
+	limiter *rate.Limiter
 }
 
-func NewRateLimiter(rates float64, burst int) *RateLimiter {
+func NewRateLimiter(rates float64, burst int, size int) *RateLimiter {
+	if size <= 0 {
+		size = 10000
+	}
 	return &RateLimiter{
-		limiters: make(map[string]*rate.Limiter),
+		limiters: make(map[string]*list.Element),
+		order:    list.New(),
 		rate:     rates,
 		burst:    burst,
+		size:     size,
 	}
 }
 
 func (r *RateLimiter) Allow(ctx context.Context, key string) error {
-	r.mutex.Lock()
-	limiter, exists := r.limiters[key]
-	if !exists {
-		limiter = rate.NewLimiter(rate.Limit(r.rate), r.burst)
-		r.limiters[key] = limiter
+	if r.rate <= 0 {
+		return nil
 	}
-	r.mutex.Unlock()
-	if err := limiter.Wait(ctx); err != nil {
+	if err := r.get(key).Wait(ctx); err != nil {
 		return domain.ErrRateLimit
 	}
 	return nil
 
 }
+
+func (r *RateLimiter) Reserve(ctx context.Context, key string) (time.Duration, error) {
+	if r.rate <= 0 {
+		return 0, nil
+	}
+	reservation := r.get(key).Reserve()
+	if !reservation.OK() {
+		return 0, domain.ErrRateLimit
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay, nil
+	}
+	return 0, nil
+}
+
+func (r *RateLimiter) get(key string) *rate.Limiter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if el, exists := r.limiters[key]; exists {
+		r.order.MoveToFront(el)
+		return el.Value.(*entry).limiter
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(r.rate), r.burst)
+	r.limiters[key] = r.order.PushFront(&entry{key: key, limiter: limiter})
+	if r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.limiters, oldest.Value.(*entry).key)
+	}
+	return limiter
+}
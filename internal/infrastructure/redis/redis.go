@@ -3,7 +3,6 @@ package redis
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/Shafeeqth/notification-service/internal/domain"
@@ -31,15 +30,41 @@ func NewRedisClient(addr string, logger *zap.Logger) (*RedisClient, error) {
 	return &RedisClient{client: client, logger: logger}, nil
 }
 
-func (r *RedisClient) SaveOTP(ctx context.Context,otp domain.OTP) error {
+// Client exposes the underlying client for components that need raw
+// commands, such as the distributed rate limiter.
+func (r *RedisClient) Client() *redis.Client {
+	return r.client
+}
+
+func otpKey(tenantId, email string) string {
+	return "otp:" + tenantId + ":" + email
+}
+
+func otpFailuresKey(tenantId, email string) string {
+	return "otp-failures:" + tenantId + ":" + email
+}
+
+// SaveOTP persists the OTP record under its tenant. Only otp.CodeHash is
+// stored; the plaintext code is never serialized.
+func (r *RedisClient) SaveOTP(ctx context.Context, otp domain.OTP) error {
+	if otp.CodeHash == "" {
+		r.logger.Error("Refusing to save OTP without a code hash", zap.String("email", otp.Email))
+		return domain.ErrOTPHashFormat
+	}
 	data, err := json.Marshal(otp)
 	if err != nil {
 		r.logger.Error("Failed to marshal OTP", zap.Error(err))
 		return err
 	}
-	// Set with expiration - (based on ExpiresAt)
+	// Set with expiration - (based on ExpiresAt); a new code starts with
+	// no failed attempts
 	duration := time.Until(otp.ExpiresAt)
-	if err := r.client.SetEx(ctx, "otp:"+otp.Email, data, duration).Err(); err != nil {
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetEx(ctx, otpKey(otp.TenantId, otp.Email), data, duration)
+		pipe.Del(ctx, otpFailuresKey(otp.TenantId, otp.Email))
+		return nil
+	})
+	if err != nil {
 		r.logger.Error("Failed to save OTP to Redis", zap.Error(err))
 		return err
 	}
@@ -48,11 +73,11 @@ func (r *RedisClient) SaveOTP(ctx context.Context,otp domain.OTP) error {
 
 }
 
-func (r *RedisClient) GetOTP(ctx context.Context, email string) (domain.OTP, error) {
-	data, err := r.client.Get(ctx, "otp:"+email).Bytes()
-	if err != redis.Nil {
+func (r *RedisClient) GetOTP(ctx context.Context, tenantId, email string) (domain.OTP, error) {
+	data, err := r.client.Get(ctx, otpKey(tenantId, email)).Bytes()
+	if err == redis.Nil {
 		r.logger.Warn("OTP not found in Redis", zap.String("email", email))
-		return domain.OTP{}, fmt.Errorf("OTP not found for email: %s", email)
+		return domain.OTP{}, domain.ErrOTPNotFound
 
 	}
 	if err != nil {
@@ -68,12 +93,61 @@ func (r *RedisClient) GetOTP(ctx context.Context, email string) (domain.OTP, err
 	// redis automatically expires but still for confirmation
 	if time.Now().After(otp.ExpiresAt) {
 		r.logger.Warn("OTP expired", zap.String("email", email))
-		return domain.OTP{}, fmt.Errorf("OTP expired for email: %s", email)
+		return domain.OTP{}, domain.ErrOTPExpired
 	}
 	return otp, nil
 
 }
 
+// ConsumeOTP deletes the OTP; only the caller whose DEL removed the key gets
+// true, which makes a code single-use across replicas.
+func (r *RedisClient) ConsumeOTP(ctx context.Context, tenantId, email string) (bool, error) {
+	deleted, err := r.client.Del(ctx, otpKey(tenantId, email)).Result()
+	if err != nil {
+		r.logger.Error("Failed to consume OTP in Redis", zap.Error(err))
+		return false, err
+	}
+	if err := r.client.Del(ctx, otpFailuresKey(tenantId, email)).Err(); err != nil {
+		r.logger.Warn("Failed to clear OTP failures in Redis", zap.Error(err))
+	}
+	return deleted == 1, nil
+}
+
+// RecordOTPFailure increments the failed attempts of an OTP, expiring them
+// with it.
+func (r *RedisClient) RecordOTPFailure(ctx context.Context, tenantId, email string, expiresAt time.Time) (int64, error) {
+	var failures *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		failures = pipe.Incr(ctx, otpFailuresKey(tenantId, email))
+		pipe.ExpireAt(ctx, otpFailuresKey(tenantId, email), expiresAt)
+		return nil
+	})
+	if err != nil {
+		r.logger.Error("Failed to record OTP failure in Redis", zap.Error(err))
+		return 0, err
+	}
+	return failures.Val(), nil
+}
+
+func (r *RedisClient) SaveMagicLink(ctx context.Context, tokenId string, ttl time.Duration) error {
+	if err := r.client.SetEx(ctx, "magiclink:"+tokenId, 1, ttl).Err(); err != nil {
+		r.logger.Error("Failed to save magic link to Redis", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ConsumeMagicLink deletes the token id; only the caller whose DEL removed
+// the key gets true, which makes redemption single-use across replicas.
+func (r *RedisClient) ConsumeMagicLink(ctx context.Context, tokenId string) (bool, error) {
+	deleted, err := r.client.Del(ctx, "magiclink:"+tokenId).Result()
+	if err != nil {
+		r.logger.Error("Failed to consume magic link in Redis", zap.Error(err))
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
 func (r *RedisClient) Close() error {
 	if err := r.client.Close(); err != nil {
 		r.logger.Error("Failed to close redis client", zap.Error(err))
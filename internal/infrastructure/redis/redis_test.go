@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+)
+
+func newTestRedisClient(t *testing.T) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client, err := NewRedisClient(server.Addr(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, server
+}
+
+func TestConsumeMagicLinkOnce(t *testing.T) {
+	client, server := newTestRedisClient(t)
+	ctx := context.Background()
+	if err := client.SaveMagicLink(ctx, "link-1", time.Minute); err != nil {
+		t.Fatalf("SaveMagicLink: %v", err)
+	}
+	if err := client.SaveMagicLink(ctx, "link-2", time.Minute); err != nil {
+		t.Fatalf("SaveMagicLink: %v", err)
+	}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		tokenId string
+		want    bool
+	}{
+		{"first use", 0, "link-1", true},
+		{"second use", 0, "link-1", false},
+		{"unknown id", 0, "link-3", false},
+		{"after expiry", time.Minute, "link-2", false},
+	}
+	for _, step := range steps {
+		server.FastForward(step.advance)
+		got, err := client.ConsumeMagicLink(ctx, step.tokenId)
+		if err != nil {
+			t.Fatalf("%s: ConsumeMagicLink: %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: ConsumeMagicLink() = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestConsumeMagicLinkConcurrent(t *testing.T) {
+	client, _ := newTestRedisClient(t)
+	ctx := context.Background()
+	if err := client.SaveMagicLink(ctx, "link-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	const redeemers = 8
+	results := make(chan bool, redeemers)
+	for range redeemers {
+		go func() {
+			redeemed, err := client.ConsumeMagicLink(ctx, "link-1")
+			if err != nil {
+				t.Error(err)
+			}
+			results <- redeemed
+		}()
+	}
+	redeemed := 0
+	for range redeemers {
+		if <-results {
+			redeemed++
+		}
+	}
+	if redeemed != 1 {
+		t.Errorf("link redeemed %d times, want once", redeemed)
+	}
+}
@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Unread counters are cached per tenant and user in a hash: "_total" plus
+// one field per category. A missing hash means "unknown" and is rebuilt from Postgres on
+// the next read, so increments only apply to hashes that already exist.
+const (
+	unreadTotalField = "_total"
+	unreadTTL        = 24 * time.Hour
+)
+
+var incrUnread = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HINCRBY", KEYS[1], "_total", ARGV[1])
+if ARGV[2] ~= "" then
+	redis.call("HINCRBY", KEYS[1], ARGV[2], ARGV[1])
+end
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+return 1
+`)
+
+// UnreadUser identifies one cached counter.
+type UnreadUser struct {
+	TenantId string
+	UserId   string
+}
+
+func unreadKey(tenantId, userId string) string {
+	return "unread:" + tenantId + ":" + userId
+}
+
+func unreadChannel(tenantId, userId string) string {
+	return "unread-events:" + tenantId + ":" + userId
+}
+
+// IncrUnread adjusts a user's cached counters by delta and notifies
+// subscribers.
+func (r *RedisClient) IncrUnread(ctx context.Context, tenantId, userId, category string, delta int64) error {
+	if err := incrUnread.Run(ctx, r.client, []string{unreadKey(tenantId, userId)}, delta, category, int(unreadTTL.Seconds())).Err(); err != nil {
+		r.logger.Error("Failed to update unread counter", zap.String("tenant_id", tenantId), zap.String("user_id", userId), zap.Error(err))
+		return err
+	}
+	return r.publishUnread(ctx, tenantId, userId)
+}
+
+// SetUnread replaces a user's cached counters and notifies subscribers if
+// they changed.
+func (r *RedisClient) SetUnread(ctx context.Context, tenantId, userId string, count domain.UnreadCount) error {
+	fields := map[string]interface{}{unreadTotalField: count.Total}
+	for category, n := range count.ByCategory {
+		fields[category] = n
+	}
+
+	previous, _, _ := r.GetUnread(ctx, tenantId, userId)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, unreadKey(tenantId, userId))
+		pipe.HSet(ctx, unreadKey(tenantId, userId), fields)
+		pipe.Expire(ctx, unreadKey(tenantId, userId), unreadTTL)
+		return nil
+	})
+	if err != nil {
+		r.logger.Error("Failed to set unread counter", zap.String("tenant_id", tenantId), zap.String("user_id", userId), zap.Error(err))
+		return err
+	}
+	if !previous.Equal(count) {
+		return r.publishUnread(ctx, tenantId, userId)
+	}
+	return nil
+}
+
+// InvalidateUnread drops a user's cached counters so the next read reloads
+// them, and notifies subscribers.
+func (r *RedisClient) InvalidateUnread(ctx context.Context, tenantId, userId string) error {
+	if err := r.client.Del(ctx, unreadKey(tenantId, userId)).Err(); err != nil {
+		r.logger.Error("Failed to invalidate unread counter", zap.String("tenant_id", tenantId), zap.String("user_id", userId), zap.Error(err))
+		return err
+	}
+	return r.publishUnread(ctx, tenantId, userId)
+}
+
+// GetUnread returns the cached counters; found is false on a cache miss.
+func (r *RedisClient) GetUnread(ctx context.Context, tenantId, userId string) (domain.UnreadCount, bool, error) {
+	fields, err := r.client.HGetAll(ctx, unreadKey(tenantId, userId)).Result()
+	if err != nil {
+		r.logger.Error("Failed to get unread counter", zap.String("tenant_id", tenantId), zap.String("user_id", userId), zap.Error(err))
+		return domain.UnreadCount{}, false, err
+	}
+	if len(fields) == 0 {
+		return domain.UnreadCount{}, false, nil
+	}
+
+	count := domain.UnreadCount{ByCategory: make(map[string]int64)}
+	for field, value := range fields {
+		n, _ := strconv.ParseInt(value, 10, 64)
+		if field == unreadTotalField {
+			count.Total = n
+		} else if n > 0 {
+			count.ByCategory[field] = n
+		}
+	}
+	return count, true, nil
+}
+
+// SubscribeUnread returns a channel that receives a value whenever the
+// user's counters change, on any replica. The channel is closed when ctx is
+// done.
+func (r *RedisClient) SubscribeUnread(ctx context.Context, tenantId, userId string) (<-chan struct{}, error) {
+	sub := r.client.Subscribe(ctx, unreadChannel(tenantId, userId))
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		r.logger.Error("Failed to subscribe to unread counter", zap.String("tenant_id", tenantId), zap.String("user_id", userId), zap.Error(err))
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+				// Coalesce bursts; the subscriber re-reads the latest value
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// UnreadUsers returns the users with cached counters.
+func (r *RedisClient) UnreadUsers(ctx context.Context) ([]UnreadUser, error) {
+	var users []UnreadUser
+	iter := r.client.Scan(ctx, 0, "unread:*", 500).Iterator()
+	for iter.Next(ctx) {
+		// Keys without a tenant predate multi-tenancy and simply expire
+		tenantId, userId, ok := strings.Cut(strings.TrimPrefix(iter.Val(), "unread:"), ":")
+		if ok {
+			users = append(users, UnreadUser{TenantId: tenantId, UserId: userId})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		r.logger.Error("Failed to scan unread counters", zap.Error(err))
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *RedisClient) publishUnread(ctx context.Context, tenantId, userId string) error {
+	if err := r.client.Publish(ctx, unreadChannel(tenantId, userId), "changed").Err(); err != nil {
+		r.logger.Warn("Failed to publish unread counter change", zap.String("tenant_id", tenantId), zap.String("user_id", userId), zap.Error(err))
+		return err
+	}
+	return nil
+}
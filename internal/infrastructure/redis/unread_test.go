@@ -0,0 +1,133 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"go.uber.org/zap"
+)
+
+func TestUnreadCounter(t *testing.T) {
+	client, server := newTestRedisClient(t)
+	ctx := context.Background()
+	get := func() (domain.UnreadCount, bool) {
+		t.Helper()
+		count, found, err := client.GetUnread(ctx, "acme", "u1")
+		if err != nil {
+			t.Fatalf("GetUnread: %v", err)
+		}
+		return count, found
+	}
+
+	// Increments do not fabricate a counter that was never loaded
+	if err := client.IncrUnread(ctx, "acme", "u1", "course", 1); err != nil {
+		t.Fatalf("IncrUnread: %v", err)
+	}
+	if count, found := get(); found {
+		t.Fatalf("GetUnread() = %+v after an increment of a missing counter, want a miss", count)
+	}
+
+	loaded := domain.UnreadCount{Total: 3, ByCategory: map[string]int64{"course": 2, "billing": 1}}
+	if err := client.SetUnread(ctx, "acme", "u1", loaded); err != nil {
+		t.Fatalf("SetUnread: %v", err)
+	}
+	steps := []struct {
+		name     string
+		category string
+		delta    int64
+		want     domain.UnreadCount
+	}{
+		{"saved", "course", 1, domain.UnreadCount{Total: 4, ByCategory: map[string]int64{"course": 3, "billing": 1}}},
+		{"saved uncategorized", "", 1, domain.UnreadCount{Total: 5, ByCategory: map[string]int64{"course": 3, "billing": 1}}},
+		{"read", "billing", -1, domain.UnreadCount{Total: 4, ByCategory: map[string]int64{"course": 3}}},
+	}
+	for _, step := range steps {
+		if err := client.IncrUnread(ctx, "acme", "u1", step.category, step.delta); err != nil {
+			t.Fatalf("%s: IncrUnread: %v", step.name, err)
+		}
+		if count, found := get(); !found || !count.Equal(step.want) {
+			t.Errorf("%s: GetUnread() = %+v, %v, want %+v", step.name, count, found, step.want)
+		}
+	}
+	if ttl := server.TTL(unreadKey("acme", "u1")); ttl <= 0 || ttl > unreadTTL {
+		t.Errorf("counter TTL = %v, want up to %v", ttl, unreadTTL)
+	}
+
+	// Another tenant's counter for the same user is separate
+	if count, found, _ := client.GetUnread(ctx, "globex", "u1"); found {
+		t.Errorf("GetUnread() in another tenant = %+v, want a miss", count)
+	}
+
+	if err := client.InvalidateUnread(ctx, "acme", "u1"); err != nil {
+		t.Fatalf("InvalidateUnread: %v", err)
+	}
+	if count, found := get(); found {
+		t.Errorf("GetUnread() after invalidation = %+v, want a miss", count)
+	}
+}
+
+func TestSubscribeUnread(t *testing.T) {
+	publisher, server := newTestRedisClient(t)
+	// The subscriber is another replica on the same Redis
+	subscriber, err := NewRedisClient(server.Addr(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := subscriber.SubscribeUnread(ctx, "acme", "u1")
+	if err != nil {
+		t.Fatalf("SubscribeUnread: %v", err)
+	}
+	expect := func(name string, want bool) {
+		t.Helper()
+		select {
+		case _, ok := <-changes:
+			if !want || !ok {
+				t.Errorf("%s: got a change (open %v), want none", name, ok)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if want {
+				t.Errorf("%s: no change received", name)
+			}
+		}
+	}
+
+	if err := publisher.SetUnread(ctx, "acme", "u1", domain.UnreadCount{Total: 1}); err != nil {
+		t.Fatal(err)
+	}
+	expect("counter loaded", true)
+	if err := publisher.IncrUnread(ctx, "acme", "u1", "", 1); err != nil {
+		t.Fatal(err)
+	}
+	expect("counter incremented", true)
+	if err := publisher.SetUnread(ctx, "acme", "u1", domain.UnreadCount{Total: 2}); err != nil {
+		t.Fatal(err)
+	}
+	expect("counter reloaded unchanged", false)
+	if err := publisher.IncrUnread(ctx, "acme", "u2", "", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.IncrUnread(ctx, "globex", "u1", "", 1); err != nil {
+		t.Fatal(err)
+	}
+	expect("other users and tenants", false)
+	if err := publisher.InvalidateUnread(ctx, "acme", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	expect("counter invalidated", true)
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Error("change received after the subscription ended")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel not closed when the subscription ended")
+	}
+}
@@ -0,0 +1,175 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/auth"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Auth authenticates callers by bearer JWT or verified client certificate
+// and stores the resulting domain.Principal in the request context.
+// Requests carrying a user_id are rejected unless the principal is that
+// user or holds the admin or service role, and privilegedMethods are
+// rejected unless it holds one of those roles.
+type Auth struct {
+	jwt               *auth.JWTVerifier
+	mtls              bool
+	allowedIdentities map[string]bool
+	publicMethods     map[string]bool
+	logger            *zap.Logger
+}
+
+// NewAuth creates the auth layer. verifier may be nil to disable JWTs.
+// With mtls enabled, a client certificate verified by the server's TLS
+// config is accepted only if its identity is in allowedIdentities, so a
+// certificate merely signed by the CA does not make its holder a service.
+// publicMethods are short RPC names that skip authentication.
+func NewAuth(verifier *auth.JWTVerifier, mtls bool, allowedIdentities, publicMethods []string, logger *zap.Logger) *Auth {
+	a := &Auth{
+		jwt:               verifier,
+		mtls:              mtls,
+		allowedIdentities: make(map[string]bool),
+		publicMethods:     make(map[string]bool),
+		logger:            logger,
+	}
+	for _, id := range allowedIdentities {
+		a.allowedIdentities[id] = true
+	}
+	for _, m := range publicMethods {
+		a.publicMethods[strings.ToLower(m)] = true
+	}
+	return a
+}
+
+func (a *Auth) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+		if a.publicMethods[strings.ToLower(method)] {
+			return handler(ctx, req)
+		}
+
+		principal, err := a.authenticate(ctx)
+		if err != nil {
+			a.logger.Warn("Authentication failed", zap.String("method", method), zap.Error(err))
+			return nil, toStatus(domain.ErrUnauthenticated)
+		}
+		if err := a.authorize(method, principal, req); err != nil {
+			return nil, err
+		}
+
+		return handler(domain.ContextWithPrincipal(ctx, principal), req)
+	}
+}
+
+// StreamInterceptor authenticates when the stream opens and authorizes
+// every received message, since the user_id is only known once the
+// handler reads the request.
+func (a *Auth) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+		if a.publicMethods[strings.ToLower(method)] {
+			return handler(srv, ss)
+		}
+
+		principal, err := a.authenticate(ss.Context())
+		if err != nil {
+			a.logger.Warn("Authentication failed", zap.String("method", method), zap.Error(err))
+			return toStatus(domain.ErrUnauthenticated)
+		}
+
+		return handler(srv, &authorizedStream{
+			ServerStream: ss,
+			ctx:          domain.ContextWithPrincipal(ss.Context(), principal),
+			auth:         a,
+			method:       method,
+			principal:    principal,
+		})
+	}
+}
+
+// privilegedMethods are short RPC names only admins and services may call:
+// they send to any users and recipients, which no single user_id check
+// covers.
+var privilegedMethods = map[string]bool{
+	"sendnotification":       true,
+	"batchsendnotifications": true,
+}
+
+// authorize rejects privileged methods for principals without the admin or
+// service role, and requests carrying a user_id the principal may not act
+// for.
+func (a *Auth) authorize(method string, principal domain.Principal, req interface{}) error {
+	if privilegedMethods[strings.ToLower(method)] && !principal.HasRole(domain.RoleAdmin) && !principal.HasRole(domain.RoleService) {
+		a.logger.Warn("Principal not allowed to call method",
+			zap.String("method", method),
+			zap.String("principal", principal.Subject))
+		return toStatus(domain.ErrUnauthorized)
+	}
+	if r, ok := req.(interface{ GetUserId() string }); ok && r.GetUserId() != "" {
+		if !principal.CanActFor(r.GetUserId()) {
+			a.logger.Warn("Principal not allowed to act for user",
+				zap.String("method", method),
+				zap.String("principal", principal.Subject),
+				zap.String("user_id", r.GetUserId()))
+			return toStatus(domain.ErrUnauthorized)
+		}
+	}
+	return nil
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	auth      *Auth
+	method    string
+	principal domain.Principal
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.auth.authorize(s.method, s.principal, m)
+}
+
+func (a *Auth) authenticate(ctx context.Context) (domain.Principal, error) {
+	if token := bearerToken(ctx); token != "" && a.jwt != nil {
+		return a.jwt.Verify(token)
+	}
+
+	if a.mtls {
+		if p, ok := peer.FromContext(ctx); ok {
+			if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+				identity := auth.CertificateIdentity(tlsInfo.State.VerifiedChains[0][0])
+				if !a.allowedIdentities[identity] {
+					return domain.Principal{}, domain.ErrUnauthenticated
+				}
+				return domain.Principal{
+					Subject: identity,
+					Kind:    domain.ServicePrincipal,
+					Roles:   []string{domain.RoleService},
+				}, nil
+			}
+		}
+	}
+	return domain.Principal{}, domain.ErrUnauthenticated
+}
+
+func bearerToken(ctx context.Context) string {
+	header := firstMetadata(ctx, "authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthorize(t *testing.T) {
+	user := domain.Principal{Subject: "u1", Kind: domain.UserPrincipal}
+	admin := domain.Principal{Subject: "a1", Kind: domain.UserPrincipal, Roles: []string{domain.RoleAdmin}}
+	svc := domain.Principal{Subject: "svc", Kind: domain.ServicePrincipal, Roles: []string{domain.RoleService}}
+
+	tests := []struct {
+		name      string
+		method    string
+		principal domain.Principal
+		req       interface{}
+		allowed   bool
+	}{
+		{"own user_id", "GetAllNotifications", user, &proto.GetAllNotificationsRequest{UserId: "u1"}, true},
+		{"other user_id", "GetAllNotifications", user, &proto.GetAllNotificationsRequest{UserId: "u2"}, false},
+		{"admin for other user", "GetAllNotifications", admin, &proto.GetAllNotificationsRequest{UserId: "u2"}, true},
+		{"user sends to self", "SendNotification", user, &proto.SendNotificationRequest{UserId: "u1"}, false},
+		{"user batch send", "BatchSendNotifications", user, &proto.BatchSendNotificationsRequest{}, false},
+		{"service sends", "SendNotification", svc, &proto.SendNotificationRequest{UserId: "u2"}, true},
+		{"service batch send", "BatchSendNotifications", svc, &proto.BatchSendNotificationsRequest{}, true},
+		{"admin sends", "SendNotification", admin, &proto.SendNotificationRequest{UserId: "u2"}, true},
+	}
+	a := NewAuth(nil, false, nil, nil, zap.NewNop())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.authorize(tt.method, tt.principal, tt.req)
+			if tt.allowed && err != nil {
+				t.Fatalf("authorize() = %v, want allowed", err)
+			}
+			if !tt.allowed && status.Code(err) != codes.PermissionDenied {
+				t.Fatalf("authorize() = %v, want PermissionDenied", err)
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{"no principal", context.Background(), codes.Unauthenticated},
+		{"user", domain.ContextWithPrincipal(context.Background(), domain.Principal{Subject: "u1"}), codes.PermissionDenied},
+		{"service", domain.ContextWithPrincipal(context.Background(), domain.Principal{Subject: "svc", Roles: []string{domain.RoleService}}), codes.PermissionDenied},
+		{"admin", domain.ContextWithPrincipal(context.Background(), domain.Principal{Subject: "a1", Roles: []string{domain.RoleAdmin}}), codes.OK},
+	}
+	h := &Handler{logger: zap.NewNop()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(h.requireAdmin(tt.ctx)); code != tt.code {
+				t.Errorf("requireAdmin() code = %v, want %v", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestAuthenticateClientCertificate(t *testing.T) {
+	withCert := func(commonName string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+		return peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}},
+		})
+	}
+	unverified := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+
+	tests := []struct {
+		name    string
+		mtls    bool
+		allowed []string
+		ctx     context.Context
+		ok      bool
+	}{
+		{"allowed identity", true, []string{"billing", "courses"}, withCert("courses"), true},
+		{"identity not allowed", true, []string{"billing"}, withCert("courses"), false},
+		{"no identities allowed", true, nil, withCert("courses"), false},
+		{"unverified certificate", true, []string{"courses"}, unverified, false},
+		{"no peer", true, []string{"courses"}, context.Background(), false},
+		{"mtls disabled", false, []string{"courses"}, withCert("courses"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuth(nil, tt.mtls, tt.allowed, nil, zap.NewNop())
+			principal, err := a.authenticate(tt.ctx)
+			if !tt.ok {
+				if !errors.Is(err, domain.ErrUnauthenticated) {
+					t.Errorf("authenticate() = %+v, %v, want %v", principal, err, domain.ErrUnauthenticated)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticate: %v", err)
+			}
+			if principal.Subject != "courses" || principal.Kind != domain.ServicePrincipal || !principal.HasRole(domain.RoleService) {
+				t.Errorf("authenticate() = %+v, want the courses service", principal)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package grpc
+
+import (
+	"errors"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errorDomain is reported in ErrorInfo so clients can tell our reasons
+// apart from those of other services.
+const errorDomain = "notification.edulearn"
+
+type errorMapping struct {
+	err     error
+	code    codes.Code
+	reason  string
+	message string
+}
+
+// errorMappings translates domain errors into gRPC statuses. Messages are
+// fixed strings so internal error text never reaches callers.
+var errorMappings = []errorMapping{
+	{domain.ErrNotFound, codes.NotFound, "NOTIFICATION_NOT_FOUND", "notification not found"},
+	{domain.ErrUnauthorized, codes.PermissionDenied, "PERMISSION_DENIED", "not allowed to access this resource"},
+	{domain.ErrUnauthenticated, codes.Unauthenticated, "UNAUTHENTICATED", "valid credentials are required"},
+	{domain.ErrRateLimit, codes.ResourceExhausted, "RATE_LIMITED", "too many requests, retry later"},
+	{domain.ErrOTPNotFound, codes.NotFound, "OTP_NOT_FOUND", "no pending OTP for this address"},
+	{domain.ErrOTPExpired, codes.FailedPrecondition, "OTP_EXPIRED", "OTP has expired"},
+	{domain.ErrOTPAttemptsExceeded, codes.FailedPrecondition, "OTP_ATTEMPTS_EXCEEDED", "too many failed attempts, request a new OTP"},
+	{domain.ErrTOTPNotEnrolled, codes.FailedPrecondition, "TOTP_NOT_ENROLLED", "authenticator app is not enrolled"},
+	{domain.ErrTOTPEnrolled, codes.AlreadyExists, "TOTP_ALREADY_ENROLLED", "authenticator app is already enrolled"},
+	{domain.ErrMagicLinkInvalid, codes.Unauthenticated, "MAGIC_LINK_INVALID", "magic link is invalid or expired"},
+	{domain.ErrMagicLinkUsed, codes.FailedPrecondition, "MAGIC_LINK_USED", "magic link has already been used"},
+	{domain.ErrAlreadyProcessed, codes.AlreadyExists, "ALREADY_PROCESSED", "notification already processed"},
+	{domain.ErrKafkaProduce, codes.Unavailable, "QUEUE_UNAVAILABLE", "notification could not be queued, retry later"},
+	{domain.ErrEmailSend, codes.Unavailable, "DELIVERY_UNAVAILABLE", "notification could not be delivered, retry later"},
+	{domain.ErrInvalidPageToken, codes.InvalidArgument, "INVALID_PAGE_TOKEN", "invalid page token"},
+	{domain.ErrUnknownTenant, codes.InvalidArgument, "UNKNOWN_TENANT", "unknown tenant"},
+	{domain.ErrInvalidPayload, codes.InvalidArgument, "INVALID_PAYLOAD", "invalid notification payload"},
+	{domain.ErrUnknownTemplate, codes.InvalidArgument, "UNKNOWN_TEMPLATE", "unknown template"},
+	{domain.ErrUnsupportedChannel, codes.InvalidArgument, "UNSUPPORTED_CHANNEL", "notification channel is not available"},
+	{domain.ErrIdempotencyKeyReused, codes.FailedPrecondition, "IDEMPOTENCY_KEY_REUSED", "idempotency key was already used for a different request"},
+	{domain.ErrIdempotencyKeyInProgress, codes.Aborted, "IDEMPOTENCY_KEY_IN_PROGRESS", "a request with this idempotency key is in progress, retry later"},
+	{domain.ErrRuleNotFound, codes.NotFound, "RULE_NOT_FOUND", "notification rule not found"},
+	{domain.ErrDatabase, codes.Internal, "INTERNAL", "internal error"},
+}
+
+// toStatus converts a service error into a gRPC status error carrying an
+// ErrorInfo reason, and a RetryInfo for a rate limit whose retry-after is
+// known. Unknown errors become a bare Internal.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	var invalid *domain.InvalidPayloadError
+	if errors.As(err, &invalid) {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(invalid.Violations))
+		for i, v := range invalid.Violations {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: v.Field, Description: v.Description}
+		}
+		return withDetails(status.New(codes.InvalidArgument, "invalid request data"),
+			&errdetails.BadRequest{FieldViolations: violations})
+	}
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+				Reason: m.reason,
+				Domain: errorDomain,
+			}}
+			var delivery *domain.DeliveryError
+			if errors.As(err, &delivery) && delivery.Class == domain.DeliveryRateLimited && delivery.RetryAfter > 0 {
+				details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(delivery.RetryAfter)})
+			}
+			return withDetails(status.New(m.code, m.message), details...)
+		}
+	}
+	return withDetails(status.New(codes.Internal, "internal error"), &errdetails.ErrorInfo{
+		Reason: "INTERNAL",
+		Domain: errorDomain,
+	})
+}
+
+// field is one request field checked against validator tags.
+type field struct {
+	name  string
+	value interface{}
+	tag   string
+}
+
+// validate checks each field and returns InvalidArgument with a BadRequest
+// detail listing every violation, or nil.
+func (h *Handler) validate(fields ...field) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, f := range fields {
+		err := h.validator.Var(f.value, f.tag)
+		if err == nil {
+			continue
+		}
+		var verrs validator.ValidationErrors
+		if !errors.As(err, &verrs) {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: f.name, Description: "invalid value"})
+			continue
+		}
+		for _, v := range verrs {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.name,
+				Description: describeViolation(v.Tag(), v.Param()),
+			})
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return withDetails(status.New(codes.InvalidArgument, "invalid request data"),
+		&errdetails.BadRequest{FieldViolations: violations})
+}
+
+// invalidField reports a single field violation found outside the validator.
+func invalidField(name, description string) error {
+	return withDetails(status.New(codes.InvalidArgument, "invalid request data"),
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+			Field:       name,
+			Description: description,
+		}}})
+}
+
+func describeViolation(tag, param string) string {
+	switch tag {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "uuid":
+		return "must be a UUID"
+	case "numeric":
+		return "must be numeric"
+	case "len":
+		return "must be " + param + " characters long"
+	case "min":
+		return "must be at least " + param
+	case "max":
+		return "must be at most " + param
+	case "oneof":
+		return "must be one of: " + param
+	case "url":
+		return "must be a valid URL"
+	default:
+		return "failed " + tag + " validation"
+	}
+}
+
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	if detailed, err := st.WithDetails(details...); err == nil {
+		return detailed.Err()
+	}
+	return st.Err()
+}
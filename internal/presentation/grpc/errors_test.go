@@ -0,0 +1,68 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		code       codes.Code
+		reason     string
+		retryAfter time.Duration
+	}{
+		{"not found", domain.ErrNotFound, codes.NotFound, "NOTIFICATION_NOT_FOUND", 0},
+		{"wrapped", fmt.Errorf("loading: %w", domain.ErrRuleNotFound), codes.NotFound, "RULE_NOT_FOUND", 0},
+		{"rate limit without retry-after", domain.ErrRateLimit, codes.ResourceExhausted, "RATE_LIMITED", 0},
+		{"rate limit with retry-after", domain.RateLimitedError(domain.ErrRateLimit, 1500*time.Millisecond), codes.ResourceExhausted, "RATE_LIMITED", 1500 * time.Millisecond},
+		{"transient delivery", domain.TransientError(domain.ErrDatabase), codes.Internal, "INTERNAL", 0},
+		{"unsupported channel", fmt.Errorf("%w: sms", domain.ErrUnsupportedChannel), codes.InvalidArgument, "UNSUPPORTED_CHANNEL", 0},
+		{"unknown", errors.New("boom"), codes.Internal, "INTERNAL", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(toStatus(tt.err))
+			if !ok {
+				t.Fatalf("toStatus(%v) is not a status", tt.err)
+			}
+			if st.Code() != tt.code {
+				t.Errorf("code = %v, want %v", st.Code(), tt.code)
+			}
+			var reason string
+			var retryAfter time.Duration
+			for _, detail := range st.Details() {
+				switch d := detail.(type) {
+				case *errdetails.ErrorInfo:
+					reason = d.Reason
+				case *errdetails.RetryInfo:
+					retryAfter = d.RetryDelay.AsDuration()
+				}
+			}
+			if reason != tt.reason {
+				t.Errorf("reason = %q, want %q", reason, tt.reason)
+			}
+			if retryAfter != tt.retryAfter {
+				t.Errorf("retry delay = %v, want %v", retryAfter, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestToStatusKeepsStatusErrors(t *testing.T) {
+	err := status.Error(codes.PermissionDenied, "denied")
+	if got := toStatus(err); got != err {
+		t.Errorf("toStatus(%v) = %v, want it unchanged", err, got)
+	}
+	if toStatus(nil) != nil {
+		t.Error("toStatus(nil) != nil")
+	}
+}
@@ -2,11 +2,11 @@ package grpc
 
 import (
 	"context"
-	"fmt"
 	"strings"
 	"time"
 
 	"github.com/Shafeeqth/notification-service/internal/application/service"
+	"github.com/Shafeeqth/notification-service/internal/domain"
 	"github.com/Shafeeqth/notification-service/internal/infrastructure/metrics"
 	"github.com/Shafeeqth/notification-service/internal/proto"
 	"github.com/go-playground/validator/v10"
@@ -23,15 +23,19 @@ var isAlnum = func(s string) bool {
 	return true
 }
 
+// maxPageSize caps GetAllNotifications pages.
+const maxPageSize = 100
+
 type Handler struct {
 	proto.UnimplementedNotificationServiceServer
 	notificationService *service.NotificationService
 	otpService          *service.OTPService
+	ruleService         *service.RuleService
 	logger              *zap.Logger
 	validator           *validator.Validate
 }
 
-func NewHandler(notificationService *service.NotificationService, otpService *service.OTPService, logger *zap.Logger) *Handler {
+func NewHandler(notificationService *service.NotificationService, otpService *service.OTPService, ruleService *service.RuleService, logger *zap.Logger) *Handler {
 	v := validator.New()
 
 	// Register custom validation for username
@@ -51,6 +55,7 @@ func NewHandler(notificationService *service.NotificationService, otpService *se
 	return &Handler{
 		notificationService: notificationService,
 		otpService:          otpService,
+		ruleService:         ruleService,
 		logger:              logger,
 		validator:           v,
 	}
@@ -58,16 +63,21 @@ func NewHandler(notificationService *service.NotificationService, otpService *se
 
 func (h *Handler) SendOTP(ctx context.Context, req *proto.OTPRequest) (*proto.NotificationResponse, error) {
 	// Validate request using validator
-	if err := h.validator.Struct(req); err != nil {
-		h.logger.Error("Validation failed", zap.Error(err))
-		return &proto.NotificationResponse{Success: false, Message: "Invalid request data"}, nil
+	if err := h.validate(
+		field{"user_id", req.UserId, "required"},
+		field{"email", req.Email, "required,email"},
+		field{"username", req.Username, "required"},
+		field{"locale", req.Locale, "omitempty,bcp47_language_tag"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
 	}
 
 	h.logger.Info("Request received to handler (:)")
-	_, err := h.otpService.SendOTP(ctx, req.UserId, req.Email, req.Username)
+	_, err := h.otpService.SendOTP(ctx, req.UserId, req.Email, req.Username, req.Locale)
 	if err != nil {
 		h.logger.Error("Failed to send OTP", zap.Error(err))
-		return &proto.NotificationResponse{Success: false, Message: err.Error()}, nil
+		return nil, toStatus(err)
 	}
 
 	metrics.OTPSentTotal.Inc()
@@ -76,16 +86,20 @@ func (h *Handler) SendOTP(ctx context.Context, req *proto.OTPRequest) (*proto.No
 
 func (h *Handler) VerifyOTP(ctx context.Context, req *proto.VerifyOTPRequest) (*proto.NotificationResponse, error) {
 	// Validate request
-	if err := h.validator.Struct(req); err != nil {
-		h.logger.Error("Validation failed", zap.Error(err))
-		return &proto.NotificationResponse{Success: false, Message: "Invalid request data"}, nil
+	if err := h.validate(
+		field{"user_id", req.UserId, "required"},
+		field{"email", req.Email, "required,email"},
+		field{"otp", req.Otp, "required,numeric,len=6"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
 	}
 
 	// Verify OTP
 	isValid, err := h.otpService.VerifyOTP(ctx, req.UserId, req.Email, req.Otp)
 	if err != nil {
 		h.logger.Error("Failed to verify OTP", zap.Error(err))
-		return &proto.NotificationResponse{Success: false, Message: err.Error()}, nil
+		return nil, toStatus(err)
 	}
 
 	if !isValid {
@@ -98,17 +112,21 @@ func (h *Handler) VerifyOTP(ctx context.Context, req *proto.VerifyOTPRequest) (*
 
 func (h *Handler) ForgotPassword(ctx context.Context, req *proto.ForgotPasswordRequest) (*proto.NotificationResponse, error) {
 	// Validate request
-	if err := h.validator.Struct(req); err != nil {
-		h.logger.Error("Validation failed", zap.Error(err))
-		return &proto.NotificationResponse{Success: false, Message: "Invalid request data"}, nil
+	if err := h.validate(
+		field{"user_id", req.UserId, "required"},
+		field{"email", req.Email, "required,email"},
+		field{"reset_link", req.ResetLink, "required,url"},
+		field{"locale", req.Locale, "omitempty,bcp47_language_tag"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
 	}
 
 	// Send password reset email
-	subject := "Password Reset Request"
-	body := fmt.Sprintf("Click the following link to reset your password: %s", req.ResetLink)
-	if err := h.notificationService.SendEmailNotification(ctx, req.UserId, req.Email, subject, body); err != nil {
+	vars := map[string]interface{}{"reset_link": req.ResetLink}
+	if err := h.notificationService.SendSensitiveEmail(ctx, req.UserId, req.Email, "password-reset", req.Locale, vars); err != nil {
 		h.logger.Error("Failed to send password reset email", zap.Error(err))
-		return &proto.NotificationResponse{Success: false, Message: err.Error()}, nil
+		return nil, toStatus(err)
 	}
 
 	h.logger.Info("Password reset email sent", zap.String("email", req.Email))
@@ -117,9 +135,12 @@ func (h *Handler) ForgotPassword(ctx context.Context, req *proto.ForgotPasswordR
 
 func (h *Handler) GetANotification(ctx context.Context, req *proto.GetNotificationRequest) (*proto.Notification, error) {
 	// Validate request using validator
-	if err := h.validator.Struct(req); err != nil {
-		h.logger.Error("Validation failed", zap.Error(err))
-		return nil, fmt.Errorf("invalid request data")
+	if err := h.validate(
+		field{"notification_id", req.NotificationId, "required"},
+		field{"user_id", req.UserId, "required"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
 	}
 
 	notification, err := h.notificationService.GetANotification(ctx, req.NotificationId, req.UserId)
@@ -128,79 +149,263 @@ func (h *Handler) GetANotification(ctx context.Context, req *proto.GetNotificati
 			zap.String("userId", req.UserId),
 			zap.String("notification_id", req.NotificationId),
 			zap.Error(err))
-		return nil, err
+		return nil, toStatus(err)
 	}
-	return &proto.Notification{
-			Id:        notification.ID,
-			UserId:    notification.UserId,
-			Type:      string(notification.Type),
-			Subject:   notification.Subject,
-			Body:      notification.Body,
-			Recipient: notification.Recipient,
-			IsRead:    notification.IsRead,
-			CreatedAt: notification.CreatedAt.Format(time.RFC3339),
-		},
-		nil
+	return toProtoNotification(*notification), nil
 }
 
 func (h *Handler) GetAllNotifications(ctx context.Context, req *proto.GetAllNotificationsRequest) (*proto.GetAllNotificationsResponse, error) {
-	page := int(req.Page)
-	if page < 1 {
-		page = 1
+	if err := h.validate(field{"user_id", req.UserId, "required"}); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
 	}
 
 	pageSize := int(req.PageSize)
 	if pageSize < 1 {
-		page = 10
+		pageSize = 10
 	}
-	var isRead *bool
-	if req.IsRead {
-		isRead = &req.IsRead
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
 	}
-	var notifyType *string
-	if req.Type != "" {
-		notifyType = &req.Type
+
+	filter, err := h.notificationFilter(req)
+	if err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
 	}
 
-	notifications, total, err := h.notificationService.GetAllNotifications(ctx, req.UserId, page, pageSize, isRead, notifyType)
+	var after *domain.PageCursor
+	if req.PageToken != "" {
+		cursor, err := domain.DecodePageCursor(req.PageToken)
+		if err != nil {
+			return nil, invalidField("page_token", "is not a valid page token")
+		}
+		after = &cursor
+	}
+
+	notifications, next, total, err := h.notificationService.GetAllNotifications(ctx, req.UserId, filter, after, pageSize)
 	if err != nil {
 		h.logger.Error("Failed to get notifications", zap.String("userId", req.UserId), zap.Error(err))
-		return nil, err
+		return nil, toStatus(err)
 	}
 	protoNotifications := make([]*proto.Notification, len(notifications))
 	for i, n := range notifications {
-		protoNotifications[i] = &proto.Notification{
-			Id:        n.ID,
-			UserId:    n.UserId,
-			Type:      string(n.Type),
-			Subject:   n.Subject,
-			Body:      n.Body,
-			Recipient: n.Recipient,
-			IsRead:    n.IsRead,
-			CreatedAt: n.CreatedAt.Format(time.RFC3339),
-		}
+		protoNotifications[i] = toProtoNotification(n)
 	}
-	return &proto.GetAllNotificationsResponse{
+	resp := &proto.GetAllNotificationsResponse{
 		Notifications: protoNotifications,
 		Total:         int32(total),
-		Page:          int32(page),
 		PageSize:      int32(pageSize),
-	}, nil
+	}
+	if next != nil {
+		resp.NextPageToken = next.Encode()
+	}
+	return resp, nil
+}
+
+// notificationFilter builds the listing filter from the request; empty
+// strings mean "not filtered".
+func (h *Handler) notificationFilter(req *proto.GetAllNotificationsRequest) (domain.NotificationFilter, error) {
+	filter := domain.NotificationFilter{
+		IsRead:         req.IsRead,
+		Archived:       req.Archived,
+		Pinned:         req.Pinned,
+		IncludeSnoozed: req.IncludeSnoozed,
+	}
+	if filter.Archived == nil {
+		archived := false
+		filter.Archived = &archived
+	}
+	if req.Type != "" {
+		notifyType := domain.NotificationType(req.Type)
+		filter.Type = &notifyType
+	}
+	if req.Category != "" {
+		filter.Category = &req.Category
+	}
+	if req.CreatedAfter != "" {
+		t, err := time.Parse(time.RFC3339, req.CreatedAfter)
+		if err != nil {
+			return filter, invalidField("created_after", "must be an RFC3339 timestamp")
+		}
+		filter.CreatedAfter = &t
+	}
+	if req.CreatedBefore != "" {
+		t, err := time.Parse(time.RFC3339, req.CreatedBefore)
+		if err != nil {
+			return filter, invalidField("created_before", "must be an RFC3339 timestamp")
+		}
+		filter.CreatedBefore = &t
+	}
+	return filter, nil
+}
+
+func toProtoNotification(n domain.Notification) *proto.Notification {
+	notification := &proto.Notification{
+		Id:        n.ID,
+		TenantId:  n.TenantId,
+		UserId:    n.UserId,
+		Type:      string(n.Type),
+		Category:  n.Category,
+		Subject:   n.Subject,
+		Body:      n.Body,
+		Recipient: n.Recipient,
+		IsRead:    n.IsRead,
+		CreatedAt: n.CreatedAt.Format(time.RFC3339),
+		Pinned:    n.Pinned,
+		DeepLink:  n.DeepLink,
+		IconUrl:   n.IconURL,
+		ImageUrl:  n.ImageURL,
+		Actions:   toProtoActions(n.Actions),
+		Metadata:  toProtoMetadata(n.Metadata),
+		Priority:  string(n.Priority),
+	}
+	if n.ArchivedAt != nil {
+		notification.ArchivedAt = n.ArchivedAt.Format(time.RFC3339)
+	}
+	if n.SnoozedUntil != nil {
+		notification.SnoozedUntil = n.SnoozedUntil.Format(time.RFC3339)
+	}
+	if n.SendAt != nil {
+		notification.SendAt = n.SendAt.Format(time.RFC3339)
+	}
+	return notification
 }
 
 func (h *Handler) MarkAsRead(ctx context.Context, req *proto.MarkNotificationRequest) (*proto.NotificationResponse, error) {
+	if err := h.validate(
+		field{"notification_id", req.NotificationId, "required"},
+		field{"user_id", req.UserId, "required"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
 	if err := h.notificationService.MarkAsRead(ctx, req.NotificationId, req.UserId); err != nil {
 		h.logger.Error("Failed to mark as read", zap.Error(err))
-		return &proto.NotificationResponse{Success: false, Message: err.Error()}, nil
+		return nil, toStatus(err)
 
 	}
 	return &proto.NotificationResponse{Success: true, Message: "Notification marked as read"}, nil
 }
 
 func (h *Handler) MarkAllAsRead(ctx context.Context, req *proto.MarkAllNotificationsRequest) (*proto.NotificationResponse, error) {
+	if err := h.validate(field{"user_id", req.UserId, "required"}); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
 	if err := h.notificationService.MarkAllAsRead(ctx, req.UserId); err != nil {
 		h.logger.Error("Failed to mark all as read", zap.Error(err))
-		return &proto.NotificationResponse{Success: false, Message: err.Error()}, nil
+		return nil, toStatus(err)
 	}
 	return &proto.NotificationResponse{Success: true, Message: "All notifications marked as read"}, nil
 }
+
+func (h *Handler) EnrollTOTP(ctx context.Context, req *proto.EnrollTOTPRequest) (*proto.EnrollTOTPResponse, error) {
+	if err := h.validate(
+		field{"user_id", req.UserId, "required"},
+		field{"account_name", req.AccountName, "required"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
+	secret, uri, err := h.otpService.EnrollTOTP(ctx, req.UserId, req.AccountName)
+	if err != nil {
+		h.logger.Error("Failed to enroll TOTP", zap.String("user_id", req.UserId), zap.Error(err))
+		return nil, toStatus(err)
+	}
+	return &proto.EnrollTOTPResponse{Secret: secret, OtpauthUri: uri}, nil
+}
+
+func (h *Handler) VerifyTOTP(ctx context.Context, req *proto.VerifyTOTPRequest) (*proto.NotificationResponse, error) {
+	if err := h.validate(
+		field{"user_id", req.UserId, "required"},
+		field{"code", req.Code, "required,numeric,len=6"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
+	isValid, err := h.otpService.VerifyTOTP(ctx, req.UserId, req.Code)
+	if err != nil {
+		h.logger.Error("Failed to verify TOTP", zap.Error(err))
+		return nil, toStatus(err)
+	}
+	if !isValid {
+		return &proto.NotificationResponse{Success: false, Message: "Invalid code"}, nil
+	}
+	return &proto.NotificationResponse{Success: true, Message: "Code verified successfully"}, nil
+}
+
+func (h *Handler) SendMagicLink(ctx context.Context, req *proto.MagicLinkRequest) (*proto.NotificationResponse, error) {
+	if err := h.validate(
+		field{"user_id", req.UserId, "required"},
+		field{"email", req.Email, "required,email"},
+		field{"locale", req.Locale, "omitempty,bcp47_language_tag"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
+	if err := h.otpService.SendMagicLink(ctx, req.UserId, req.Email, req.Locale); err != nil {
+		h.logger.Error("Failed to send magic link", zap.Error(err))
+		return nil, toStatus(err)
+	}
+	return &proto.NotificationResponse{Success: true, Message: "Magic link sent successfully"}, nil
+}
+
+func (h *Handler) ConsumeMagicLink(ctx context.Context, req *proto.ConsumeMagicLinkRequest) (*proto.ConsumeMagicLinkResponse, error) {
+	if err := h.validate(field{"token", req.Token, "required"}); err != nil {
+		return nil, err
+	}
+
+	claims, err := h.otpService.ConsumeMagicLink(ctx, req.Token)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.ConsumeMagicLinkResponse{
+		Success: true,
+		Message: "Magic link accepted",
+		UserId:  claims.UserId,
+		Email:   claims.Email,
+	}, nil
+}
+
+func (h *Handler) GetUnreadCount(ctx context.Context, req *proto.UnreadCountRequest) (*proto.UnreadCountResponse, error) {
+	if err := h.validate(field{"user_id", req.UserId, "required"}); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
+	count, err := h.notificationService.GetUnreadCount(ctx, req.UserId)
+	if err != nil {
+		h.logger.Error("Failed to get unread count", zap.String("userId", req.UserId), zap.Error(err))
+		return nil, toStatus(err)
+	}
+	return toProtoUnreadCount(count), nil
+}
+
+func (h *Handler) SubscribeUnreadCount(req *proto.UnreadCountRequest, stream proto.NotificationService_SubscribeUnreadCountServer) error {
+	if err := h.validate(field{"user_id", req.UserId, "required"}); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return err
+	}
+
+	counts, err := h.notificationService.SubscribeUnreadCount(stream.Context(), req.UserId)
+	if err != nil {
+		h.logger.Error("Failed to subscribe to unread count", zap.String("userId", req.UserId), zap.Error(err))
+		return toStatus(err)
+	}
+	for count := range counts {
+		if err := stream.Send(toProtoUnreadCount(count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toProtoUnreadCount(count domain.UnreadCount) *proto.UnreadCountResponse {
+	return &proto.UnreadCountResponse{Total: count.Total, ByCategory: count.ByCategory}
+}
@@ -0,0 +1,165 @@
+package grpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/application/service"
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"go.uber.org/zap"
+)
+
+// maxBatchSize caps the notification IDs accepted by batch lifecycle RPCs.
+const maxBatchSize = 100
+
+// lifecycleAction is one archive/delete/snooze/pin operation, shared by the
+// single and batch RPCs.
+type lifecycleAction struct {
+	done   string // Past tense used in responses, e.g. "archived"
+	snooze bool   // Requires snooze_until
+	apply  func(s *service.NotificationService, ctx context.Context, userId string, ids []string, until time.Time) (int64, error)
+}
+
+var (
+	deleteAction = lifecycleAction{done: "deleted", apply: func(s *service.NotificationService, ctx context.Context, userId string, ids []string, _ time.Time) (int64, error) {
+		return s.DeleteNotifications(ctx, userId, ids)
+	}}
+	archiveAction = lifecycleAction{done: "archived", apply: func(s *service.NotificationService, ctx context.Context, userId string, ids []string, _ time.Time) (int64, error) {
+		return s.ArchiveNotifications(ctx, userId, ids, true)
+	}}
+	unarchiveAction = lifecycleAction{done: "unarchived", apply: func(s *service.NotificationService, ctx context.Context, userId string, ids []string, _ time.Time) (int64, error) {
+		return s.ArchiveNotifications(ctx, userId, ids, false)
+	}}
+	snoozeAction = lifecycleAction{done: "snoozed", snooze: true, apply: func(s *service.NotificationService, ctx context.Context, userId string, ids []string, until time.Time) (int64, error) {
+		return s.SnoozeNotifications(ctx, userId, ids, until)
+	}}
+	pinAction = lifecycleAction{done: "pinned", apply: func(s *service.NotificationService, ctx context.Context, userId string, ids []string, _ time.Time) (int64, error) {
+		return s.PinNotifications(ctx, userId, ids, true)
+	}}
+	unpinAction = lifecycleAction{done: "unpinned", apply: func(s *service.NotificationService, ctx context.Context, userId string, ids []string, _ time.Time) (int64, error) {
+		return s.PinNotifications(ctx, userId, ids, false)
+	}}
+)
+
+func (h *Handler) DeleteNotification(ctx context.Context, req *proto.NotificationLifecycleRequest) (*proto.NotificationResponse, error) {
+	return h.applyLifecycle(ctx, deleteAction, req)
+}
+
+func (h *Handler) ArchiveNotification(ctx context.Context, req *proto.NotificationLifecycleRequest) (*proto.NotificationResponse, error) {
+	return h.applyLifecycle(ctx, archiveAction, req)
+}
+
+func (h *Handler) UnarchiveNotification(ctx context.Context, req *proto.NotificationLifecycleRequest) (*proto.NotificationResponse, error) {
+	return h.applyLifecycle(ctx, unarchiveAction, req)
+}
+
+func (h *Handler) SnoozeNotification(ctx context.Context, req *proto.NotificationLifecycleRequest) (*proto.NotificationResponse, error) {
+	return h.applyLifecycle(ctx, snoozeAction, req)
+}
+
+func (h *Handler) PinNotification(ctx context.Context, req *proto.NotificationLifecycleRequest) (*proto.NotificationResponse, error) {
+	return h.applyLifecycle(ctx, pinAction, req)
+}
+
+func (h *Handler) UnpinNotification(ctx context.Context, req *proto.NotificationLifecycleRequest) (*proto.NotificationResponse, error) {
+	return h.applyLifecycle(ctx, unpinAction, req)
+}
+
+func (h *Handler) BatchDeleteNotifications(ctx context.Context, req *proto.BatchNotificationLifecycleRequest) (*proto.BatchNotificationResponse, error) {
+	return h.applyBatchLifecycle(ctx, deleteAction, req)
+}
+
+func (h *Handler) BatchArchiveNotifications(ctx context.Context, req *proto.BatchNotificationLifecycleRequest) (*proto.BatchNotificationResponse, error) {
+	return h.applyBatchLifecycle(ctx, archiveAction, req)
+}
+
+func (h *Handler) BatchUnarchiveNotifications(ctx context.Context, req *proto.BatchNotificationLifecycleRequest) (*proto.BatchNotificationResponse, error) {
+	return h.applyBatchLifecycle(ctx, unarchiveAction, req)
+}
+
+func (h *Handler) BatchSnoozeNotifications(ctx context.Context, req *proto.BatchNotificationLifecycleRequest) (*proto.BatchNotificationResponse, error) {
+	return h.applyBatchLifecycle(ctx, snoozeAction, req)
+}
+
+func (h *Handler) BatchPinNotifications(ctx context.Context, req *proto.BatchNotificationLifecycleRequest) (*proto.BatchNotificationResponse, error) {
+	return h.applyBatchLifecycle(ctx, pinAction, req)
+}
+
+func (h *Handler) BatchUnpinNotifications(ctx context.Context, req *proto.BatchNotificationLifecycleRequest) (*proto.BatchNotificationResponse, error) {
+	return h.applyBatchLifecycle(ctx, unpinAction, req)
+}
+
+func (h *Handler) applyLifecycle(ctx context.Context, action lifecycleAction, req *proto.NotificationLifecycleRequest) (*proto.NotificationResponse, error) {
+	if err := h.validate(
+		field{"notification_id", req.NotificationId, "required"},
+		field{"user_id", req.UserId, "required"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+	until, err := snoozeUntil(action, req.SnoozeUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := action.apply(h.notificationService, ctx, req.UserId, []string{req.NotificationId}, until)
+	if err != nil {
+		h.logger.Error("Failed to update notification",
+			zap.String("action", action.done),
+			zap.String("notification_id", req.NotificationId),
+			zap.Error(err))
+		return nil, toStatus(err)
+	}
+	if updated == 0 {
+		return nil, toStatus(domain.ErrNotFound)
+	}
+	return &proto.NotificationResponse{Success: true, Message: "Notification " + action.done}, nil
+}
+
+func (h *Handler) applyBatchLifecycle(ctx context.Context, action lifecycleAction, req *proto.BatchNotificationLifecycleRequest) (*proto.BatchNotificationResponse, error) {
+	if err := h.validate(
+		field{"notification_ids", req.NotificationIds, "required,min=1,max=" + strconv.Itoa(maxBatchSize) + ",dive,required"},
+		field{"user_id", req.UserId, "required"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+	until, err := snoozeUntil(action, req.SnoozeUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := action.apply(h.notificationService, ctx, req.UserId, req.NotificationIds, until)
+	if err != nil {
+		h.logger.Error("Failed to update notifications",
+			zap.String("action", action.done),
+			zap.String("userId", req.UserId),
+			zap.Error(err))
+		return nil, toStatus(err)
+	}
+	return &proto.BatchNotificationResponse{
+		Success: true,
+		Message: "Notifications " + action.done,
+		Updated: int32(updated),
+	}, nil
+}
+
+// snoozeUntil parses snooze_until for snooze actions; other actions ignore it.
+func snoozeUntil(action lifecycleAction, value string) (time.Time, error) {
+	if !action.snooze {
+		return time.Time{}, nil
+	}
+	if value == "" {
+		return time.Time{}, invalidField("snooze_until", "is required")
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, invalidField("snooze_until", "must be an RFC3339 timestamp")
+	}
+	if !until.After(time.Now()) {
+		return time.Time{}, invalidField("snooze_until", "must be in the future")
+	}
+	return until, nil
+}
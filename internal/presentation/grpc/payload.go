@@ -0,0 +1,42 @@
+package grpc
+
+import (
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func toProtoActions(actions []domain.NotificationAction) []*proto.NotificationAction {
+	if len(actions) == 0 {
+		return nil
+	}
+	converted := make([]*proto.NotificationAction, len(actions))
+	for i, a := range actions {
+		converted[i] = &proto.NotificationAction{Id: a.ID, Label: a.Label, Url: a.URL}
+	}
+	return converted
+}
+
+// toProtoMetadata converts metadata decoded from JSON, which always fits a
+// Struct; anything else is dropped.
+func toProtoMetadata(metadata map[string]interface{}) *structpb.Struct {
+	if len(metadata) == 0 {
+		return nil
+	}
+	converted, err := structpb.NewStruct(metadata)
+	if err != nil {
+		return nil
+	}
+	return converted
+}
+
+func fromProtoActions(actions []*proto.NotificationAction) []domain.NotificationAction {
+	if len(actions) == 0 {
+		return nil
+	}
+	converted := make([]domain.NotificationAction, len(actions))
+	for i, a := range actions {
+		converted[i] = domain.NotificationAction{ID: a.GetId(), Label: a.GetLabel(), URL: a.GetUrl()}
+	}
+	return converted
+}
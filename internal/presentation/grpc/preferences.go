@@ -0,0 +1,47 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) GetPreferences(ctx context.Context, req *proto.PreferencesRequest) (*proto.Preferences, error) {
+	if err := h.validate(field{"user_id", req.UserId, "required"}); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
+	preferences, err := h.notificationService.GetPreferences(ctx, req.UserId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.Preferences{
+		UserId:   preferences.UserId,
+		Locale:   preferences.Locale,
+		TimeZone: preferences.TimeZone,
+	}, nil
+}
+
+func (h *Handler) UpdatePreferences(ctx context.Context, req *proto.Preferences) (*proto.NotificationResponse, error) {
+	if err := h.validate(
+		field{"user_id", req.UserId, "required"},
+		field{"locale", req.Locale, "omitempty,bcp47_language_tag"},
+		field{"time_zone", req.TimeZone, "omitempty,timezone"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
+	preferences := domain.UserPreferences{
+		UserId:   req.UserId,
+		Locale:   req.Locale,
+		TimeZone: req.TimeZone,
+	}
+	if err := h.notificationService.UpdatePreferences(ctx, preferences); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.NotificationResponse{Success: true, Message: "Preferences updated"}, nil
+}
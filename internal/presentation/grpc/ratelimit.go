@@ -0,0 +1,176 @@
+package grpc
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/ratelimit"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// QuotaPolicy limits one RPC per caller IP, per target email and per user.
+// A disabled (zero) limit skips that dimension.
+type QuotaPolicy struct {
+	PerIP    ratelimit.Limit
+	PerEmail ratelimit.Limit
+	PerUser  ratelimit.Limit
+}
+
+type quota struct {
+	dimension string
+	limit     ratelimit.Limit
+}
+
+// Quotas enforces QuotaPolicies on unary RPCs. Policies are keyed by the
+// short method name (e.g. "SendOTP"), case-insensitively.
+type Quotas struct {
+	methods           map[string][]quota
+	reserver          ratelimit.QuotaReserver
+	trustForwardedFor bool
+	logger            *zap.Logger
+}
+
+// NewQuotas builds the quota set. When trustForwardedFor is true, the first
+// x-forwarded-for metadata entry is used as the caller IP; only enable it
+// behind a proxy that overwrites that header.
+func NewQuotas(policies map[string]QuotaPolicy, reserver ratelimit.QuotaReserver, trustForwardedFor bool, logger *zap.Logger) *Quotas {
+	q := &Quotas{
+		methods:           make(map[string][]quota),
+		reserver:          reserver,
+		trustForwardedFor: trustForwardedFor,
+		logger:            logger,
+	}
+	for method, policy := range policies {
+		var quotas []quota
+		add := func(dimension string, limit ratelimit.Limit) {
+			if limit.Enabled() {
+				quotas = append(quotas, quota{dimension: dimension, limit: limit})
+			}
+		}
+		add("ip", policy.PerIP)
+		add("email", policy.PerEmail)
+		add("user", policy.PerUser)
+		q.methods[strings.ToLower(method)] = quotas
+	}
+	return q
+}
+
+func (q *Quotas) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+		quotas, ok := q.methods[strings.ToLower(method)]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		var dimensions []string
+		var reserve []ratelimit.Quota
+		for _, quota := range quotas {
+			subject := q.subject(ctx, req, quota.dimension)
+			if subject == "" {
+				continue
+			}
+			dimensions = append(dimensions, quota.dimension)
+			reserve = append(reserve, ratelimit.Quota{
+				Key:   "rpc:" + method + ":" + quota.dimension + ":" + subject,
+				Limit: quota.limit,
+			})
+		}
+
+		// Every quota is reserved in one step, so a request one dimension
+		// rejects does not use up the others
+		full, retryAfter, err := q.reserver.ReserveAll(ctx, reserve)
+		if err != nil {
+			q.logger.Error("Failed to check RPC quota", zap.String("method", method), zap.Error(err))
+			return nil, status.Error(codes.Unavailable, "rate limiter unavailable")
+		}
+		if full >= 0 {
+			q.logger.Warn("RPC quota exceeded",
+				zap.String("method", method),
+				zap.String("dimension", dimensions[full]),
+				zap.Duration("retry_after", retryAfter))
+			return nil, resourceExhausted(ctx, dimensions[full], retryAfter)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (q *Quotas) subject(ctx context.Context, req interface{}, dimension string) string {
+	switch dimension {
+	case "ip":
+		return q.callerIP(ctx)
+	case "email":
+		if r, ok := req.(interface{ GetEmail() string }); ok {
+			return strings.ToLower(strings.TrimSpace(r.GetEmail()))
+		}
+	case "user":
+		// Only an authenticated caller identifies a user: a user principal
+		// is itself, and a service is trusted with the request's user_id
+		principal, ok := domain.PrincipalFromContext(ctx)
+		if !ok {
+			return ""
+		}
+		if r, ok := req.(interface{ GetUserId() string }); ok && r.GetUserId() != "" && principal.CanActFor(r.GetUserId()) {
+			return r.GetUserId()
+		}
+		return principal.Subject
+	}
+	return ""
+}
+
+func (q *Quotas) callerIP(ctx context.Context) string {
+	if q.trustForwardedFor {
+		if forwarded := firstMetadata(ctx, "x-forwarded-for"); forwarded != "" {
+			ip, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// resourceExhausted reports retry-after both as response metadata (whole
+// seconds, like the HTTP header) and as a RetryInfo error detail.
+func resourceExhausted(ctx context.Context, dimension string, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(seconds)))
+
+	return withDetails(status.New(codes.ResourceExhausted, "too many requests, retry later"),
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)},
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     dimension,
+			Description: "per-" + dimension + " quota exceeded",
+		}}},
+		&errdetails.ErrorInfo{Reason: "RATE_LIMITED", Domain: errorDomain},
+	)
+}
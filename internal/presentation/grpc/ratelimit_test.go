@@ -0,0 +1,205 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/infrastructure/ratelimit"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// recordingReserver records the quotas of every ReserveAll and answers
+// with its fixed result.
+type recordingReserver struct {
+	full       int
+	retryAfter time.Duration
+	err        error
+	reserved   [][]ratelimit.Quota
+}
+
+func (r *recordingReserver) ReserveAll(_ context.Context, quotas []ratelimit.Quota) (int, time.Duration, error) {
+	r.reserved = append(r.reserved, quotas)
+	return r.full, r.retryAfter, r.err
+}
+
+// headerStream captures the headers an interceptor sets.
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestQuotasKeys(t *testing.T) {
+	limit := ratelimit.Limit{Rate: 1, Burst: 5}
+	policies := map[string]QuotaPolicy{"SendOTP": {PerIP: limit, PerEmail: limit, PerUser: limit}}
+	from := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 5555}})
+	}
+	forwarded := metadata.NewIncomingContext(from("10.0.0.1"), metadata.Pairs("x-forwarded-for", "203.0.113.7, 10.0.0.1"))
+	user := domain.Principal{Subject: "u1", Kind: domain.UserPrincipal}
+	svc := domain.Principal{Subject: "svc", Kind: domain.ServicePrincipal, Roles: []string{domain.RoleService}}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		trust     bool
+		method    string
+		req       interface{}
+		principal *domain.Principal
+		want      []string
+	}{
+		{"ip and email", from("10.0.0.1"), false, "SendOTP", &proto.OTPRequest{Email: " Ann@Example.com "},
+			nil, []string{"rpc:SendOTP:ip:10.0.0.1", "rpc:SendOTP:email:ann@example.com"}},
+		{"forwarded ip ignored", forwarded, false, "SendOTP", &proto.OTPRequest{},
+			nil, []string{"rpc:SendOTP:ip:10.0.0.1"}},
+		{"forwarded ip trusted", forwarded, true, "SendOTP", &proto.OTPRequest{},
+			nil, []string{"rpc:SendOTP:ip:203.0.113.7"}},
+		{"user is the caller", from("10.0.0.1"), false, "SendOTP", &proto.OTPRequest{UserId: "u2"},
+			&user, []string{"rpc:SendOTP:ip:10.0.0.1", "rpc:SendOTP:user:u1"}},
+		{"service acts for the user", from("10.0.0.1"), false, "SendOTP", &proto.OTPRequest{UserId: "u2"},
+			&svc, []string{"rpc:SendOTP:ip:10.0.0.1", "rpc:SendOTP:user:u2"}},
+		{"service without a user", from("10.0.0.1"), false, "SendOTP", &proto.OTPRequest{},
+			&svc, []string{"rpc:SendOTP:ip:10.0.0.1", "rpc:SendOTP:user:svc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reserver := &recordingReserver{full: -1}
+			q := NewQuotas(policies, reserver, tt.trust, zap.NewNop())
+			ctx := tt.ctx
+			if tt.principal != nil {
+				ctx = domain.ContextWithPrincipal(ctx, *tt.principal)
+			}
+			called := false
+			_, err := q.UnaryInterceptor()(ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: "/notification.NotificationService/" + tt.method},
+				func(context.Context, interface{}) (interface{}, error) { called = true; return nil, nil })
+			if err != nil || !called {
+				t.Fatalf("interceptor = %v, handler called %v, want the request handled", err, called)
+			}
+			if len(reserver.reserved) != 1 {
+				t.Fatalf("ReserveAll called %d times, want once", len(reserver.reserved))
+			}
+			var keys []string
+			for _, quota := range reserver.reserved[0] {
+				keys = append(keys, quota.Key)
+				if quota.Limit != limit {
+					t.Errorf("quota %s has limit %+v, want %+v", quota.Key, quota.Limit, limit)
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("reserved %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotasReject(t *testing.T) {
+	limit := ratelimit.Limit{Rate: 1, Burst: 5}
+	policies := map[string]QuotaPolicy{"SendOTP": {PerIP: limit, PerEmail: limit}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5555}})
+
+	tests := []struct {
+		name       string
+		reserver   *recordingReserver
+		code       codes.Code
+		dimension  string
+		retryAfter string
+	}{
+		{"ip full", &recordingReserver{full: 0, retryAfter: 1500 * time.Millisecond}, codes.ResourceExhausted, "ip", "2"},
+		{"email full", &recordingReserver{full: 1, retryAfter: 30 * time.Second}, codes.ResourceExhausted, "email", "30"},
+		{"limiter error", &recordingReserver{full: -1, err: errors.New("redis down")}, codes.Unavailable, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQuotas(policies, tt.reserver, false, zap.NewNop())
+			stream := &headerStream{}
+			ctx := grpc.NewContextWithServerTransportStream(ctx, stream)
+			_, err := q.UnaryInterceptor()(ctx, &proto.OTPRequest{Email: "ann@example.com"}, &grpc.UnaryServerInfo{FullMethod: "/notification.NotificationService/SendOTP"},
+				func(context.Context, interface{}) (interface{}, error) {
+					t.Fatal("handler called for a rejected request")
+					return nil, nil
+				})
+
+			st := status.Convert(err)
+			if st.Code() != tt.code {
+				t.Fatalf("code = %v, want %v", st.Code(), tt.code)
+			}
+			if tt.code != codes.ResourceExhausted {
+				return
+			}
+			if got := stream.header.Get("retry-after"); len(got) != 1 || got[0] != tt.retryAfter {
+				t.Errorf("retry-after = %v, want %s", got, tt.retryAfter)
+			}
+			var retry *errdetails.RetryInfo
+			var violation *errdetails.QuotaFailure
+			for _, detail := range st.Details() {
+				switch d := detail.(type) {
+				case *errdetails.RetryInfo:
+					retry = d
+				case *errdetails.QuotaFailure:
+					violation = d
+				}
+			}
+			if retry == nil || retry.RetryDelay.AsDuration() != tt.reserver.retryAfter {
+				t.Errorf("RetryInfo = %v, want a delay of %v", retry, tt.reserver.retryAfter)
+			}
+			if violation == nil || violation.Violations[0].Subject != tt.dimension {
+				t.Errorf("QuotaFailure = %v, want the %s quota", violation, tt.dimension)
+			}
+		})
+	}
+}
+
+func TestQuotasUnlimitedMethod(t *testing.T) {
+	reserver := &recordingReserver{full: 0, retryAfter: time.Second}
+	q := NewQuotas(map[string]QuotaPolicy{"SendOTP": {PerIP: ratelimit.Limit{Rate: 1, Burst: 1}}}, reserver, false, zap.NewNop())
+	called := false
+	_, err := q.UnaryInterceptor()(context.Background(), &proto.VerifyOTPRequest{}, &grpc.UnaryServerInfo{FullMethod: "/notification.NotificationService/VerifyOTP"},
+		func(context.Context, interface{}) (interface{}, error) { called = true; return nil, nil })
+	if err != nil || !called || len(reserver.reserved) != 0 {
+		t.Errorf("interceptor = %v, handler called %v, reserved %v; want the request handled unlimited", err, called, reserver.reserved)
+	}
+}
+
+func TestQuotasChargeOnlyAdmitted(t *testing.T) {
+	// The same email from many IPs: once its quota is full, the IP quotas
+	// of the rejected requests are left untouched
+	limit := ratelimit.Limit{Rate: 0.01, Burst: 1}
+	q := NewQuotas(map[string]QuotaPolicy{"SendOTP": {PerIP: limit, PerEmail: limit}}, ratelimit.NewLocalQuotas(0), false, zap.NewNop())
+	send := func(ip, email string) codes.Code {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 5555}})
+		_, err := q.UnaryInterceptor()(ctx, &proto.OTPRequest{Email: email}, &grpc.UnaryServerInfo{FullMethod: "/notification.NotificationService/SendOTP"},
+			func(context.Context, interface{}) (interface{}, error) { return nil, nil })
+		return status.Code(err)
+	}
+
+	steps := []struct {
+		ip, email string
+		want      codes.Code
+	}{
+		{"10.0.0.1", "ann@example.com", codes.OK},
+		{"10.0.0.2", "ann@example.com", codes.ResourceExhausted},
+		{"10.0.0.2", "bob@example.com", codes.OK},
+		{"10.0.0.1", "eve@example.com", codes.ResourceExhausted},
+	}
+	for i, step := range steps {
+		if got := send(step.ip, step.email); got != step.want {
+			t.Errorf("request %d from %s for %s = %v, want %v", i+1, step.ip, step.email, got, step.want)
+		}
+	}
+}
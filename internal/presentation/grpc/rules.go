@@ -0,0 +1,194 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func (h *Handler) CreateRule(ctx context.Context, req *proto.Rule) (*proto.Rule, error) {
+	rule, err := h.toRule(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rule, err = h.ruleService.CreateRule(ctx, rule)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoRule(rule)
+}
+
+// UpdateRule replaces the rule with the request's id.
+func (h *Handler) UpdateRule(ctx context.Context, req *proto.Rule) (*proto.Rule, error) {
+	if err := h.validate(field{"id", req.Id, "required,uuid"}); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+	rule, err := h.toRule(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = req.Id
+	rule, err = h.ruleService.UpdateRule(ctx, rule)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoRule(rule)
+}
+
+func (h *Handler) DeleteRule(ctx context.Context, req *proto.RuleRequest) (*proto.NotificationResponse, error) {
+	if err := h.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := h.validate(field{"id", req.Id, "required,uuid"}); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+	if err := h.ruleService.DeleteRule(ctx, req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.NotificationResponse{Success: true, Message: "Rule deleted"}, nil
+}
+
+func (h *Handler) GetRule(ctx context.Context, req *proto.RuleRequest) (*proto.Rule, error) {
+	if err := h.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := h.validate(field{"id", req.Id, "required,uuid"}); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+	rule, err := h.ruleService.GetRule(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoRule(rule)
+}
+
+func (h *Handler) ListRules(ctx context.Context, req *proto.ListRulesRequest) (*proto.ListRulesResponse, error) {
+	if err := h.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	rules, err := h.ruleService.ListRules(ctx, req.EventType)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &proto.ListRulesResponse{Rules: make([]*proto.Rule, len(rules))}
+	for i, rule := range rules {
+		if resp.Rules[i], err = toProtoRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// requireAdmin restricts rule management to admins. Calls without a
+// principal, because authentication is disabled or the method was made
+// public, are rejected.
+func (h *Handler) requireAdmin(ctx context.Context) error {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		h.logger.Warn("Unauthenticated call to manage rules")
+		return toStatus(domain.ErrUnauthenticated)
+	}
+	if !principal.HasRole(domain.RoleAdmin) {
+		h.logger.Warn("Principal not allowed to manage rules", zap.String("principal", principal.Subject))
+		return toStatus(domain.ErrUnauthorized)
+	}
+	return nil
+}
+
+// toRule validates a rule from a create or update request.
+func (h *Handler) toRule(ctx context.Context, req *proto.Rule) (domain.NotificationRule, error) {
+	if err := h.requireAdmin(ctx); err != nil {
+		return domain.NotificationRule{}, err
+	}
+	if err := h.validate(
+		field{"name", req.Name, "required,max=255"},
+		field{"event_type", req.EventType, "required,max=255"},
+		field{"channels", req.Channels, "required,min=1,max=3,unique,dive,oneof=email inapp push"},
+		field{"template", req.Template, "required,max=255"},
+		field{"user_field", req.UserField, "omitempty,max=255"},
+		field{"recipient_field", req.RecipientField, "omitempty,max=255"},
+		field{"locale_field", req.LocaleField, "omitempty,max=255"},
+		field{"category", req.Category, "omitempty,max=50"},
+		field{"priority", req.Priority, "omitempty,oneof=low normal high"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return domain.NotificationRule{}, err
+	}
+
+	conditions := make([]domain.RuleCondition, len(req.Conditions))
+	for i, c := range req.Conditions {
+		name := fmt.Sprintf("conditions[%d]", i)
+		if err := h.validate(
+			field{name + ".field", c.Field, "required,max=255"},
+			field{name + ".operator", c.Operator, "required,oneof=eq ne in exists gt gte lt lte"},
+		); err != nil {
+			h.logger.Warn("Validation failed", zap.Error(err))
+			return domain.NotificationRule{}, err
+		}
+		conditions[i] = domain.RuleCondition{
+			Field:    c.Field,
+			Operator: domain.RuleOperator(c.Operator),
+			Value:    c.Value.AsInterface(),
+		}
+		if _, ok := conditions[i].Value.([]interface{}); conditions[i].Operator == domain.RuleIn && !ok {
+			return domain.NotificationRule{}, invalidField(name+".value", "must be a list for operator in")
+		}
+	}
+
+	channels := make([]domain.NotificationType, len(req.Channels))
+	for i, c := range req.Channels {
+		channels[i] = domain.NotificationType(c)
+	}
+	return domain.NotificationRule{
+		Name:           req.Name,
+		EventType:      req.EventType,
+		Conditions:     conditions,
+		Channels:       channels,
+		Template:       req.Template,
+		UserField:      req.UserField,
+		RecipientField: req.RecipientField,
+		LocaleField:    req.LocaleField,
+		Category:       req.Category,
+		Priority:       domain.NotificationPriority(req.Priority),
+		Enabled:        req.Enabled,
+	}, nil
+}
+
+func toProtoRule(rule domain.NotificationRule) (*proto.Rule, error) {
+	conditions := make([]*proto.RuleCondition, len(rule.Conditions))
+	for i, c := range rule.Conditions {
+		value, err := structpb.NewValue(c.Value)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		conditions[i] = &proto.RuleCondition{Field: c.Field, Operator: string(c.Operator), Value: value}
+	}
+	channels := make([]string, len(rule.Channels))
+	for i, c := range rule.Channels {
+		channels[i] = string(c)
+	}
+	return &proto.Rule{
+		Id:             rule.ID,
+		Name:           rule.Name,
+		EventType:      rule.EventType,
+		Conditions:     conditions,
+		Channels:       channels,
+		Template:       rule.Template,
+		UserField:      rule.UserField,
+		RecipientField: rule.RecipientField,
+		LocaleField:    rule.LocaleField,
+		Category:       rule.Category,
+		Priority:       string(rule.Priority),
+		Enabled:        rule.Enabled,
+		CreatedAt:      rule.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      rule.UpdatedAt.Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxSearchPageSize caps SearchNotifications pages.
+	maxSearchPageSize = 50
+	// maxSearchOffset bounds how deep search results can be paged, since
+	// every page re-ranks all matches before it.
+	maxSearchOffset = 1000
+)
+
+func (h *Handler) SearchNotifications(ctx context.Context, req *proto.SearchNotificationsRequest) (*proto.SearchNotificationsResponse, error) {
+	if err := h.validate(
+		field{"user_id", req.UserId, "required"},
+		field{"query", strings.TrimSpace(req.Query), "required,max=256"},
+		field{"language", req.Language, "omitempty,oneof=" + strings.Join(domain.SearchLanguages, " ")},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > maxSearchPageSize {
+		pageSize = maxSearchPageSize
+	}
+
+	filter, err := h.notificationFilter(&proto.GetAllNotificationsRequest{
+		Type:          req.Type,
+		Category:      req.Category,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		IsRead:        req.IsRead,
+	})
+	if err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+	if req.IncludeArchived {
+		filter.Archived = nil
+	}
+
+	offset := 0
+	if req.PageToken != "" {
+		offset, err = decodeSearchPageToken(req.PageToken)
+		if err != nil {
+			return nil, invalidField("page_token", "is not a valid page token")
+		}
+	}
+
+	query := domain.SearchQuery{Text: req.Query, Language: req.Language, Filter: filter}
+	results, more, err := h.notificationService.SearchNotifications(ctx, req.UserId, query, offset, pageSize)
+	if err != nil {
+		h.logger.Error("Failed to search notifications", zap.String("userId", req.UserId), zap.Error(err))
+		return nil, toStatus(err)
+	}
+
+	resp := &proto.SearchNotificationsResponse{Results: make([]*proto.SearchResult, len(results))}
+	for i, r := range results {
+		resp.Results[i] = &proto.SearchResult{
+			Notification:   toProtoNotification(r.Notification),
+			Rank:           r.Rank,
+			SubjectSnippet: r.SubjectSnippet,
+			BodySnippet:    r.BodySnippet,
+		}
+	}
+	if next := offset + len(results); more && next < maxSearchOffset {
+		resp.NextPageToken = encodeSearchPageToken(next)
+	}
+	return resp, nil
+}
+
+// Search pages are ranked rather than keyset ordered, so the page token is an
+// opaque encoding of the result offset.
+func encodeSearchPageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeSearchPageToken(token string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 || offset >= maxSearchOffset {
+		return 0, strconv.ErrRange
+	}
+	return offset, nil
+}
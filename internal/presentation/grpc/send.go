@@ -0,0 +1,148 @@
+package grpc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/Shafeeqth/notification-service/internal/proto"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+func (h *Handler) SendNotification(ctx context.Context, req *proto.SendNotificationRequest) (*proto.SendNotificationResponse, error) {
+	sent, err := h.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.SendNotificationResponse{Notifications: sent}, nil
+}
+
+// BatchSendNotifications sends each request independently, so one invalid
+// request fails only its own result.
+func (h *Handler) BatchSendNotifications(ctx context.Context, req *proto.BatchSendNotificationsRequest) (*proto.BatchSendNotificationsResponse, error) {
+	if err := h.validate(
+		field{"requests", req.Requests, "required,min=1,max=" + strconv.Itoa(maxBatchSize)},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+
+	results := make([]*proto.BatchSendResult, len(req.Requests))
+	for i, r := range req.Requests {
+		sent, err := h.send(ctx, r)
+		if err != nil {
+			results[i] = toBatchSendError(err)
+			continue
+		}
+		results[i] = &proto.BatchSendResult{Notifications: sent}
+	}
+	return &proto.BatchSendNotificationsResponse{Results: results}, nil
+}
+
+func (h *Handler) send(ctx context.Context, req *proto.SendNotificationRequest) ([]*proto.SentNotification, error) {
+	if req == nil {
+		return nil, invalidField("request", "is required")
+	}
+	if err := h.validate(
+		field{"user_id", req.UserId, "required"},
+		field{"channels", req.Channels, "required,min=1,max=3,unique,dive,oneof=email inapp push"},
+		field{"recipient", req.Recipient, "omitempty,email"},
+		field{"locale", req.Locale, "omitempty,bcp47_language_tag"},
+		field{"priority", req.Priority, "omitempty,oneof=low normal high"},
+		field{"idempotency_key", req.IdempotencyKey, "omitempty,max=255"},
+	); err != nil {
+		h.logger.Warn("Validation failed", zap.Error(err))
+		return nil, err
+	}
+	if req.Template != "" && (req.Subject != "" || req.Body != "") {
+		return nil, invalidField("template", "cannot be combined with subject or body")
+	}
+	var sendAt *time.Time
+	if req.SendAt != "" {
+		t, err := time.Parse(time.RFC3339, req.SendAt)
+		if err != nil {
+			return nil, invalidField("send_at", "must be an RFC3339 timestamp")
+		}
+		sendAt = &t
+	}
+
+	channels := make([]domain.NotificationType, len(req.Channels))
+	for i, c := range req.Channels {
+		channels[i] = domain.NotificationType(c)
+	}
+	notifications, err := h.notificationService.Send(ctx, domain.SendRequest{
+		Channels: channels,
+		Content: domain.Notification{
+			UserId:    req.UserId,
+			Recipient: req.Recipient,
+			Subject:   req.Subject,
+			Body:      req.Body,
+			Category:  req.Category,
+			DeepLink:  req.DeepLink,
+			IconURL:   req.IconUrl,
+			ImageURL:  req.ImageUrl,
+			Actions:   fromProtoActions(req.Actions),
+			Metadata:  req.Metadata.AsMap(),
+			Priority:  domain.NotificationPriority(req.Priority),
+			SendAt:    sendAt,
+		},
+		Template:       req.Template,
+		TemplateData:   templateData(req.TemplateData.AsMap()),
+		Locale:         req.Locale,
+		IdempotencyKey: req.IdempotencyKey,
+	})
+	if err != nil {
+		h.logger.Error("Failed to send notification",
+			zap.String("userId", req.UserId),
+			zap.Strings("channels", req.Channels),
+			zap.Error(err))
+		return nil, toStatus(err)
+	}
+
+	sent := make([]*proto.SentNotification, len(notifications))
+	for i, n := range notifications {
+		sent[i] = &proto.SentNotification{Channel: string(n.Type), NotificationId: n.ID}
+	}
+	return sent, nil
+}
+
+// templateData turns RFC3339 strings into times so templates can format
+// them as dates.
+func templateData(data map[string]interface{}) map[string]interface{} {
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				data[k] = t
+			}
+		}
+	}
+	return data
+}
+
+// toBatchSendError reports err as the status SendNotification would have
+// returned, with any field violations spelled out in the message.
+func toBatchSendError(err error) *proto.BatchSendResult {
+	st := status.Convert(err)
+	result := &proto.BatchSendResult{
+		ErrorCode:    code.Code(st.Code()).String(),
+		ErrorMessage: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			result.ErrorReason = d.Reason
+		case *errdetails.BadRequest:
+			violations := make([]string, len(d.FieldViolations))
+			for i, v := range d.FieldViolations {
+				violations[i] = v.Field + " " + v.Description
+			}
+			result.ErrorMessage += ": " + strings.Join(violations, "; ")
+		}
+	}
+	return result
+}
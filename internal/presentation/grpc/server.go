@@ -1,12 +1,14 @@
 package grpc
 
 import (
+	"crypto/tls"
 	"net"
 
 	"github.com/Shafeeqth/notification-service/internal/application/service"
 	"github.com/Shafeeqth/notification-service/internal/proto"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type Server struct {
@@ -14,9 +16,18 @@ type Server struct {
 	logger     *zap.Logger
 }
 
-func NewServer(notificationService *service.NotificationService, otpService *service.OTPService, logger *zap.Logger) *Server {
-	grpcServer := grpc.NewServer()
-	handler := NewHandler(notificationService, otpService, logger)
+// NewServer creates the gRPC server. tlsConfig may be nil to serve
+// plaintext; interceptors run in the given order.
+func NewServer(notificationService *service.NotificationService, otpService *service.OTPService, ruleService *service.RuleService, tlsConfig *tls.Config, logger *zap.Logger, unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor) *Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(opts...)
+	handler := NewHandler(notificationService, otpService, ruleService, logger)
 	proto.RegisterNotificationServiceServer(grpcServer, handler)
 	return &Server{
 		grpcServer: grpcServer, logger: logger,
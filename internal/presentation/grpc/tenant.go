@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// tenantMetadataKey names the tenant a call acts in.
+const tenantMetadataKey = "x-tenant-id"
+
+// TenantResolver stores the tenant of each call in its context. A caller
+// whose credential is bound to a tenant always acts in it. Admins and
+// services without a bound tenant, and unauthenticated calls to public
+// methods, choose one with x-tenant-id metadata; other users may not, and
+// act in domain.DefaultTenant. Without metadata the tenant is
+// domain.DefaultTenant. It must run after authentication.
+type TenantResolver struct {
+	tenants domain.Tenants
+	logger  *zap.Logger
+}
+
+func NewTenantResolver(tenants domain.Tenants, logger *zap.Logger) *TenantResolver {
+	return &TenantResolver{tenants: tenants, logger: logger}
+}
+
+func (t *TenantResolver) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := t.resolve(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (t *TenantResolver) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := t.resolve(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tenantStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (t *TenantResolver) resolve(ctx context.Context, method string) (context.Context, error) {
+	tenantId := firstMetadata(ctx, tenantMetadataKey)
+	if principal, ok := domain.PrincipalFromContext(ctx); ok && principal.Tenant != "" {
+		if tenantId != "" && tenantId != principal.Tenant {
+			t.logger.Warn("Principal not allowed to act in tenant",
+				zap.String("method", method),
+				zap.String("principal", principal.Subject),
+				zap.String("tenant_id", tenantId))
+			return nil, toStatus(domain.ErrUnauthorized)
+		}
+		tenantId = principal.Tenant
+	} else if ok && tenantId != "" && tenantId != domain.DefaultTenant &&
+		!principal.HasRole(domain.RoleAdmin) && !principal.HasRole(domain.RoleService) {
+		// A user credential without a tenant claim belongs to the default
+		// tenant; choosing another would cross tenant isolation
+		t.logger.Warn("Principal not allowed to choose a tenant",
+			zap.String("method", method),
+			zap.String("principal", principal.Subject),
+			zap.String("tenant_id", tenantId))
+		return nil, toStatus(domain.ErrUnauthorized)
+	}
+	if tenantId == "" {
+		tenantId = domain.DefaultTenant
+	}
+
+	if _, err := t.tenants.Lookup(tenantId); err != nil {
+		t.logger.Warn("Unknown tenant", zap.String("method", method), zap.String("tenant_id", tenantId))
+		return nil, toStatus(err)
+	}
+	return domain.ContextWithTenant(ctx, tenantId), nil
+}
+
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTenantResolver(t *testing.T) {
+	user := &domain.Principal{Subject: "u1", Kind: domain.UserPrincipal}
+	boundUser := &domain.Principal{Subject: "u2", Kind: domain.UserPrincipal, Tenant: "acme"}
+	svc := &domain.Principal{Subject: "svc", Kind: domain.ServicePrincipal, Roles: []string{domain.RoleService}}
+	admin := &domain.Principal{Subject: "a1", Kind: domain.UserPrincipal, Roles: []string{domain.RoleAdmin}}
+
+	tests := []struct {
+		name      string
+		principal *domain.Principal
+		metadata  string
+		tenant    string
+		code      codes.Code
+	}{
+		{"user without metadata", user, "", domain.DefaultTenant, codes.OK},
+		{"user choosing a tenant", user, "acme", "", codes.PermissionDenied},
+		{"user naming the default tenant", user, domain.DefaultTenant, domain.DefaultTenant, codes.OK},
+		{"bound user", boundUser, "", "acme", codes.OK},
+		{"bound user in another tenant", boundUser, "globex", "", codes.PermissionDenied},
+		{"service choosing a tenant", svc, "acme", "acme", codes.OK},
+		{"admin choosing a tenant", admin, "globex", "globex", codes.OK},
+		{"service in an unknown tenant", svc, "initech", "", codes.InvalidArgument},
+		{"unauthenticated public call", nil, "acme", "acme", codes.OK},
+	}
+	resolver := NewTenantResolver(domain.Tenants{
+		domain.DefaultTenant: {},
+		"acme":               {},
+		"globex":             {},
+	}, zap.NewNop())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.metadata != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(tenantMetadataKey, tt.metadata))
+			}
+			if tt.principal != nil {
+				ctx = domain.ContextWithPrincipal(ctx, *tt.principal)
+			}
+			ctx, err := resolver.resolve(ctx, "/notification.NotificationService/GetAllNotifications")
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("resolve() code = %v, want %v (%v)", code, tt.code, err)
+			}
+			if err == nil && domain.TenantFromContext(ctx) != tt.tenant {
+				t.Errorf("tenant = %q, want %q", domain.TenantFromContext(ctx), tt.tenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/Shafeeqth/notification-service/internal/domain"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Trace metadata. A call without a correlation ID is given a new one,
+// returned in the response header.
+const (
+	correlationMetadataKey = "x-correlation-id"
+	requestIdMetadataKey   = "x-request-id"
+	traceParentMetadataKey = "traceparent"
+	traceStateMetadataKey  = "tracestate"
+)
+
+// TraceUnaryInterceptor stores each call's trace in its context, so
+// notifications it queues carry the caller's correlation ID and trace
+// context.
+func TraceUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withTrace(ctx), req)
+	}
+}
+
+func TraceStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &tenantStream{ServerStream: ss, ctx: withTrace(ss.Context())})
+	}
+}
+
+func withTrace(ctx context.Context) context.Context {
+	trace := domain.Trace{
+		CorrelationID: firstMetadata(ctx, correlationMetadataKey),
+		TraceParent:   firstMetadata(ctx, traceParentMetadataKey),
+		TraceState:    firstMetadata(ctx, traceStateMetadataKey),
+	}
+	if trace.CorrelationID == "" {
+		trace.CorrelationID = firstMetadata(ctx, requestIdMetadataKey)
+	}
+	if trace.CorrelationID == "" {
+		trace.CorrelationID = uuid.New().String()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(correlationMetadataKey, trace.CorrelationID))
+	return domain.ContextWithTrace(ctx, trace)
+}
@@ -0,0 +1,15 @@
+// Package template embeds the email templates and their message catalogs.
+//
+// layout.html wraps every page; each other *.html file is a page that
+// defines the "content" block. The pages hold no text of their own: it
+// comes from locales/<language tag>.json, a flat map of message ids to ICU
+// MessageFormat strings. A page named "activation-mail" takes its subject
+// from the "activation-mail.subject" message. Channels that can't show HTML
+// use text templates instead, which have no page: just "<name>.subject" and
+// "<name>.body" messages.
+package template
+
+import "embed"
+
+//go:embed *.html locales/*.json
+var FS embed.FS
@@ -1 +1 @@
-DROP TABLE notifications;
\ No newline at end of file
+DROP TABLE IF EXISTS notifications;
@@ -1,14 +1,13 @@
--- GRANT USAGE ON SCHEMA public TO shafeeque;
--- GRANT CREATE ON SCHEMA public TO shafeeque;
-
-CREATE TABLE notifications {
+CREATE TABLE IF NOT EXISTS notifications (
     id UUID PRIMARY KEY,
     user_id UUID NOT NULL,
     type VARCHAR(50) NOT NULL,
     subject TEXT,
     body TEXT NOT NULL,
+    recipient TEXT,
     is_read BOOLEAN DEFAULT FALSE,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    INDEX idx_user_id (user_id),
-    INDEX idx_is_read (is_read)
-}
\ No newline at end of file
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_is_read ON notifications (is_read);
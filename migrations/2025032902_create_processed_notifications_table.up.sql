@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS processed_notifications (
+    notification_id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_notifications_created_at ON processed_notifications (created_at);
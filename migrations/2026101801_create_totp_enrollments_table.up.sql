@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS totp_enrollments (
+    user_id UUID PRIMARY KEY,
+    secret TEXT NOT NULL,
+    confirmed BOOLEAN DEFAULT FALSE,
+    last_used_step BIGINT DEFAULT 0,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
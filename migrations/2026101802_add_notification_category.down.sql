@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notifications_category;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS category;
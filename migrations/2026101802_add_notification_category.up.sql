@@ -0,0 +1,3 @@
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS category VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_category ON notifications (category);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_notifications_user_listing;
+DROP INDEX IF EXISTS idx_notifications_deleted_at;
+DROP INDEX IF EXISTS idx_notifications_archived_at;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS snoozed_until,
+    DROP COLUMN IF EXISTS archived_at,
+    DROP COLUMN IF EXISTS pinned;
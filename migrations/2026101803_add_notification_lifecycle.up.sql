@@ -0,0 +1,12 @@
+ALTER TABLE notifications
+    ADD COLUMN IF NOT EXISTS pinned BOOLEAN DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_archived_at ON notifications (archived_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_deleted_at ON notifications (deleted_at);
+
+-- Keyset pagination order: pinned first, then newest
+DROP INDEX IF EXISTS idx_notifications_user_created;
+CREATE INDEX IF NOT EXISTS idx_notifications_user_listing ON notifications (user_id, pinned, created_at, id);
@@ -0,0 +1,43 @@
+-- Rebuild notifications as a plain table, dropping all partitions.
+DO $$
+BEGIN
+    IF (SELECT relkind FROM pg_class WHERE oid = to_regclass('notifications')) <> 'p' THEN
+        RETURN;
+    END IF;
+
+    ALTER TABLE notifications RENAME TO notifications_partitioned;
+    -- Free the primary key name for the new table
+    EXECUTE format('ALTER TABLE notifications_partitioned RENAME CONSTRAINT %I TO notifications_partitioned_pkey',
+        (SELECT conname FROM pg_constraint
+         WHERE conrelid = 'notifications_partitioned'::regclass AND contype = 'p'));
+
+    CREATE TABLE notifications (
+        id UUID PRIMARY KEY,
+        user_id UUID NOT NULL,
+        type VARCHAR(50) NOT NULL,
+        category VARCHAR(50),
+        subject TEXT,
+        body TEXT NOT NULL,
+        recipient TEXT,
+        is_read BOOLEAN DEFAULT FALSE,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        pinned BOOLEAN DEFAULT FALSE,
+        archived_at TIMESTAMPTZ,
+        snoozed_until TIMESTAMPTZ,
+        deleted_at TIMESTAMPTZ
+    );
+
+    INSERT INTO notifications
+    SELECT id, user_id, type, category, subject, body, recipient, is_read, created_at,
+           pinned, archived_at, snoozed_until, deleted_at
+    FROM notifications_partitioned;
+
+    DROP TABLE notifications_partitioned CASCADE;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_is_read ON notifications (is_read);
+CREATE INDEX IF NOT EXISTS idx_notifications_category ON notifications (category);
+CREATE INDEX IF NOT EXISTS idx_notifications_archived_at ON notifications (archived_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_deleted_at ON notifications (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_listing ON notifications (user_id, pinned, created_at, id);
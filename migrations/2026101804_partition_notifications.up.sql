@@ -0,0 +1,61 @@
+-- Rebuild notifications as a table range partitioned by month of created_at,
+-- copying existing rows. Partition names must match partitionNameLayout in
+-- internal/infrastructure/database/partition.go. Tables that are already
+-- partitioned are left alone.
+DO $$
+DECLARE
+    m DATE;
+    last_m DATE;
+BEGIN
+    IF (SELECT relkind FROM pg_class WHERE oid = to_regclass('notifications')) = 'p' THEN
+        RETURN;
+    END IF;
+
+    ALTER TABLE notifications RENAME TO notifications_unpartitioned;
+    -- Free the primary key name for the new table
+    EXECUTE format('ALTER TABLE notifications_unpartitioned RENAME CONSTRAINT %I TO notifications_unpartitioned_pkey',
+        (SELECT conname FROM pg_constraint
+         WHERE conrelid = 'notifications_unpartitioned'::regclass AND contype = 'p'));
+
+    CREATE TABLE notifications (
+        id UUID NOT NULL,
+        user_id UUID NOT NULL,
+        type VARCHAR(50) NOT NULL,
+        category VARCHAR(50),
+        subject TEXT,
+        body TEXT NOT NULL,
+        recipient TEXT,
+        is_read BOOLEAN DEFAULT FALSE,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        pinned BOOLEAN DEFAULT FALSE,
+        archived_at TIMESTAMPTZ,
+        snoozed_until TIMESTAMPTZ,
+        deleted_at TIMESTAMPTZ,
+        PRIMARY KEY (id, created_at)
+    ) PARTITION BY RANGE (created_at);
+
+    m := date_trunc('month', COALESCE((SELECT min(created_at) FROM notifications_unpartitioned), now()) AT TIME ZONE 'UTC')::date;
+    last_m := (date_trunc('month', now() AT TIME ZONE 'UTC') + interval '2 months')::date;
+    WHILE m <= last_m LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF notifications FOR VALUES FROM (%L) TO (%L)',
+            'notifications_y' || to_char(m, 'YYYY') || 'm' || to_char(m, 'MM'),
+            m::timestamp AT TIME ZONE 'UTC',
+            (m + interval '1 month')::timestamp AT TIME ZONE 'UTC');
+        m := (m + interval '1 month')::date;
+    END LOOP;
+
+    INSERT INTO notifications (id, user_id, type, category, subject, body, recipient, is_read, created_at,
+                               pinned, archived_at, snoozed_until, deleted_at)
+    SELECT id, user_id, COALESCE(type, ''), category, subject, COALESCE(body, ''), recipient, is_read, COALESCE(created_at, now()),
+           pinned, archived_at, snoozed_until, deleted_at
+    FROM notifications_unpartitioned;
+
+    DROP TABLE notifications_unpartitioned;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_is_read ON notifications (is_read);
+CREATE INDEX IF NOT EXISTS idx_notifications_category ON notifications (category);
+CREATE INDEX IF NOT EXISTS idx_notifications_archived_at ON notifications (archived_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_deleted_at ON notifications (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_listing ON notifications (user_id, pinned, created_at, id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_notifications_search_vector;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS search_config;
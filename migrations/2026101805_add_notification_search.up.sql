@@ -0,0 +1,10 @@
+-- Full-text search over subject (weight A) and body (weight B), using each
+-- row's text search configuration
+ALTER TABLE notifications
+    ADD COLUMN IF NOT EXISTS search_config REGCONFIG NOT NULL DEFAULT 'english',
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector(search_config, coalesce(subject, '')), 'A') ||
+        setweight(to_tsvector(search_config, coalesce(body, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_search_vector ON notifications USING GIN (search_vector);
@@ -0,0 +1,12 @@
+-- Fails if a user is enrolled under more than one tenant
+ALTER TABLE totp_enrollments DROP CONSTRAINT IF EXISTS totp_enrollments_pkey;
+ALTER TABLE totp_enrollments ADD CONSTRAINT totp_enrollments_pkey PRIMARY KEY (user_id);
+
+ALTER TABLE totp_enrollments
+    DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_notifications_user_listing;
+CREATE INDEX IF NOT EXISTS idx_notifications_user_listing ON notifications (user_id, pinned, created_at, id);
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,12 @@
+-- Rows written before multi-tenancy belong to the default tenant
+ALTER TABLE notifications
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS idx_notifications_user_listing;
+CREATE INDEX IF NOT EXISTS idx_notifications_user_listing ON notifications (tenant_id, user_id, pinned, created_at, id);
+
+ALTER TABLE totp_enrollments
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+ALTER TABLE totp_enrollments DROP CONSTRAINT IF EXISTS totp_enrollments_pkey;
+ALTER TABLE totp_enrollments ADD CONSTRAINT totp_enrollments_pkey PRIMARY KEY (tenant_id, user_id);
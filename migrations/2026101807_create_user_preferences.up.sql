@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_preferences (
+    tenant_id VARCHAR(64) NOT NULL,
+    user_id UUID NOT NULL,
+    locale VARCHAR(35),
+    time_zone VARCHAR(64),
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY (tenant_id, user_id)
+);
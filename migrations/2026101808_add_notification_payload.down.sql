@@ -0,0 +1,6 @@
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS metadata,
+    DROP COLUMN IF EXISTS actions,
+    DROP COLUMN IF EXISTS image_url,
+    DROP COLUMN IF EXISTS icon_url,
+    DROP COLUMN IF EXISTS deep_link;
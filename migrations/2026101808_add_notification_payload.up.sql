@@ -0,0 +1,6 @@
+ALTER TABLE notifications
+    ADD COLUMN IF NOT EXISTS deep_link TEXT,
+    ADD COLUMN IF NOT EXISTS icon_url TEXT,
+    ADD COLUMN IF NOT EXISTS image_url TEXT,
+    ADD COLUMN IF NOT EXISTS actions JSONB,
+    ADD COLUMN IF NOT EXISTS metadata JSONB;
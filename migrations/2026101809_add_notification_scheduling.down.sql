@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_notifications_scheduled;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS queued_at,
+    DROP COLUMN IF EXISTS send_at,
+    DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,9 @@
+ALTER TABLE notifications
+    ADD COLUMN IF NOT EXISTS priority VARCHAR(10) DEFAULT 'normal',
+    ADD COLUMN IF NOT EXISTS send_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS queued_at TIMESTAMPTZ;
+
+-- Only pending scheduled notifications are indexed, so the scheduler's
+-- claim query stays cheap however many notifications are stored
+CREATE INDEX IF NOT EXISTS idx_notifications_scheduled ON notifications (send_at)
+    WHERE send_at IS NOT NULL AND queued_at IS NULL;
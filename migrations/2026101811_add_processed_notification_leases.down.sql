@@ -0,0 +1,7 @@
+-- Unfinished claims would read as processed without their lease columns
+DELETE FROM processed_notifications WHERE processed_at IS NULL;
+
+ALTER TABLE processed_notifications
+    DROP COLUMN IF EXISTS processed_at,
+    DROP COLUMN IF EXISTS lease_expires_at,
+    DROP COLUMN IF EXISTS owner;
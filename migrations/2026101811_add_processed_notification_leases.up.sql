@@ -0,0 +1,7 @@
+ALTER TABLE processed_notifications
+    ADD COLUMN IF NOT EXISTS owner VARCHAR(128),
+    ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS processed_at TIMESTAMPTZ;
+
+-- Rows written before leases were only recorded after delivery
+UPDATE processed_notifications SET processed_at = created_at WHERE processed_at IS NULL;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS notification_rules (
+    id UUID PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    name VARCHAR(255),
+    event_type VARCHAR(255),
+    conditions JSONB,
+    channels JSONB,
+    template VARCHAR(255),
+    user_field VARCHAR(255),
+    recipient_field VARCHAR(255),
+    locale_field VARCHAR(255),
+    category VARCHAR(50),
+    priority VARCHAR(10),
+    enabled BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_rules_event ON notification_rules (tenant_id, event_type);
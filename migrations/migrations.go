@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL schema migrations. They are applied
+// with the server's "migrate" subcommand; the server itself refuses to
+// start against a dirty or outdated schema.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS